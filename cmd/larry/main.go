@@ -4,11 +4,11 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	tea "github.com/charmbracelet/bubbletea"
-	"golang.design/x/clipboard"
+	"larry/internal/buffer"
 	"larry/internal/config"
 	"larry/internal/ui"
 	"os"
@@ -25,41 +25,10 @@ func main() {
 		return
 	}
 
-	// Initialize the system clipboard
-	err := clipboard.Init()
-	if err != nil {
-		fmt.Printf("Warning: Could not initialize clipboard: %v\n", err)
-		// Continue anyway - clipboard features may not work
-	}
-
-	// Handle CLI arguments
-	filename := ""
-	var lines []string
-	args := flag.Args()
-	if len(args) > 0 {
-		filename = args[0]
-		file, err := os.Open(filename)
-		if err == nil {
-			defer file.Close()
-			scanner := bufio.NewScanner(file)
-			// Increase buffer size to handle potential long lines (1MB)
-			const maxCapacity = 1024 * 1024
-			buf := make([]byte, maxCapacity)
-			scanner.Buffer(buf, maxCapacity)
-			for scanner.Scan() {
-				lines = append(lines, scanner.Text())
-			}
-		}
-		// If read fails (e.g. new file), we start with empty lines and the filename
-	}
-
-	if len(lines) == 0 {
-		lines = []string{""}
-	}
-
 	// Load configuration
 	cfg, err := config.LoadConfig(*configPath)
-	if err != nil {
+	keysErr := errors.Is(err, config.ErrKeybindings)
+	if err != nil && !keysErr {
 		// Only print error if user explicitly provided a path that failed
 		if *configPath != "" {
 			fmt.Printf("Warning: Could not load config: %v. Using defaults.\n", err)
@@ -67,11 +36,22 @@ func main() {
 		// If no path provided or fallback, LoadConfig returns valid cfg (default) usually,
 	}
 
-	// Initialize the model
-	m := ui.InitialModel(filename, lines, cfg)
+	// Initialize the model. Every file given gets its own buffer; the files are
+	// read once the program runs, so the first screen shows up right away
+	m := ui.InitialModel("", buffer.NewBuffer(), cfg).OpenFiles(flag.Args())
+	if keysErr {
+		// The rest of the config is used, show the problem in the editor
+		m = m.WithStatus("Config: " + err.Error())
+	}
 
 	// Create and run the Bubble Tea program
-	p := tea.NewProgram(m, tea.WithAltScreen()) // Use alternate screen for clean TUI
+	// Use alternate screen for clean TUI. Bracketed paste is on by default, so a paste arrives as one key
+	opts := []tea.ProgramOption{tea.WithAltScreen()}
+	if cfg.Mouse {
+		// Report clicks, drags and the wheel; most terminals still select text natively with Shift held
+		opts = append(opts, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(m, opts...)
 
 	// Run the program and handle any errors
 	if _, err := p.Run(); err != nil {
@@ -86,10 +66,10 @@ func showHelp() {
 A minimalist, high-performance TUI text editor written in Go.
 
 USAGE:
-  larry [OPTIONS] [FILE]
+  larry [OPTIONS] [FILE...]
 
 ARGUMENTS:
-  FILE    Optional file to open on startup
+  FILE    Optional files to open on startup, each in its own buffer
 
 OPTIONS:
   -config string    Path to configuration file (default: uses built-in defaults)
@@ -102,6 +82,9 @@ EXAMPLES:
   # Open a specific file
   larry myfile.txt
 
+  # Open several files
+  larry a.go b.go c.go
+
   # Open with custom configuration
   larry -config ~/.config/larry/config.json myfile.txt
 
@@ -116,6 +99,19 @@ CONFIGURATION:
     tab_width   - Number of spaces for tab character (default: 4)
     line_numbers - Show/hide line numbers (default: true)
     leader_key  - Base key for shortcuts (default: "ctrl", use "cmd" for macOS)
+    backup      - Keep the previous version of a saved file as "file~" (default: false)
+    swap        - Journal unsaved changes to a swap file for crash recovery (default: true)
+    undo_file   - Keep the undo history of saved files across sessions (default: true)
+    mouse       - Click, drag and scroll with the mouse (default: true)
+    clipboard   - Clipboard providers to try in order: "native", "commands"
+                  (pbcopy, xclip, wl-copy), "osc52" (through the terminal,
+                  works over SSH) and "internal" (default: all of them)
+    clipboard_history - Copied and cut texts to keep (default: 60)
+    save_clipboard_history - Keep the clipboard history across sessions (default: false)
+    keymap      - "default", "vim" for modal editing on top of the default keys,
+                  or "emacs" for emacs keys with a kill ring
+    keybindings - Keys for actions by name, e.g. {"save": ["leader+s", "f2"]}
+                  or sequences of keys, e.g. {"grow_pane": "leader+k leader+k"}
 
   Example config.json:
     {
@@ -1,67 +1,390 @@
 // internal/buffer/buffer.go
 // Package buffer handles the text buffer operations for the text editor.
-// It provides methods to manipulate the internal data structure ([][]rune) representing lines of text.
+// The document is stored as a piece table: the original file content is kept
+// untouched, every inserted text is appended to an add buffer, and the document
+// is described by an ordered list of pieces pointing into those two buffers.
+// Each buffer keeps an index of its newline offsets so lines can be located
+// without scanning the whole document.
 package buffer
 
-// Buffer represents the text buffer as a slice of rune slices.
-// Each inner slice is a line of text.
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type source uint8
+
+const (
+	sourceOriginal source = iota
+	sourceAdd
+)
+
+// piece is a span of one of the two backing buffers.
+type piece struct {
+	src      source
+	start    int // byte offset inside the backing buffer
+	length   int // length in bytes
+	newlines int // number of '\n' inside the span
+}
+
+// Buffer is a piece table backed text document.
+// Rows and columns in the public API are zero-based, columns are counted in runes.
 type Buffer struct {
-	Lines [][]rune
+	// Format is the on-disk layout used by Bytes.
+	Format Format
+	// Stamp identifies the file version last loaded or saved.
+	Stamp Stamp
+
+	original   string
+	originalNL []int // offsets of '\n' in original
+	add        []byte
+	addNL      []int // offsets of '\n' in add
+
+	pieces []piece
+
+	// Prefix sums over pieces, rebuilt lazily after an edit.
+	indexDirty bool
+	pieceBytes []int // bytes before piece i
+	pieceLines []int // newlines before piece i
+	totalBytes int
+	totalLines int // total number of '\n'
+
+	// Non-nil while the line index of original is built in the background, see Load.
+	loading *loadState
 }
 
 // NewBuffer creates a new empty buffer with one empty line.
 func NewBuffer() *Buffer {
-	return &Buffer{
-		Lines: [][]rune{{}}, // Start with one empty line
+	b := New("")
+	b.Format = DefaultFormat()
+	return b
+}
+
+// New creates a buffer holding content.
+func New(content string) *Buffer {
+	b := &Buffer{
+		original:   content,
+		originalNL: indexNewlines(content, 0, nil),
+		indexDirty: true,
+	}
+	if len(content) > 0 {
+		b.pieces = []piece{{src: sourceOriginal, start: 0, length: len(content), newlines: len(b.originalNL)}}
+	}
+	return b
+}
+
+// NewFromLines creates a buffer from lines joined by '\n'.
+func NewFromLines(lines []string) *Buffer {
+	return New(strings.Join(lines, "\n"))
+}
+
+func indexNewlines(s string, base int, dst []int) []int {
+	off := 0
+	for {
+		i := strings.IndexByte(s[off:], '\n')
+		if i < 0 {
+			return dst
+		}
+		dst = append(dst, base+off+i)
+		off += i + 1
+	}
+}
+
+func (b *Buffer) newlineIndex(src source) []int {
+	if src == sourceOriginal {
+		return b.originalNL
+	}
+	return b.addNL
+}
+
+// text returns bytes [from, to) of the text of p. Only those are copied
+// from the add buffer, a large paste is a single piece.
+func (b *Buffer) text(p piece, from, to int) string {
+	if p.src == sourceOriginal {
+		return b.original[p.start+from : p.start+to]
 	}
+	return string(b.add[p.start+from : p.start+to])
+}
+
+// countNewlines returns how many newlines of src fall in [start, end).
+func (b *Buffer) countNewlines(src source, start, end int) int {
+	nl := b.newlineIndex(src)
+	return sort.SearchInts(nl, end) - sort.SearchInts(nl, start)
+}
+
+func (b *Buffer) makePiece(src source, start, length int) piece {
+	return piece{src: src, start: start, length: length, newlines: b.countNewlines(src, start, start+length)}
 }
 
-// InsertRune inserts a rune at the specified position (line, col).
-// If the line or column is out of bounds, it adjusts accordingly.
-func (b *Buffer) InsertRune(line, col int, r rune) {
-	if line < 0 || line >= len(b.Lines) {
+func (b *Buffer) rebuildIndex() {
+	b.finishLoading()
+	if !b.indexDirty {
 		return
 	}
-	if col < 0 {
-		col = 0
+	b.pieceBytes = b.pieceBytes[:0]
+	b.pieceLines = b.pieceLines[:0]
+	bytes, lines := 0, 0
+	for _, p := range b.pieces {
+		b.pieceBytes = append(b.pieceBytes, bytes)
+		b.pieceLines = append(b.pieceLines, lines)
+		bytes += p.length
+		lines += p.newlines
+	}
+	b.totalBytes = bytes
+	b.totalLines = lines
+	b.indexDirty = false
+}
+
+// Len returns the document size in bytes.
+func (b *Buffer) Len() int {
+	b.rebuildIndex()
+	return b.totalBytes
+}
+
+// LineCount returns the number of lines. An empty document has one line.
+// While a large file is still being indexed it returns the lines found so far.
+func (b *Buffer) LineCount() int {
+	if b.loading != nil {
+		if n, ok := b.partialLineCount(); ok {
+			return n
+		}
+	}
+	b.rebuildIndex()
+	return b.totalLines + 1
+}
+
+// pieceAt returns the index of the piece containing the byte offset off.
+// An offset equal to Len() returns len(pieces).
+func (b *Buffer) pieceAt(off int) int {
+	b.rebuildIndex()
+	return sort.Search(len(b.pieces), func(i int) bool {
+		return b.pieceBytes[i]+b.pieces[i].length > off
+	})
+}
+
+// lineStart returns the byte offset of the first byte of row.
+func (b *Buffer) lineStart(row int) int {
+	b.rebuildIndex()
+	if row <= 0 {
+		return 0
+	}
+	if row > b.totalLines {
+		return b.totalBytes
+	}
+	// Find the piece holding the row-th newline.
+	i := sort.Search(len(b.pieces), func(i int) bool {
+		return b.pieceLines[i]+b.pieces[i].newlines >= row
+	})
+	p := b.pieces[i]
+	k := row - b.pieceLines[i]
+	nl := b.newlineIndex(p.src)
+	pos := nl[sort.SearchInts(nl, p.start)+k-1]
+	return b.pieceBytes[i] + (pos - p.start) + 1
+}
+
+// lineEnd returns the byte offset of the newline terminating row, or Len() for the last line.
+func (b *Buffer) lineEnd(row int) int {
+	if row+1 >= b.LineCount() {
+		return b.Len()
+	}
+	return b.lineStart(row+1) - 1
+}
+
+// substring returns the document bytes in [start, end).
+func (b *Buffer) substring(start, end int) string {
+	if start >= end {
+		return ""
+	}
+	i := b.pieceAt(start)
+	if i < len(b.pieces) {
+		p := b.pieces[i]
+		rel := start - b.pieceBytes[i]
+		if end-b.pieceBytes[i] <= p.length {
+			return b.text(p, rel, end-b.pieceBytes[i])
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(end - start)
+	for ; i < len(b.pieces) && b.pieceBytes[i] < end; i++ {
+		p := b.pieces[i]
+		from := max(start-b.pieceBytes[i], 0)
+		to := min(end-b.pieceBytes[i], p.length)
+		sb.WriteString(b.text(p, from, to))
+	}
+	return sb.String()
+}
+
+// Line returns the content of row without its line terminator.
+// Returns empty string if row is out of bounds.
+func (b *Buffer) Line(row int) string {
+	if row < 0 {
+		return ""
+	}
+	if b.loading != nil {
+		if line, ok := b.partialLine(row); ok {
+			return line
+		}
+	}
+	if row >= b.LineCount() {
+		return ""
+	}
+	return b.substring(b.lineStart(row), b.lineEnd(row))
+}
+
+// LineLen returns the length of row in runes.
+func (b *Buffer) LineLen(row int) int {
+	return utf8.RuneCountInString(b.Line(row))
+}
+
+// Lines returns all lines as a slice of strings.
+func (b *Buffer) Lines() []string {
+	return strings.Split(b.String(), "\n")
+}
+
+// String returns the whole document.
+func (b *Buffer) String() string {
+	return b.substring(0, b.Len())
+}
+
+// Offset converts a row/column position into a byte offset, clamping out of range values.
+func (b *Buffer) Offset(row, col int) int {
+	if row < 0 {
+		return 0
+	}
+	if row >= b.LineCount() {
+		return b.Len()
 	}
-	if col > len(b.Lines[line]) {
-		col = len(b.Lines[line])
+	start := b.lineStart(row)
+	line := b.substring(start, b.lineEnd(row))
+	n := 0
+	for i := range line {
+		if n == col {
+			return start + i
+		}
+		n++
 	}
+	return start + len(line)
+}
 
-	// Insert the rune at the specified column in the line
-	before := b.Lines[line][:col]
-	after := b.Lines[line][col:]
-	b.Lines[line] = append(before, append([]rune{r}, after...)...)
+// Position converts a byte offset into a row/column position.
+func (b *Buffer) Position(off int) (int, int) {
+	off = min(max(off, 0), b.Len())
+	i := b.pieceAt(off)
+	row := b.totalLines
+	if i < len(b.pieces) {
+		p := b.pieces[i]
+		row = b.pieceLines[i] + b.countNewlines(p.src, p.start, p.start+off-b.pieceBytes[i])
+	}
+	return row, utf8.RuneCountInString(b.substring(b.lineStart(row), off))
 }
 
-// DeleteRune deletes a rune at the specified position (line, col).
-// If out of bounds, does nothing.
-func (b *Buffer) DeleteRune(line, col int) {
-	if line < 0 || line >= len(b.Lines) || col < 0 || col >= len(b.Lines[line]) {
+// InsertAt inserts text at the byte offset off.
+func (b *Buffer) InsertAt(off int, text string) {
+	if text == "" {
 		return
 	}
+	off = min(max(off, 0), b.Len())
+
+	i := b.pieceAt(off)
+	b.indexDirty = true
+
+	addStart := len(b.add)
+	b.add = append(b.add, text...)
+	b.addNL = indexNewlines(text, addStart, b.addNL)
+	newPiece := b.makePiece(sourceAdd, addStart, len(text))
+
+	// Typing usually continues right after the previous insertion, so grow that piece instead of adding one.
+	if i > 0 {
+		prev := &b.pieces[i-1]
+		if prev.src == sourceAdd && prev.start+prev.length == addStart && b.pieceBytes[i-1]+prev.length == off {
+			prev.length += len(text)
+			prev.newlines += newPiece.newlines
+			return
+		}
+	}
 
-	// Remove the rune at the specified column
-	before := b.Lines[line][:col]
-	after := b.Lines[line][col+1:]
-	b.Lines[line] = append(before, after...)
+	if i == len(b.pieces) || b.pieceBytes[i] == off {
+		b.pieces = slices.Insert(b.pieces, i, newPiece)
+		return
+	}
+
+	p := b.pieces[i]
+	rel := off - b.pieceBytes[i]
+	left := b.makePiece(p.src, p.start, rel)
+	right := b.makePiece(p.src, p.start+rel, p.length-rel)
+	b.pieces = slices.Replace(b.pieces, i, i+1, left, newPiece, right)
 }
 
-// GetLine returns the string representation of a line at the given index.
-// Returns empty string if line is out of bounds.
-func (b *Buffer) GetLine(line int) string {
-	if line < 0 || line >= len(b.Lines) {
+// DeleteRange removes the bytes in [start, end) and returns them.
+func (b *Buffer) DeleteRange(start, end int) string {
+	start = min(max(start, 0), b.Len())
+	end = min(max(end, 0), b.Len())
+	if start >= end {
 		return ""
 	}
-	return string(b.Lines[line])
+	removed := b.substring(start, end)
+
+	first := b.pieceAt(start)
+	last := b.pieceAt(end - 1)
+	b.indexDirty = true
+
+	var repl []piece
+	fp := b.pieces[first]
+	if rel := start - b.pieceBytes[first]; rel > 0 {
+		repl = append(repl, b.makePiece(fp.src, fp.start, rel))
+	}
+	lp := b.pieces[last]
+	if rel := end - b.pieceBytes[last]; rel < lp.length {
+		repl = append(repl, b.makePiece(lp.src, lp.start+rel, lp.length-rel))
+	}
+	b.pieces = slices.Replace(b.pieces, first, last+1, repl...)
+	return removed
+}
+
+// Insert inserts text at row/col and returns the position right after the inserted text.
+func (b *Buffer) Insert(row, col int, text string) (int, int) {
+	off := b.Offset(row, col)
+	b.InsertAt(off, text)
+	return b.Position(off + len(text))
 }
 
-// GetAllLines returns all lines as a slice of strings.
-func (b *Buffer) GetAllLines() []string {
-	lines := make([]string, len(b.Lines))
-	for i, line := range b.Lines {
-		lines[i] = string(line)
+// Delete removes the text between two positions and returns it.
+// The positions may be given in any order.
+func (b *Buffer) Delete(startRow, startCol, endRow, endCol int) string {
+	start, end := b.Offset(startRow, startCol), b.Offset(endRow, endCol)
+	if start > end {
+		start, end = end, start
+	}
+	return b.DeleteRange(start, end)
+}
+
+// Slice returns the text between two positions without modifying the buffer.
+func (b *Buffer) Slice(startRow, startCol, endRow, endCol int) string {
+	start, end := b.Offset(startRow, startCol), b.Offset(endRow, endCol)
+	if start > end {
+		start, end = end, start
+	}
+	return b.substring(start, end)
+}
+
+// Snapshot returns a read-only copy of the buffer that stays valid while the
+// original keeps being edited, e.g. for searching in a background goroutine.
+func (b *Buffer) Snapshot() *Buffer {
+	b.rebuildIndex()
+	return &Buffer{
+		Format:     b.Format,
+		Stamp:      b.Stamp,
+		original:   b.original,
+		originalNL: b.originalNL,
+		add:        b.add[:len(b.add):len(b.add)],
+		addNL:      b.addNL[:len(b.addNL):len(b.addNL)],
+		pieces:     slices.Clone(b.pieces),
+		pieceBytes: slices.Clone(b.pieceBytes),
+		pieceLines: slices.Clone(b.pieceLines),
+		totalBytes: b.totalBytes,
+		totalLines: b.totalLines,
 	}
-	return lines
 }
@@ -0,0 +1,135 @@
+package buffer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNewBufferHasOneLine(t *testing.T) {
+	b := NewBuffer()
+	if b.LineCount() != 1 {
+		t.Fatalf("expected 1 line, got %d", b.LineCount())
+	}
+	if b.Line(0) != "" {
+		t.Errorf("expected empty line, got %q", b.Line(0))
+	}
+}
+
+func TestLineAccess(t *testing.T) {
+	b := New("first\nsecond\n\nfourth")
+	want := []string{"first", "second", "", "fourth"}
+	if b.LineCount() != len(want) {
+		t.Fatalf("expected %d lines, got %d", len(want), b.LineCount())
+	}
+	for i, w := range want {
+		if got := b.Line(i); got != w {
+			t.Errorf("Line(%d) = %q, want %q", i, got, w)
+		}
+	}
+	if got := b.Line(10); got != "" {
+		t.Errorf("out of range line should be empty, got %q", got)
+	}
+}
+
+func TestInsertAndDelete(t *testing.T) {
+	b := New("hello world")
+
+	row, col := b.Insert(0, 5, ",\nbig")
+	if row != 1 || col != 3 {
+		t.Errorf("Insert returned (%d, %d), want (1, 3)", row, col)
+	}
+	if got := b.String(); got != "hello,\nbig world" {
+		t.Fatalf("unexpected content after insert: %q", got)
+	}
+
+	removed := b.Delete(0, 5, 1, 3)
+	if removed != ",\nbig" {
+		t.Errorf("Delete returned %q", removed)
+	}
+	if got := b.String(); got != "hello world" {
+		t.Errorf("unexpected content after delete: %q", got)
+	}
+}
+
+func TestRuneColumns(t *testing.T) {
+	b := New("héllo\nwörld")
+	b.Insert(1, 2, "X")
+	if got := b.Line(1); got != "wöXrld" {
+		t.Errorf("unexpected line %q", got)
+	}
+	if got := b.LineLen(0); got != 5 {
+		t.Errorf("LineLen(0) = %d, want 5", got)
+	}
+	if got := b.Slice(0, 1, 0, 3); got != "él" {
+		t.Errorf("Slice = %q, want %q", got, "él")
+	}
+}
+
+func TestSnapshotIsIndependent(t *testing.T) {
+	b := New("one\ntwo")
+	snap := b.Snapshot()
+	b.Insert(0, 0, "zero\n")
+	if snap.LineCount() != 2 || snap.Line(0) != "one" {
+		t.Errorf("snapshot changed after edit: %q", snap.String())
+	}
+	if b.Line(0) != "zero" {
+		t.Errorf("buffer not edited: %q", b.String())
+	}
+}
+
+// TestRandomEdits compares the piece table against a plain string.
+func TestRandomEdits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "\n", "é", "xyz", "\n\n"}
+
+	doc := "line one\nline two\nline three\n"
+	b := New(doc)
+
+	for i := 0; i < 2000; i++ {
+		if rng.Intn(3) > 0 || len(doc) == 0 {
+			off := rng.Intn(len(doc) + 1)
+			for off > 0 && off < len(doc) && doc[off]&0xC0 == 0x80 {
+				off--
+			}
+			text := alphabet[rng.Intn(len(alphabet))]
+			b.InsertAt(off, text)
+			doc = doc[:off] + text + doc[off:]
+		} else {
+			start := rng.Intn(len(doc))
+			for start > 0 && doc[start]&0xC0 == 0x80 {
+				start--
+			}
+			end := start + rng.Intn(len(doc)-start+1)
+			for end < len(doc) && doc[end]&0xC0 == 0x80 {
+				end++
+			}
+			b.DeleteRange(start, end)
+			doc = doc[:start] + doc[end:]
+		}
+
+		if b.String() != doc {
+			t.Fatalf("step %d: content mismatch\n got %q\nwant %q", i, b.String(), doc)
+		}
+		lines := strings.Split(doc, "\n")
+		if b.LineCount() != len(lines) {
+			t.Fatalf("step %d: LineCount = %d, want %d", i, b.LineCount(), len(lines))
+		}
+		row := rng.Intn(len(lines))
+		if b.Line(row) != lines[row] {
+			t.Fatalf("step %d: Line(%d) = %q, want %q", i, row, b.Line(row), lines[row])
+		}
+	}
+}
+
+func TestOffsetPositionRoundTrip(t *testing.T) {
+	b := New("ab\ncdé\n\nf")
+	for row := 0; row < b.LineCount(); row++ {
+		for col := 0; col <= b.LineLen(row); col++ {
+			r, c := b.Position(b.Offset(row, col))
+			if r != row || c != col {
+				t.Errorf("Position(Offset(%d, %d)) = (%d, %d)", row, col, r, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a file on disk.
+// The buffer itself always holds UTF-8 text.
+type Encoding int
+
+const (
+	UTF8 Encoding = iota
+	UTF16LE
+	UTF16BE
+	Latin1
+	Windows1252
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case UTF16LE:
+		return "UTF-16LE"
+	case UTF16BE:
+		return "UTF-16BE"
+	case Latin1:
+		return "ISO-8859-1"
+	case Windows1252:
+		return "Windows-1252"
+	default:
+		return "UTF-8"
+	}
+}
+
+// ParseEncoding returns the encoding for a user supplied name such as
+// "utf-16le", "latin1" or "cp1252".
+func ParseEncoding(name string) (Encoding, error) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	n = strings.NewReplacer("-", "", "_", "", " ", "").Replace(n)
+	switch n {
+	case "utf8":
+		return UTF8, nil
+	case "utf16", "utf16le":
+		return UTF16LE, nil
+	case "utf16be":
+		return UTF16BE, nil
+	case "latin1", "iso88591", "l1":
+		return Latin1, nil
+	case "windows1252", "cp1252", "win1252":
+		return Windows1252, nil
+	}
+	return UTF8, fmt.Errorf("unknown encoding %q", name)
+}
+
+// bom returns the byte order mark of the encoding, if it has one.
+func (e Encoding) bom() string {
+	switch e {
+	case UTF8:
+		return utf8BOM
+	case UTF16LE:
+		return "\xff\xfe"
+	case UTF16BE:
+		return "\xfe\xff"
+	}
+	return ""
+}
+
+// windows1252High maps the bytes 0x80-0x9F to runes. Unassigned bytes map to
+// the matching C1 control character so every byte round-trips.
+var windows1252High = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// decode converts raw bytes (without BOM) in encoding e to UTF-8.
+func (e Encoding) decode(raw string) string {
+	switch e {
+	case UTF16LE, UTF16BE:
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 0; i+1 < len(raw); i += 2 {
+			if e == UTF16LE {
+				units = append(units, uint16(raw[i])|uint16(raw[i+1])<<8)
+			} else {
+				units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+			}
+		}
+		s := string(utf16.Decode(units))
+		if len(raw)%2 == 1 {
+			s += string(utf8.RuneError)
+		}
+		return s
+	case Latin1, Windows1252:
+		var sb strings.Builder
+		sb.Grow(len(raw))
+		for i := 0; i < len(raw); i++ {
+			c := raw[i]
+			if e == Windows1252 && c >= 0x80 && c < 0xA0 {
+				sb.WriteRune(windows1252High[c-0x80])
+			} else {
+				sb.WriteRune(rune(c))
+			}
+		}
+		return sb.String()
+	default:
+		return raw
+	}
+}
+
+// encode converts UTF-8 text to encoding e. It fails if the text contains
+// characters the encoding cannot represent.
+func (e Encoding) encode(text string) ([]byte, error) {
+	switch e {
+	case UTF16LE, UTF16BE:
+		units := utf16.Encode([]rune(text))
+		out := make([]byte, 0, len(units)*2)
+		for _, u := range units {
+			if e == UTF16LE {
+				out = append(out, byte(u), byte(u>>8))
+			} else {
+				out = append(out, byte(u>>8), byte(u))
+			}
+		}
+		return out, nil
+	case Latin1, Windows1252:
+		out := make([]byte, 0, len(text))
+		for _, r := range text {
+			c, ok := e.encodeRune(r)
+			if !ok {
+				return nil, fmt.Errorf("character %q cannot be encoded in %s", r, e)
+			}
+			out = append(out, c)
+		}
+		return out, nil
+	default:
+		return []byte(text), nil
+	}
+}
+
+func (e Encoding) encodeRune(r rune) (byte, bool) {
+	if e == Windows1252 {
+		if r >= 0x80 && r < 0xA0 {
+			// C1 controls are only valid where Windows-1252 leaves the byte unassigned.
+			return byte(r), windows1252High[r-0x80] == r
+		}
+		for i, hr := range windows1252High {
+			if hr == r {
+				return byte(0x80 + i), true
+			}
+		}
+	}
+	if r < 0x100 {
+		return byte(r), true
+	}
+	return 0, false
+}
+
+// sniffEncoding guesses the encoding of raw file content. It reports whether
+// the content starts with the byte order mark of that encoding.
+func sniffEncoding(raw string) (Encoding, bool) {
+	for _, e := range []Encoding{UTF8, UTF16LE, UTF16BE} {
+		if strings.HasPrefix(raw, e.bom()) {
+			return e, true
+		}
+	}
+
+	// Mostly-ASCII UTF-16 text has a zero in every other byte.
+	sample := raw[:min(len(raw), 4096)]
+	if len(sample) >= 4 {
+		evenZeros, oddZeros := 0, 0
+		for i := 0; i < len(sample); i++ {
+			if sample[i] == 0 {
+				if i%2 == 0 {
+					evenZeros++
+				} else {
+					oddZeros++
+				}
+			}
+		}
+		pairs := len(sample) / 2
+		if oddZeros > pairs/2 && evenZeros <= pairs/10 {
+			return UTF16LE, false
+		}
+		if evenZeros > pairs/2 && oddZeros <= pairs/10 {
+			return UTF16BE, false
+		}
+	}
+
+	if utf8.ValidString(raw) {
+		return UTF8, false
+	}
+	for i := 0; i < len(raw); i++ {
+		if raw[i] >= 0x80 && raw[i] < 0xA0 {
+			return Windows1252, false
+		}
+	}
+	return Latin1, false
+}
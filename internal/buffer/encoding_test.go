@@ -0,0 +1,96 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodingDetection(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   string
+		enc   Encoding
+		bom   bool
+		first string
+	}{
+		{"utf-8", "héllo\nworld\n", UTF8, false, "héllo"},
+		{"utf-16le bom", "\xff\xfeh\x00i\x00\r\x00\n\x00", UTF16LE, true, "hi"},
+		{"utf-16be bom", "\xfe\xff\x00h\x00i", UTF16BE, true, "hi"},
+		{"utf-16le no bom", "a\x00,\x00b\x00\n\x00c\x00", UTF16LE, false, "a,b"},
+		{"latin-1", "caf\xe9\n", Latin1, false, "café"},
+		{"windows-1252", "\x93quoted\x94 \x80\n", Windows1252, false, "“quoted” €"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.txt")
+			if err := os.WriteFile(path, []byte(tt.raw), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			b, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if b.Format.Encoding != tt.enc || b.Format.BOM != tt.bom {
+				t.Errorf("got %s BOM=%v, want %s BOM=%v", b.Format.Encoding, b.Format.BOM, tt.enc, tt.bom)
+			}
+			if got := b.Line(0); got != tt.first {
+				t.Errorf("Line(0) = %q, want %q", got, tt.first)
+			}
+
+			data, err := b.Encode()
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if string(data) != tt.raw {
+				t.Errorf("round trip changed the file: got %q, want %q", data, tt.raw)
+			}
+		})
+	}
+}
+
+func TestEncodeUnrepresentable(t *testing.T) {
+	b := New("snowman ☃")
+	b.Format.SetEncoding(Latin1)
+	if _, err := b.Encode(); err == nil {
+		t.Errorf("expected an error encoding ☃ in %s", Latin1)
+	}
+}
+
+func TestLoadWithEncoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("caf\xc3\xa9"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	b, err := LoadWithEncoding(path, Latin1)
+	if err != nil {
+		t.Fatalf("LoadWithEncoding failed: %v", err)
+	}
+	if got := b.Line(0); got != "cafÃ©" {
+		t.Errorf("Line(0) = %q", got)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := map[string]Encoding{
+		"utf-8":        UTF8,
+		"UTF-16LE":     UTF16LE,
+		"utf_16be":     UTF16BE,
+		"latin1":       Latin1,
+		"ISO-8859-1":   Latin1,
+		"cp1252":       Windows1252,
+		"Windows-1252": Windows1252,
+	}
+	for name, want := range tests {
+		got, err := ParseEncoding(name)
+		if err != nil || got != want {
+			t.Errorf("ParseEncoding(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseEncoding("ebcdic"); err == nil {
+		t.Errorf("expected an error for an unknown encoding")
+	}
+}
@@ -0,0 +1,140 @@
+package buffer
+
+import (
+	"strings"
+)
+
+// LineEnding is the line terminator used by a file on disk.
+// The buffer itself always stores lines separated by '\n'.
+type LineEnding int
+
+const (
+	LF LineEnding = iota
+	CRLF
+)
+
+func (le LineEnding) String() string {
+	if le == CRLF {
+		return "CRLF"
+	}
+	return "LF"
+}
+
+// Sequence returns the bytes written for a line break.
+func (le LineEnding) Sequence() string {
+	if le == CRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// NormalizeLineBreaks turns the line breaks of text coming from outside,
+// such as a paste, into the '\n' stored by the buffer, whether they are
+// "\r\n" or a lone '\r'. Encode writes them in the line ending of the file.
+func NormalizeLineBreaks(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+const utf8BOM = "\xef\xbb\xbf"
+
+// Format describes how a document is laid out on disk, so it can be written
+// back exactly the way it was read.
+type Format struct {
+	Encoding     Encoding
+	LineEnding   LineEnding
+	FinalNewline bool // the file ends with a line break
+	BOM          bool // the file starts with the byte order mark of its encoding
+}
+
+// DefaultFormat is used for new files.
+func DefaultFormat() Format {
+	return Format{Encoding: UTF8, LineEnding: LF, FinalNewline: true}
+}
+
+// SetEncoding changes the encoding used when saving. UTF-16 files get a byte
+// order mark, single byte encodings have none.
+func (f *Format) SetEncoding(e Encoding) {
+	f.Encoding = e
+	switch e {
+	case UTF16LE, UTF16BE:
+		f.BOM = true
+	case Latin1, Windows1252:
+		f.BOM = false
+	}
+}
+
+// String returns a short description for the status bar, e.g. "UTF-8 CRLF BOM".
+func (f Format) String() string {
+	parts := []string{f.Encoding.String(), f.LineEnding.String()}
+	if f.BOM {
+		parts = append(parts, "BOM")
+	}
+	if !f.FinalNewline {
+		parts = append(parts, "noeol")
+	}
+	return strings.Join(parts, " ")
+}
+
+// detectFormat inspects raw file content and returns it decoded to UTF-8,
+// normalized to '\n' line breaks, without BOM and without the final line
+// break, together with the detected format. If enc is nil the encoding is
+// sniffed from the content.
+func detectFormat(raw string, enc *Encoding) (string, Format) {
+	var f Format
+
+	if enc == nil {
+		f.Encoding, f.BOM = sniffEncoding(raw)
+	} else {
+		f.Encoding = *enc
+		f.BOM = f.Encoding.bom() != "" && strings.HasPrefix(raw, f.Encoding.bom())
+	}
+	if f.BOM {
+		raw = raw[len(f.Encoding.bom()):]
+	}
+	raw = f.Encoding.decode(raw)
+
+	// Only a file with CRLF line breaks throughout is normalized. In a file
+	// with both, the '\r' stays in the text, so that it is written back as
+	// it was read.
+	crlf := strings.Count(raw, "\r\n")
+	lf := strings.Count(raw, "\n") - crlf
+	if crlf > 0 && lf == 0 {
+		f.LineEnding = CRLF
+		raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	}
+
+	if strings.HasSuffix(raw, "\n") {
+		f.FinalNewline = true
+		raw = raw[:len(raw)-1]
+	}
+
+	return raw, f
+}
+
+// Encode returns the document in its on-disk format. It fails if the text
+// cannot be represented in the encoding of the format.
+func (b *Buffer) Encode() ([]byte, error) {
+	content := b.String()
+	sep := b.Format.LineEnding.Sequence()
+
+	var sb strings.Builder
+	sb.Grow(len(content) + b.LineCount()*(len(sep)-1) + len(sep))
+	if b.Format.LineEnding == LF {
+		sb.WriteString(content)
+	} else {
+		sb.WriteString(strings.ReplaceAll(content, "\n", sep))
+	}
+	if b.Format.FinalNewline {
+		sb.WriteString(sep)
+	}
+
+	data, err := b.Format.Encoding.encode(sb.String())
+	if err != nil {
+		return nil, err
+	}
+	if b.Format.BOM {
+		data = append([]byte(b.Format.Encoding.bom()), data...)
+	}
+	return data, nil
+}
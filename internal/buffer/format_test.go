@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Format
+		lines int
+	}{
+		{"lf", "a\nb\n", Format{Encoding: UTF8, LineEnding: LF, FinalNewline: true}, 2},
+		{"crlf", "a\r\nb\r\n", Format{Encoding: UTF8, LineEnding: CRLF, FinalNewline: true}, 2},
+		{"no final newline", "a\nb", Format{Encoding: UTF8, LineEnding: LF}, 2},
+		{"bom", "\xef\xbb\xbfa\r\nb", Format{Encoding: UTF8, LineEnding: CRLF, BOM: true}, 2},
+		{"mostly crlf", "a\r\nb\nc\r\n", Format{Encoding: UTF8, LineEnding: LF, FinalNewline: true}, 3},
+		{"mostly lf", "a\nb\r\nc\n", Format{Encoding: UTF8, LineEnding: LF, FinalNewline: true}, 3},
+		{"empty", "", Format{Encoding: UTF8, LineEnding: LF}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.txt")
+			if err := os.WriteFile(path, []byte(tt.input), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			b, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if b.Format != tt.want {
+				t.Errorf("Format = %+v, want %+v", b.Format, tt.want)
+			}
+			if b.LineCount() != tt.lines {
+				t.Errorf("LineCount = %d, want %d", b.LineCount(), tt.lines)
+			}
+			data, err := b.Encode()
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if got := string(data); got != tt.input {
+				t.Errorf("Encode() = %q, want %q", got, tt.input)
+			}
+		})
+	}
+}
+
+func TestConvertLineEnding(t *testing.T) {
+	b := New("a\nb")
+	b.Format = Format{Encoding: UTF8, LineEnding: CRLF, FinalNewline: true}
+	data, err := b.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if got := string(data); got != "a\r\nb\r\n" {
+		t.Errorf("Encode() = %q", got)
+	}
+}
+
+func TestNormalizeLineBreaks(t *testing.T) {
+	b := New("")
+	b.Format = Format{Encoding: UTF8, LineEnding: CRLF}
+	b.Insert(0, 0, NormalizeLineBreaks("a\r\nb\rc\nd"))
+	if got := b.String(); got != "a\nb\nc\nd" {
+		t.Errorf("String() = %q", got)
+	}
+	data, err := b.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if got := string(data); got != "a\r\nb\r\nc\r\nd" {
+		t.Errorf("Encode() = %q", got)
+	}
+}
@@ -0,0 +1,216 @@
+package buffer
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// backgroundIndexThreshold is the file size above which the line index is
+// built in a background goroutine instead of before Load returns.
+const backgroundIndexThreshold = 1 << 20
+
+// indexChunkSize is how many bytes the background indexer scans before
+// publishing the newlines it found.
+const indexChunkSize = 1 << 20
+
+// loadState tracks the background indexing of the original content.
+type loadState struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	nl    []int
+	done  bool
+	ready chan struct{} // closed once done is set
+}
+
+// Load reads the file at path into a new buffer.
+// The encoding is detected from the byte order mark or the content and the
+// text is decoded to UTF-8. The encoding, line endings, the final line break
+// and the BOM are recorded in the buffer Format. The file is streamed in chunks without any line length limit. For large
+// files the line index is built in the background: the first lines are
+// available right away and Loaded reports when the whole file is indexed.
+func Load(path string) (*Buffer, error) {
+	return load(path, nil, nil)
+}
+
+// LoadWithEncoding is like Load but decodes the file with enc instead of
+// detecting the encoding.
+func LoadWithEncoding(path string, enc Encoding) (*Buffer, error) {
+	return load(path, &enc, nil)
+}
+
+// Progress is told how many bytes of a file of size bytes were read so far.
+type Progress func(read, size int64)
+
+// LoadProgress is like Load but calls progress after every read, so
+// that a caller loading a large file in the background can show how far it
+// got.
+func LoadProgress(path string, progress Progress) (*Buffer, error) {
+	return load(path, nil, progress)
+}
+
+// progressReader reports the bytes read through it to progress.
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	size     int64
+	progress Progress
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.read += int64(n)
+	if n > 0 {
+		pr.progress(pr.read, pr.size)
+	}
+	return n, err
+}
+
+func load(path string, enc *Encoding, progress Progress) (*Buffer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = f
+	if progress != nil {
+		r = &progressReader{r: f, size: info.Size(), progress: progress}
+	}
+
+	var sb strings.Builder
+	sb.Grow(int(info.Size()))
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(&sb, h), r); err != nil {
+		return nil, err
+	}
+
+	content, format := detectFormat(sb.String(), enc)
+
+	var b *Buffer
+	if len(content) < backgroundIndexThreshold {
+		b = New(content)
+	} else {
+		b = newIndexedInBackground(content)
+	}
+	b.Format = format
+	b.Stamp = Stamp{ModTime: info.ModTime(), Size: info.Size()}
+	h.Sum(b.Stamp.Hash[:0])
+	return b, nil
+}
+
+func newIndexedInBackground(content string) *Buffer {
+	l := &loadState{ready: make(chan struct{})}
+	l.cond = sync.NewCond(&l.mu)
+
+	b := &Buffer{
+		original:   content,
+		pieces:     []piece{{src: sourceOriginal, start: 0, length: len(content)}},
+		indexDirty: true,
+		loading:    l,
+	}
+
+	go func() {
+		var nl []int
+		for off := 0; off < len(content); off += indexChunkSize {
+			end := min(off+indexChunkSize, len(content))
+			nl = indexNewlines(content[off:end], off, nl)
+
+			l.mu.Lock()
+			l.nl = nl
+			l.cond.Broadcast()
+			l.mu.Unlock()
+		}
+
+		l.mu.Lock()
+		l.nl = nl
+		l.done = true
+		l.cond.Broadcast()
+		l.mu.Unlock()
+		close(l.ready)
+	}()
+
+	return b
+}
+
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// Loaded reports whether the line index of the buffer is complete.
+func (b *Buffer) Loaded() bool {
+	select {
+	case <-b.Ready():
+		return true
+	default:
+		return false
+	}
+}
+
+// Ready returns a channel that is closed once the line index of the buffer is
+// complete. The channel may be waited on from any goroutine.
+func (b *Buffer) Ready() <-chan struct{} {
+	if b.loading == nil {
+		return closedChan
+	}
+	return b.loading.ready
+}
+
+// finishLoading waits for the background indexer and installs its result.
+func (b *Buffer) finishLoading() {
+	l := b.loading
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	for !l.done {
+		l.cond.Wait()
+	}
+	nl := l.nl
+	l.mu.Unlock()
+
+	b.originalNL = nl
+	b.pieces[0].newlines = len(nl)
+	b.indexDirty = true
+	b.loading = nil
+}
+
+// partialLineCount returns the number of lines indexed so far and whether
+// indexing is still running.
+func (b *Buffer) partialLineCount() (int, bool) {
+	l := b.loading
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done {
+		return 0, false
+	}
+	return max(len(l.nl), 1), true
+}
+
+// partialLine returns row while indexing is still running, waiting for the
+// indexer to reach it if needed.
+func (b *Buffer) partialLine(row int) (string, bool) {
+	l := b.loading
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.nl) <= row && !l.done {
+		l.cond.Wait()
+	}
+	if l.done {
+		return "", false
+	}
+	start := 0
+	if row > 0 {
+		start = l.nl[row-1] + 1
+	}
+	return b.original[start:l.nl[row]], true
+}
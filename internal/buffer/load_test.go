@@ -0,0 +1,109 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSmallFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "small.txt")
+	if err := os.WriteFile(path, []byte("alpha\nbeta"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	b, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !b.Loaded() {
+		t.Errorf("small files should be indexed synchronously")
+	}
+	if b.LineCount() != 2 || b.Line(1) != "beta" {
+		t.Errorf("unexpected content %q", b.String())
+	}
+}
+
+func TestLoadLongLine(t *testing.T) {
+	long := strings.Repeat("x", 3<<20)
+	path := filepath.Join(t.TempDir(), "long.json")
+	if err := os.WriteFile(path, []byte(long+"\nend"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	b, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := b.Line(0); len(got) != len(long) {
+		t.Errorf("expected a %d byte line, got %d bytes", len(long), len(got))
+	}
+	<-b.Ready()
+	if b.LineCount() != 2 || b.Line(1) != "end" {
+		t.Errorf("unexpected last line %q", b.Line(1))
+	}
+}
+
+func TestLoadBackgroundIndex(t *testing.T) {
+	var sb strings.Builder
+	for sb.Len() < 4*backgroundIndexThreshold {
+		sb.WriteString("some generated line of text\n")
+	}
+	content := sb.String()
+	path := filepath.Join(t.TempDir(), "big.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	b, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := b.Line(0); got != "some generated line of text" {
+		t.Errorf("unexpected first line %q", got)
+	}
+
+	// Editing waits for the index to complete.
+	b.Insert(0, 0, "header\n")
+	// The final line break is recorded in Format rather than kept as an empty line.
+	want := strings.Count(content, "\n") + 1
+	if b.LineCount() != want {
+		t.Errorf("LineCount = %d, want %d", b.LineCount(), want)
+	}
+	if b.Line(0) != "header" {
+		t.Errorf("unexpected first line after insert %q", b.Line(0))
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.txt"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+}
+
+func TestLoadProgress(t *testing.T) {
+	content := strings.Repeat("0123456789abcdef\n", 3*indexChunkSize/17)
+	path := filepath.Join(t.TempDir(), "big.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	var reads []int64
+	b, err := LoadProgress(path, func(read, size int64) {
+		if size != int64(len(content)) {
+			t.Errorf("size = %d, want %d", size, len(content))
+		}
+		reads = append(reads, read)
+	})
+	if err != nil {
+		t.Fatalf("LoadProgress failed: %v", err)
+	}
+	if len(reads) < 3 || reads[len(reads)-1] != int64(len(content)) {
+		t.Errorf("expected progress for every chunk up to the whole file, got %v", reads)
+	}
+	if b.Len() != len(content)-1 {
+		t.Errorf("unexpected length %d", b.Len())
+	}
+}
@@ -0,0 +1,143 @@
+package buffer
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// maxSymlinkDepth bounds symlink resolution so link loops fail instead of spinning.
+const maxSymlinkDepth = 40
+
+// SaveOptions controls how files are written.
+type SaveOptions struct {
+	// Backup keeps the previous content of the file as path + "~".
+	Backup bool
+	// Mode is the permissions of a new file, 0644 if zero. An existing file
+	// keeps its own.
+	Mode os.FileMode
+}
+
+// Save encodes the buffer in its Format and writes it to path, see WriteFile.
+// On success the Stamp is updated to the written file.
+func (b *Buffer) Save(path string, opts SaveOptions) error {
+	data, err := b.Encode()
+	if err != nil {
+		return err
+	}
+	if err := WriteFile(path, data, opts); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		b.Stamp = Stamp{ModTime: info.ModTime(), Size: info.Size(), Hash: sha256.Sum256(data)}
+	}
+	return nil
+}
+
+// WriteFile atomically replaces the file at path with data.
+// The data is written to a temporary file in the same directory, synced to
+// disk and renamed over the target, so a crash or a full disk never leaves a
+// half-written file behind. Symlinks are followed so the link itself is kept,
+// and the mode and owner of an existing file are preserved.
+func WriteFile(path string, data []byte, opts SaveOptions) error {
+	target, err := resolveSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if opts.Mode != 0 {
+		mode = opts.Mode
+	}
+	info, err := os.Stat(target)
+	exists := err == nil
+	if exists {
+		mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".larry-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return err
+	}
+	if exists {
+		preserveOwner(tmpName, info)
+	}
+
+	if exists && opts.Backup {
+		if err := writeBackup(target, mode); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpName, target); err != nil {
+		return err
+	}
+	committed = true
+	syncDir(dir)
+	return nil
+}
+
+// resolveSymlinks follows path through any symlinks to the file that should
+// actually be written. The final target does not need to exist yet.
+func resolveSymlinks(path string) (string, error) {
+	for i := 0; i < maxSymlinkDepth; i++ {
+		info, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+		link, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = link
+	}
+	return "", errors.New("too many levels of symbolic links: " + path)
+}
+
+// writeBackup copies the current content of path to path + "~".
+func writeBackup(path string, mode os.FileMode) error {
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	backup := path + "~"
+	if err := os.WriteFile(backup, old, mode); err != nil {
+		return err
+	}
+	return os.Chmod(backup, mode)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package buffer
+
+import "os"
+
+func preserveOwner(path string, info os.FileInfo) {}
+
+func syncDir(dir string) {}
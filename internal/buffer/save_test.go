@@ -0,0 +1,110 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFilePreservesMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := WriteFile(path, []byte("#!/bin/sh\necho hi\n"), SaveOptions{}); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "#!/bin/sh\necho hi\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestWriteFileFollowsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Symlink("real.txt", link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := WriteFile(link, []byte("new"), SaveOptions{}); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link was replaced by a regular file")
+	}
+	content, _ := os.ReadFile(target)
+	if string(content) != "new" {
+		t.Errorf("target content = %q, want %q", content, "new")
+	}
+}
+
+func TestWriteFileBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("v1"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := WriteFile(path, []byte("v2"), SaveOptions{Backup: true}); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	backup, err := os.ReadFile(path + "~")
+	if err != nil {
+		t.Fatalf("backup missing: %v", err)
+	}
+	if string(backup) != "v1" {
+		t.Errorf("backup content = %q, want %q", backup, "v1")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected only the file and its backup, got %d entries", len(entries))
+	}
+}
+
+func TestWriteFileNewMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := WriteFile(path, []byte("x"), SaveOptions{Mode: 0600}); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestSaveNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.txt")
+	b := NewBuffer()
+	b.Insert(0, 0, "hello")
+	if err := b.Save(path, SaveOptions{Backup: true}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "hello\n" {
+		t.Errorf("content = %q, want %q", content, "hello\n")
+	}
+	if _, err := os.Stat(path + "~"); !os.IsNotExist(err) {
+		t.Errorf("no backup expected for a new file")
+	}
+}
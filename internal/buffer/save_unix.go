@@ -0,0 +1,26 @@
+//go:build unix
+
+package buffer
+
+import (
+	"os"
+	"syscall"
+)
+
+// preserveOwner gives path the owner and group of info. Failing is not an
+// error: only root may give a file away, everyone else keeps their own.
+func preserveOwner(path string, info os.FileInfo) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Chown(path, int(st.Uid), int(st.Gid))
+	}
+}
+
+// syncDir flushes the directory entry of a rename to disk.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
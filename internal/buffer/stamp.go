@@ -0,0 +1,55 @@
+package buffer
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"time"
+)
+
+// Stamp identifies the version of a file on disk that a buffer was loaded
+// from or last saved to, so changes made by other programs can be noticed.
+type Stamp struct {
+	ModTime time.Time
+	Size    int64
+	Hash    [sha256.Size]byte
+}
+
+// IsZero reports whether the stamp is unset, e.g. for a buffer that was never
+// read from or written to disk.
+func (s Stamp) IsZero() bool {
+	return s == Stamp{}
+}
+
+// Equal reports whether s and o describe the same file version.
+func (s Stamp) Equal(o Stamp) bool {
+	return s.ModTime.Equal(o.ModTime) && s.Size == o.Size && s.Hash == o.Hash
+}
+
+// Changed reports whether the file at path differs from the version described
+// by s and returns the stamp of the file as it is now. The content is only
+// hashed when modification time or size differ, so a file that was merely
+// touched does not count as changed.
+func (s Stamp) Changed(path string) (Stamp, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Stamp{}, false, err
+	}
+	if info.ModTime().Equal(s.ModTime) && info.Size() == s.Size {
+		return s, false, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Stamp{}, false, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return Stamp{}, false, err
+	}
+
+	cur := Stamp{ModTime: info.ModTime(), Size: info.Size()}
+	h.Sum(cur.Hash[:0])
+	return cur, cur.Hash != s.Hash || s.IsZero(), nil
+}
@@ -0,0 +1,65 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStampChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	b, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if _, changed, err := b.Stamp.Changed(path); err != nil || changed {
+		t.Fatalf("fresh file reported as changed (err %v)", err)
+	}
+
+	// Touching the file without changing it is not a change.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	if _, changed, _ := b.Stamp.Changed(path); changed {
+		t.Errorf("touched file reported as changed")
+	}
+
+	if err := os.WriteFile(path, []byte("two\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	cur, changed, err := b.Stamp.Changed(path)
+	if err != nil || !changed {
+		t.Fatalf("rewritten file not reported as changed (err %v)", err)
+	}
+	if _, changed, _ := cur.Changed(path); changed {
+		t.Errorf("returned stamp does not match the current file")
+	}
+}
+
+func TestSaveUpdatesStamp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	b := New("hello")
+	b.Format = DefaultFormat()
+	if err := b.Save(path, SaveOptions{}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if b.Stamp.IsZero() {
+		t.Fatalf("Save did not set the stamp")
+	}
+	if _, changed, _ := b.Stamp.Changed(path); changed {
+		t.Errorf("saved file reported as changed")
+	}
+}
+
+func TestStampMissingFile(t *testing.T) {
+	var s Stamp
+	if _, _, err := s.Changed(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package clipboard gives the editor a system clipboard that works in as
+// many environments as possible. Providers, such as the native clipboard of
+// the desktop or an OSC 52 escape sequence to the terminal, are tried in a
+// configurable order, and an in-process one keeps copy and paste working
+// inside the editor when no other is available.
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrUnavailable is returned by a provider that can't be used here, e.g. the
+// native clipboard without a display.
+var ErrUnavailable = errors.New("not available")
+
+// Provider is a way to reach a clipboard.
+type Provider interface {
+	Name() string
+	Write(text string) error
+	Read() (string, error)
+}
+
+// Names lists the providers New knows, in the default order.
+var Names = []string{"native", "commands", "osc52", "internal"}
+
+// Clipboard tries its providers in order: Write stops at the first one that
+// succeeds and so does Read.
+type Clipboard struct {
+	providers []Provider
+}
+
+// New returns a clipboard using the providers named in order, see Names.
+// OSC 52 sequences are written to term. Unknown names are reported and
+// skipped, and without any provider the clipboard is kept in-process.
+func New(order []string, term io.Writer) (*Clipboard, error) {
+	var providers []Provider
+	var unknown []string
+	for _, name := range order {
+		switch name {
+		case "native":
+			providers = append(providers, Native{})
+		case "commands":
+			providers = append(providers, Commands{})
+		case "osc52":
+			providers = append(providers, NewOSC52(term))
+		case "internal":
+			providers = append(providers, &Memory{})
+		default:
+			unknown = append(unknown, fmt.Sprintf("%q", name))
+		}
+	}
+	if len(providers) == 0 {
+		providers = append(providers, &Memory{})
+	}
+
+	c := &Clipboard{providers: providers}
+	if len(unknown) > 0 {
+		return c, fmt.Errorf("unknown clipboard provider %s, use %s", strings.Join(unknown, ", "), strings.Join(Names, ", "))
+	}
+	return c, nil
+}
+
+// NewWith returns a clipboard using providers in order.
+func NewWith(providers ...Provider) *Clipboard {
+	return &Clipboard{providers: providers}
+}
+
+// Write puts text on the clipboard. The error lists why every provider
+// failed.
+func (c *Clipboard) Write(text string) error {
+	var errs []error
+	for _, p := range c.providers {
+		err := p.Write(text)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return joinErrors(errs)
+}
+
+// Read returns the text on the clipboard.
+func (c *Clipboard) Read() (string, error) {
+	var errs []error
+	for _, p := range c.providers {
+		text, err := p.Read()
+		if err == nil {
+			return text, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return "", joinErrors(errs)
+}
+
+// joinErrors joins errs on one line, for the status bar.
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return errors.New("no clipboard provider")
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Memory is a clipboard inside the editor, shared by its buffers but not
+// with other programs.
+type Memory struct {
+	text string
+}
+
+func (m *Memory) Name() string { return "internal" }
+
+func (m *Memory) Write(text string) error {
+	m.text = text
+	return nil
+}
+
+func (m *Memory) Read() (string, error) {
+	return m.text, nil
+}
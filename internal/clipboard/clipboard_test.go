@@ -0,0 +1,152 @@
+package clipboard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type failing struct{ name string }
+
+func (f failing) Name() string          { return f.name }
+func (f failing) Write(string) error    { return ErrUnavailable }
+func (f failing) Read() (string, error) { return "", ErrUnavailable }
+
+func TestClipboardFallsBack(t *testing.T) {
+	first, second := &Memory{}, &Memory{}
+	c := NewWith(failing{"broken"}, first, second)
+
+	if err := c.Write("hello"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if first.text != "hello" || second.text != "" {
+		t.Errorf("expected only the first working provider to be written, got %q and %q", first.text, second.text)
+	}
+	text, err := c.Read()
+	if err != nil || text != "hello" {
+		t.Errorf("Read = %q, %v, want hello", text, err)
+	}
+}
+
+func TestClipboardReportsEveryProvider(t *testing.T) {
+	c := NewWith(failing{"one"}, failing{"two"})
+
+	err := c.Write("x")
+	if err == nil {
+		t.Fatal("expected an error when no provider works")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "one: not available") || !strings.Contains(msg, "two: not available") {
+		t.Errorf("error should name every provider, got %q", msg)
+	}
+	if _, err := c.Read(); err == nil {
+		t.Error("expected a read error when no provider works")
+	}
+}
+
+func TestNew(t *testing.T) {
+	var term strings.Builder
+	c, err := New([]string{"osc52", "internal"}, &term)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if len(c.providers) != 2 || c.providers[0].Name() != "osc52" || c.providers[1].Name() != "internal" {
+		t.Errorf("unexpected providers %v", c.providers)
+	}
+
+	c, err = New([]string{"xsel", "internal"}, &term)
+	if err == nil || !strings.Contains(err.Error(), `"xsel"`) {
+		t.Errorf("expected an error naming the unknown provider, got %v", err)
+	}
+	if len(c.providers) != 1 || c.providers[0].Name() != "internal" {
+		t.Errorf("unknown providers should be skipped, got %v", c.providers)
+	}
+
+	c, _ = New(nil, &term)
+	if err := c.Write("kept"); err != nil {
+		t.Errorf("a clipboard without providers should keep text in-process, got %v", err)
+	}
+	if text, _ := c.Read(); text != "kept" {
+		t.Errorf("Read = %q, want kept", text)
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	t.Setenv("TMUX", "")
+	var term strings.Builder
+	o := NewOSC52(&term)
+
+	if err := o.Write("hi there"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if want := "\x1b]52;c;aGkgdGhlcmU=\a"; term.String() != want {
+		t.Errorf("wrote %q, want %q", term.String(), want)
+	}
+	if text, err := o.Read(); err != nil || text != "hi there" {
+		t.Errorf("Read = %q, %v, want the text last written", text, err)
+	}
+}
+
+func TestOSC52Tmux(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+	var term strings.Builder
+	o := NewOSC52(&term)
+
+	if err := o.Write("hi"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if want := "\x1bPtmux;\x1b\x1b]52;c;aGk=\a\x1b\\"; term.String() != want {
+		t.Errorf("wrote %q, want %q", term.String(), want)
+	}
+}
+
+type brokenWriter struct{}
+
+func (brokenWriter) Write([]byte) (int, error) { return 0, errors.New("closed") }
+
+func TestOSC52WriteError(t *testing.T) {
+	o := NewOSC52(brokenWriter{})
+	if err := o.Write("lost"); err == nil {
+		t.Fatal("expected the write error")
+	}
+	if text, _ := o.Read(); text != "" {
+		t.Errorf("text that wasn't written should not be read back, got %q", text)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "larry", "clipboard.json")
+
+	entries, err := LoadHistory(path)
+	if err != nil || entries != nil {
+		t.Fatalf("expected no history before the first save, got %q, %v", entries, err)
+	}
+
+	want := []string{"first", "two\nlines\n", ""}
+	if err := SaveHistory(path, want); err != nil {
+		t.Fatalf("SaveHistory failed: %v", err)
+	}
+	entries, err = LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("LoadHistory = %q, want %q", entries, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("history file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHistory(path); err == nil {
+		t.Error("expected an error for a corrupt history file")
+	}
+}
@@ -0,0 +1,71 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Commands uses the clipboard tools of the system: pbcopy and pbpaste on
+// macOS, xclip or wl-copy and wl-paste elsewhere.
+type Commands struct{}
+
+func (Commands) Name() string { return "commands" }
+
+func (Commands) Write(text string) error {
+	var errs []error
+	for _, args := range writeCommands() {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, commandError(args[0], err, stderr.String()))
+			continue
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func (Commands) Read() (string, error) {
+	var errs []error
+	for _, args := range readCommands() {
+		cmd := exec.Command(args[0], args[1:]...)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			errs = append(errs, commandError(args[0], err, stderr.String()))
+			continue
+		}
+		return string(out), nil
+	}
+	return "", errors.Join(errs...)
+}
+
+func writeCommands() [][]string {
+	if runtime.GOOS == "darwin" {
+		return [][]string{{"pbcopy"}}
+	}
+	return [][]string{{"xclip", "-selection", "clipboard", "-in"}, {"wl-copy"}}
+}
+
+func readCommands() [][]string {
+	if runtime.GOOS == "darwin" {
+		return [][]string{{"pbpaste"}}
+	}
+	return [][]string{{"xclip", "-selection", "clipboard", "-out"}, {"wl-paste", "--no-newline"}}
+}
+
+func commandError(name string, err error, stderr string) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%s not installed", name)
+	}
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Errorf("%s: %s", name, stderr)
+	}
+	return fmt.Errorf("%s: %v", name, err)
+}
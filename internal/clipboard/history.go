@@ -0,0 +1,51 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"larry/internal/buffer"
+)
+
+// HistoryPath returns the file keeping the clipboard history between
+// sessions, under the user cache directory.
+func HistoryPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "larry", "clipboard.json"), nil
+}
+
+// SaveHistory stores the texts of the clipboard history, oldest first, in
+// the file at path. A new file is only readable by the user, since the
+// history may hold passwords and the like.
+func SaveHistory(path string, entries []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return buffer.WriteFile(path, data, buffer.SaveOptions{Mode: 0600})
+}
+
+// LoadHistory returns the clipboard history stored by SaveHistory, or none
+// if there is no file at path.
+func LoadHistory(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
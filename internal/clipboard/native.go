@@ -0,0 +1,35 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.design/x/clipboard"
+)
+
+// Native uses the clipboard of the desktop through its system library:
+// X11 on Linux, which needs a display, and the pasteboard on macOS.
+type Native struct{}
+
+func (Native) Name() string { return "native" }
+
+func (Native) Write(text string) error {
+	if err := clipboard.Init(); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if clipboard.Write(clipboard.FmtText, []byte(text)) == nil {
+		return errors.New("write failed")
+	}
+	return nil
+}
+
+func (Native) Read() (string, error) {
+	if err := clipboard.Init(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	data := clipboard.Read(clipboard.FmtText)
+	if data == nil {
+		return "", errors.New("no text on the clipboard")
+	}
+	return string(data), nil
+}
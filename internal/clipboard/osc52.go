@@ -0,0 +1,48 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+)
+
+// OSC52 asks the terminal to put text on the clipboard with an OSC 52
+// escape sequence. This works over SSH and in containers, as long as the
+// terminal supports it, and inside tmux with allow-passthrough set. Terminals
+// don't reliably answer reads, so Read returns the text last written.
+type OSC52 struct {
+	term io.Writer
+	tmux bool
+	last string
+}
+
+// NewOSC52 returns a provider writing to term, wrapping the sequence for
+// tmux if the editor runs in it.
+func NewOSC52(term io.Writer) *OSC52 {
+	return &OSC52{term: term, tmux: os.Getenv("TMUX") != ""}
+}
+
+func (o *OSC52) Name() string { return "osc52" }
+
+func (o *OSC52) Write(text string) error {
+	if _, err := io.WriteString(o.term, o.sequence(text)); err != nil {
+		return err
+	}
+	o.last = text
+	return nil
+}
+
+func (o *OSC52) Read() (string, error) {
+	return o.last, nil
+}
+
+// sequence returns the escape sequence setting the clipboard to text.
+func (o *OSC52) sequence(text string) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if o.tmux {
+		// tmux passes on a DCS sequence with its escapes doubled.
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
@@ -2,15 +2,46 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+
+	"larry/internal/keymap"
 )
 
+// ErrKeybindings is returned by LoadConfig if the keymap or the keybindings
+// section is invalid. The rest of the config is still used, with the default
+// keys.
+var ErrKeybindings = errors.New("invalid keybindings")
+
 type Config struct {
 	Theme       string `json:"theme"`
 	TabWidth    int    `json:"tab_width"`
 	LineNumbers bool   `json:"line_numbers"`
 	LeaderKey   string `json:"leader_key"`
+	Backup      bool   `json:"backup"`
+	Swap        bool   `json:"swap"`
+	UndoFile    bool   `json:"undo_file"`
+	Mouse       bool   `json:"mouse"`
+
+	// Clipboard lists the clipboard providers to try, in order, see
+	// clipboard.Names.
+	Clipboard []string `json:"clipboard"`
+
+	// ClipboardHistory is how many copied, cut and killed texts are kept
+	// for the clipboard history and the kill ring, at least 1.
+	ClipboardHistory int `json:"clipboard_history"`
+
+	// SaveClipboardHistory keeps the clipboard history across sessions.
+	SaveClipboardHistory bool `json:"save_clipboard_history"`
+
+	// Keymap is "default", "vim", which adds modal editing on top of the
+	// default keys, or "emacs", which uses the keys of keymap.Presets.
+	Keymap string `json:"keymap"`
+
+	// Keybindings maps action names to keys, replacing their defaults.
+	Keybindings map[string]keymap.Keys `json:"keybindings"`
 }
 
 func DefaultConfig() Config {
@@ -19,6 +50,15 @@ func DefaultConfig() Config {
 		TabWidth:    4,
 		LineNumbers: true,
 		LeaderKey:   "ctrl",
+		Backup:      false,
+		Swap:        true,
+		UndoFile:    true,
+		Mouse:       true,
+		Clipboard:   []string{"native", "commands", "osc52", "internal"},
+		Keymap:      "default",
+
+		ClipboardHistory:     60,
+		SaveClipboardHistory: false,
 	}
 }
 
@@ -59,5 +99,20 @@ func LoadConfig(path string) (Config, error) {
 		return DefaultConfig(), err
 	}
 
+	var errs []error
+	switch cfg.Keymap {
+	case "default", "vim", "emacs":
+	default:
+		errs = append(errs, fmt.Errorf("unknown keymap %q, use default, vim or emacs", cfg.Keymap))
+		cfg.Keymap = "default"
+	}
+	if _, err := keymap.Resolve(cfg.LeaderKey, cfg.Keymap, cfg.Keybindings); err != nil {
+		errs = append(errs, err)
+		cfg.Keybindings = nil
+	}
+	if len(errs) > 0 {
+		return cfg, fmt.Errorf("%w: %w", ErrKeybindings, errors.Join(errs...))
+	}
+
 	return cfg, nil
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -67,3 +70,96 @@ func TestLoadConfig_ExplicitPath(t *testing.T) {
 		t.Errorf("expected theme nord, got %s", cfg.Theme)
 	}
 }
+
+func TestLoadConfig_Keybindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"tab_width": 2, "keybindings": {"save": "ctrl+w", "close_buffer": ["leader+shift+w"]}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if got := cfg.Keybindings["save"]; len(got) != 1 || got[0] != "ctrl+w" {
+		t.Errorf("expected save bound to ctrl+w, got %q", got)
+	}
+}
+
+func TestLoadConfig_InvalidKeybindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"tab_width": 2, "keybindings": {"save": "ctrl+f", "frobnicate": "ctrl+y"}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if !errors.Is(err, ErrKeybindings) {
+		t.Fatalf("expected ErrKeybindings, got %v", err)
+	}
+	for _, want := range []string{`unknown action "frobnicate"`, `"ctrl+f" is bound to both "save" and "search"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got %v", want, err)
+		}
+	}
+	if cfg.Keybindings != nil {
+		t.Errorf("expected keybindings to be dropped, got %v", cfg.Keybindings)
+	}
+	if cfg.TabWidth != 2 {
+		t.Errorf("expected the rest of the config to be kept, got tab_width %d", cfg.TabWidth)
+	}
+}
+
+func TestLoadConfig_UnknownKeymap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"keymap": "nano", "tab_width": 2}`), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if !errors.Is(err, ErrKeybindings) || !strings.Contains(err.Error(), `unknown keymap "nano"`) {
+		t.Fatalf("expected an unknown keymap error, got %v", err)
+	}
+	if cfg.Keymap != "default" || cfg.TabWidth != 2 {
+		t.Errorf("expected the default keymap and the rest of the config, got %q and tab_width %d", cfg.Keymap, cfg.TabWidth)
+	}
+}
+
+func TestLoadConfig_EmacsKeymap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	// ctrl+k is free with the default keys, but kills the line with emacs ones.
+	content := `{"keymap": "emacs", "keybindings": {"save": "ctrl+k"}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if !errors.Is(err, ErrKeybindings) || !strings.Contains(err.Error(), `"ctrl+k" is bound to both "save" and "kill_line"`) {
+		t.Fatalf("expected a conflict with the emacs keys, got %v", err)
+	}
+	if cfg.Keymap != "emacs" {
+		t.Errorf("expected the emacs keymap to be kept, got %q", cfg.Keymap)
+	}
+}
+
+func TestLoadConfig_Clipboard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"clipboard": ["osc52", "internal"], "clipboard_history": 10, "save_clipboard_history": true}`), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if !slices.Equal(cfg.Clipboard, []string{"osc52", "internal"}) {
+		t.Errorf("expected the configured providers, got %q", cfg.Clipboard)
+	}
+	if cfg.ClipboardHistory != 10 || !cfg.SaveClipboardHistory {
+		t.Errorf("expected a saved history of 10 entries, got %d, %v", cfg.ClipboardHistory, cfg.SaveClipboardHistory)
+	}
+	if got := DefaultConfig().Clipboard; !slices.Equal(got, []string{"native", "commands", "osc52", "internal"}) {
+		t.Errorf("unexpected default providers %q", got)
+	}
+}
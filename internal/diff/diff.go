@@ -0,0 +1,205 @@
+// Package diff computes line based differences between two versions of a text,
+// e.g. to compare a buffer against the file on disk before overwriting it.
+package diff
+
+import (
+	"fmt"
+	"slices"
+)
+
+// maxEdits bounds the work done by the Myers algorithm. Texts further apart
+// than this are reported as one big replacement.
+const maxEdits = 1000
+
+type Kind int
+
+const (
+	Equal Kind = iota
+	Insert
+	Delete
+)
+
+// Line is one line of a diff.
+type Line struct {
+	Kind Kind
+	Text string
+}
+
+// Lines returns the edit script turning a into b.
+func Lines(a, b []string) []Line {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []Line
+	for _, l := range a[:prefix] {
+		out = append(out, Line{Equal, l})
+	}
+	out = append(out, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, Line{Equal, l})
+	}
+	return out
+}
+
+// myers implements the O(ND) difference algorithm by Eugene W. Myers.
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replace(a, b)
+	}
+
+	maxD := n + m
+	v := make([]int, 2*maxD+2)
+	// trace[d] holds v for k in [-d, d] as it was before round d.
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		if d > maxEdits {
+			return replace(a, b)
+		}
+		trace = append(trace, slices.Clone(v[maxD-d:maxD+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[maxD+k-1] < v[maxD+k+1]) {
+				x = v[maxD+k+1]
+			} else {
+				x = v[maxD+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[maxD+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return replace(a, b)
+}
+
+func backtrack(trace [][]int, a, b []string) []Line {
+	var out []Line
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		w := trace[d]
+		get := func(k int) int { return w[k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := get(prevK)
+		prevY := prevX - prevK
+
+		// The edit moved down (insert) or right (delete), then followed a snake of equal lines.
+		insert := prevK == k+1
+		snakeX := prevX + 1
+		if insert {
+			snakeX = prevX
+		}
+		for x > snakeX {
+			out = append(out, Line{Equal, a[x-1]})
+			x--
+			y--
+		}
+
+		if insert {
+			out = append(out, Line{Insert, b[prevY]})
+		} else {
+			out = append(out, Line{Delete, a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		out = append(out, Line{Equal, a[x-1]})
+		x--
+		y--
+	}
+	slices.Reverse(out)
+	return out
+}
+
+func replace(a, b []string) []Line {
+	out := make([]Line, 0, len(a)+len(b))
+	for _, l := range a {
+		out = append(out, Line{Delete, l})
+	}
+	for _, l := range b {
+		out = append(out, Line{Insert, l})
+	}
+	return out
+}
+
+// Unified renders a diff between a and b in unified format with the given
+// number of context lines around each change. It returns nil if a and b are equal.
+func Unified(a, b []string, context int) []string {
+	lines := Lines(a, b)
+
+	var out []string
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == Equal {
+			i++
+			continue
+		}
+
+		// Grow the hunk until the gap to the next change exceeds the context.
+		start := max(i-context, 0)
+		end := i
+		for end < len(lines) {
+			if lines[end].Kind != Equal {
+				end++
+				continue
+			}
+			gap := end
+			for gap < len(lines) && lines[gap].Kind == Equal {
+				gap++
+			}
+			if gap == len(lines) || gap-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = gap
+		}
+
+		aStart, bStart := 0, 0
+		for _, l := range lines[:start] {
+			if l.Kind != Insert {
+				aStart++
+			}
+			if l.Kind != Delete {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		var body []string
+		for _, l := range lines[start:end] {
+			switch l.Kind {
+			case Equal:
+				aLen++
+				bLen++
+				body = append(body, " "+l.Text)
+			case Delete:
+				aLen++
+				body = append(body, "-"+l.Text)
+			case Insert:
+				bLen++
+				body = append(body, "+"+l.Text)
+			}
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart+1, aLen, bStart+1, bLen))
+		out = append(out, body...)
+		i = end
+	}
+	return out
+}
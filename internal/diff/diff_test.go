@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// apply rebuilds both sides from an edit script.
+func apply(lines []Line) ([]string, []string) {
+	var a, b []string
+	for _, l := range lines {
+		if l.Kind != Insert {
+			a = append(a, l.Text)
+		}
+		if l.Kind != Delete {
+			b = append(b, l.Text)
+		}
+	}
+	return a, b
+}
+
+func TestLines(t *testing.T) {
+	a := []string{"one", "two", "three", "four"}
+	b := []string{"one", "2", "three", "four", "five"}
+
+	got := Lines(a, b)
+	want := []Line{
+		{Equal, "one"},
+		{Delete, "two"},
+		{Insert, "2"},
+		{Equal, "three"},
+		{Equal, "four"},
+		{Insert, "five"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d lines, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLinesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d"}
+	gen := func() []string {
+		out := make([]string, rng.Intn(20))
+		for i := range out {
+			out[i] = words[rng.Intn(len(words))]
+		}
+		return out
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := gen(), gen()
+		gotA, gotB := apply(Lines(a, b))
+		if strings.Join(gotA, ",") != strings.Join(a, ",") || strings.Join(gotB, ",") != strings.Join(b, ",") {
+			t.Fatalf("script does not rebuild the inputs:\na=%v\nb=%v", a, b)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	b := []string{"1", "2", "3", "4", "five", "6", "7", "8", "9"}
+
+	got := strings.Join(Unified(a, b, 1), "\n")
+	want := "@@ -4,3 +4,3 @@\n 4\n-5\n+five\n 6"
+	if got != want {
+		t.Errorf("Unified =\n%s\nwant\n%s", got, want)
+	}
+
+	if Unified(a, a, 3) != nil {
+		t.Errorf("expected no hunks for equal input")
+	}
+}
@@ -0,0 +1,331 @@
+// Package keymap holds the editor's actions with their default keys and
+// resolves the keybindings section of the config against them.
+package keymap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Leader stands for the leader key at the start of a key string, e.g.
+// "leader+s" is "ctrl+s" with the default leader.
+const Leader = "leader"
+
+// Action is something a key can be bound to.
+type Action struct {
+	Name  string   // used in the config, e.g. "save"
+	Group string   // section of the help menu
+	Help  string   // help menu label, shared by actions shown on one line
+	Keys  []string // default keys
+}
+
+// Actions lists every action in the order of the help menu.
+var Actions = []Action{
+	{"quit", "General", "Quit", []string{"leader+q"}},
+	{"save_all_quit", "General", "Save All & Quit", []string{"leader+]"}},
+	{"save", "General", "Save", []string{"leader+s"}},
+	{"open", "General", "Open File", []string{"leader+o"}},
+	{"go_to_line", "General", "Go to Line", []string{"leader+g"}},
+	{"search", "General", "Search", []string{"leader+f"}},
+	{"replace", "General", "Replace", []string{"leader+t"}},
+	{"global_finder", "General", "Global Finder", []string{"leader+p"}},
+	{"toggle_help", "General", "Toggle Help", []string{"leader+h"}},
+	{"markdown_preview", "General", "Markdown Preview", []string{"leader+u"}},
+	{"toggle_line_ending", "General", "Toggle LF/CRLF", []string{"leader+e"}},
+	{"reopen_with_encoding", "General", "Reopen w/ Encoding", []string{"leader+l"}},
+	{"save_with_encoding", "General", "Save w/ Encoding", []string{"leader+y"}},
+
+	{"undo", "Editing", "Undo", []string{"leader+z"}},
+	{"redo", "Editing", "Redo", []string{"leader+r"}},
+	{"earlier", "Editing", "Earlier/Later State", []string{"alt+-"}},
+	{"later", "Editing", "Earlier/Later State", []string{"alt+="}},
+	{"undo_history", "Editing", "Undo History", []string{"alt+/"}},
+	{"copy", "Editing", "Copy", []string{"leader+c"}},
+	{"paste", "Editing", "Paste", []string{"leader+v"}},
+	{"cut", "Editing", "Cut", []string{"leader+x"}},
+	{"select_all", "Editing", "Select All", []string{"leader+a"}},
+	{"delete", "Editing", "Delete", []string{"backspace", "delete"}},
+	{"set_mark", "Editing", "Set Mark", nil},
+	{"kill_line", "Editing", "Kill Line", nil},
+	{"yank_pop", "Editing", "Paste Older Kill", nil},
+	{"copy_to_register", "Editing", "Copy/Paste Register", []string{"alt+'"}},
+	{"paste_from_register", "Editing", "Copy/Paste Register", []string{`alt+"`}},
+	{"clipboard_history", "Editing", "Clipboard History", []string{"alt+ctrl+v"}},
+
+	{"cursor_left", "Navigation", "Move Cursor", []string{"left"}},
+	{"cursor_right", "Navigation", "Move Cursor", []string{"right"}},
+	{"cursor_up", "Navigation", "Move Cursor", []string{"up"}},
+	{"cursor_down", "Navigation", "Move Cursor", []string{"down"}},
+	{"jump_word_left", "Navigation", "Jump Word", []string{"leader+left"}},
+	{"jump_word_right", "Navigation", "Jump Word", []string{"leader+right"}},
+	{"jump_lines_up", "Navigation", "Jump 5 Lines", []string{"leader+up"}},
+	{"jump_lines_down", "Navigation", "Jump 5 Lines", []string{"leader+down"}},
+	{"line_start", "Navigation", "Line Start", []string{"home"}},
+	{"line_end", "Navigation", "Line End", []string{"end"}},
+	{"file_start", "Navigation", "File Start", []string{"leader+home"}},
+	{"file_end", "Navigation", "File End", []string{"leader+end"}},
+
+	{"select_left", "Selection", "Select Text", []string{"shift+left"}},
+	{"select_right", "Selection", "Select Text", []string{"shift+right"}},
+	{"select_up", "Selection", "Select Text", []string{"shift+up"}},
+	{"select_down", "Selection", "Select Text", []string{"shift+down"}},
+	{"select_word_left", "Selection", "Select Word", []string{"leader+shift+left"}},
+	{"select_word_right", "Selection", "Select Word", []string{"leader+shift+right"}},
+	{"select_lines_up", "Selection", "Select Lines", []string{"leader+shift+up"}},
+	{"select_lines_down", "Selection", "Select Lines", []string{"leader+shift+down"}},
+	{"select_to_line_start", "Selection", "Select to Start/End", []string{"shift+home"}},
+	{"select_to_line_end", "Selection", "Select to Start/End", []string{"shift+end"}},
+	{"block_select_left", "Selection", "Block Select", []string{"alt+leader+shift+left"}},
+	{"block_select_right", "Selection", "Block Select", []string{"alt+leader+shift+right"}},
+	{"block_select_up", "Selection", "Block Select", []string{"alt+leader+shift+up"}},
+	{"block_select_down", "Selection", "Block Select", []string{"alt+leader+shift+down"}},
+	{"add_cursor_above", "Selection", "Add Cursor Above/Below", []string{"alt+ctrl+up"}},
+	{"add_cursor_below", "Selection", "Add Cursor Above/Below", []string{"alt+ctrl+down"}},
+	{"add_next_occurrence", "Selection", "Add Next Occurrence", []string{"alt+ctrl+n"}},
+	{"select_all_matches", "Selection", "Cursors on All Matches", []string{"alt+enter"}},
+
+	{"search_regex", "Search Options", "Regular Expressions", []string{"alt+ctrl+r"}},
+	{"search_ignore_case", "Search Options", "Ignore Case", []string{"alt+ctrl+c"}},
+	{"search_smart_case", "Search Options", "Smart Case", []string{"alt+ctrl+s"}},
+	{"search_whole_word", "Search Options", "Whole Words", []string{"alt+ctrl+w"}},
+
+	{"next_buffer", "Buffers & Panes", "Next/Prev Buffer", []string{"leader+pgdown"}},
+	{"prev_buffer", "Buffers & Panes", "Next/Prev Buffer", []string{"leader+pgup"}},
+	{"buffer_list", "Buffers & Panes", "Buffer List", []string{"leader+b"}},
+	{"close_buffer", "Buffers & Panes", "Close Buffer", []string{"leader+w"}},
+	{"split_vertical", "Buffers & Panes", "Split Side by Side", []string{"leader+\\"}},
+	{"split_horizontal", "Buffers & Panes", "Split Below", []string{"leader+_"}},
+	{"next_pane", "Buffers & Panes", "Next Pane", []string{"leader+n"}},
+	{"close_pane", "Buffers & Panes", "Close Pane", []string{"leader+d"}},
+	{"grow_pane", "Buffers & Panes", "Grow/Shrink Pane", []string{"leader+k"}},
+	{"shrink_pane", "Buffers & Panes", "Grow/Shrink Pane", []string{"leader+j"}},
+}
+
+// Presets holds the keys of the keymaps other than the default one, for the
+// actions whose keys they change.
+var Presets = map[string]map[string][]string{
+	"emacs": {
+		"quit":                 {"ctrl+x ctrl+c"},
+		"save_all_quit":        {"ctrl+x s"},
+		"save":                 {"ctrl+x ctrl+s"},
+		"open":                 {"ctrl+x ctrl+f"},
+		"go_to_line":           {"alt+g g", "alt+g alt+g"},
+		"search":               {"ctrl+s"},
+		"replace":              {"alt+%"},
+		"global_finder":        {"ctrl+x p"},
+		"toggle_help":          {"f1"},
+		"markdown_preview":     {"ctrl+c m"},
+		"toggle_line_ending":   {"ctrl+c e"},
+		"reopen_with_encoding": {"ctrl+x enter r"},
+		"save_with_encoding":   {"ctrl+x enter f"},
+
+		"undo":       {"ctrl+_", "ctrl+x u"},
+		"redo":       {"alt+_"},
+		"copy":       {"alt+w"},
+		"paste":      {"ctrl+y"},
+		"cut":        {"ctrl+w"},
+		"select_all": {"ctrl+x h"},
+		"set_mark":   {"ctrl+@"},
+		"kill_line":  {"ctrl+k"},
+		"yank_pop":   {"alt+y"},
+
+		"copy_to_register":    {"ctrl+x r s"},
+		"paste_from_register": {"ctrl+x r i"},
+
+		"cursor_left":     {"left", "ctrl+b"},
+		"cursor_right":    {"right", "ctrl+f"},
+		"cursor_up":       {"up", "ctrl+p"},
+		"cursor_down":     {"down", "ctrl+n"},
+		"jump_word_left":  {"ctrl+left", "alt+b"},
+		"jump_word_right": {"ctrl+right", "alt+f"},
+		"jump_lines_up":   {"ctrl+up", "alt+v"},
+		"jump_lines_down": {"ctrl+down", "ctrl+v"},
+		"line_start":      {"home", "ctrl+a"},
+		"line_end":        {"end", "ctrl+e"},
+		"file_start":      {"ctrl+home", "alt+<"},
+		"file_end":        {"ctrl+end", "alt+>"},
+
+		"select_word_left":  {"ctrl+shift+left"},
+		"select_word_right": {"ctrl+shift+right"},
+		"select_lines_up":   {"ctrl+shift+up"},
+		"select_lines_down": {"ctrl+shift+down"},
+
+		"next_buffer":      {"ctrl+x right"},
+		"prev_buffer":      {"ctrl+x left"},
+		"buffer_list":      {"ctrl+x b"},
+		"close_buffer":     {"ctrl+x k"},
+		"split_vertical":   {"ctrl+x 3"},
+		"split_horizontal": {"ctrl+x 2"},
+		"next_pane":        {"ctrl+x o"},
+		"close_pane":       {"ctrl+x 0"},
+		"grow_pane":        {"ctrl+x ^"},
+		"shrink_pane":      {"ctrl+x -"},
+	},
+}
+
+// Keys is the keys of one action in the config. It is written as a single
+// key string or a list of them; an empty list unbinds the action. A key can
+// be a sequence of keys pressed one after the other, separated by spaces,
+// e.g. "leader+k leader+c".
+type Keys []string
+
+func (k *Keys) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*k = Keys{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("keys must be a string or a list of strings")
+	}
+	*k = many
+	return nil
+}
+
+// Map is the effective keys of every action, by action name.
+type Map map[string][]string
+
+// Resolve returns the keys of every action: the ones in overrides, or the
+// defaults of the preset keymap for actions not in there, with the leader
+// filled in. Actions the preset leaves out, or all of them if it is not in
+// Presets, keep their keys in Actions. Resolve reports unknown actions,
+// malformed keys, keys bound to more than one action and keys that are bound
+// but also start a sequence; the returned map then holds the defaults only.
+func Resolve(leader, preset string, overrides map[string]Keys) (Map, error) {
+	defaults := make(Map, len(Actions))
+	for _, a := range Actions {
+		keys := a.Keys
+		if p, ok := Presets[preset][a.Name]; ok {
+			keys = p
+		}
+		defaults[a.Name] = expand(leader, keys)
+	}
+	if len(overrides) == 0 {
+		return defaults, nil
+	}
+
+	var errs []error
+	known := make(map[string]bool, len(Actions))
+	for _, a := range Actions {
+		known[a.Name] = true
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			errs = append(errs, fmt.Errorf("unknown action %q", name))
+			continue
+		}
+		for _, k := range overrides[name] {
+			if err := check(leader, k); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+
+	m := make(Map, len(Actions))
+	owner := map[string]string{}
+	for _, a := range Actions {
+		keys := defaults[a.Name]
+		if o, ok := overrides[a.Name]; ok {
+			keys = expand(leader, o)
+		}
+		m[a.Name] = keys
+		for _, k := range keys {
+			if prev, ok := owner[k]; ok && prev != a.Name {
+				errs = append(errs, fmt.Errorf("key %q is bound to both %q and %q", k, prev, a.Name))
+				continue
+			}
+			owner[k] = a.Name
+		}
+	}
+	for _, a := range Actions {
+		for _, k := range m[a.Name] {
+			for i := range len(k) {
+				if k[i] != ' ' {
+					continue
+				}
+				if prev, ok := owner[k[:i]]; ok {
+					errs = append(errs, fmt.Errorf("key %q of %q starts the sequence %q of %q", k[:i], prev, k, a.Name))
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return defaults, errors.Join(errs...)
+	}
+	return m, nil
+}
+
+// check reports a key string that can never match a key press.
+func check(leader, k string) error {
+	if k == "" {
+		return errors.New("empty key")
+	}
+	for _, part := range strings.Split(k, " ") {
+		if part == "" {
+			return fmt.Errorf("key %q has extra spaces", k)
+		}
+		mods, base := split(part)
+		if base == "" {
+			return fmt.Errorf("key %q has no key after its modifiers", k)
+		}
+		for _, mod := range mods {
+			switch mod {
+			case "ctrl", "alt", "shift", Leader, leader:
+			default:
+				return fmt.Errorf("key %q has unknown modifier %q, use ctrl, alt, shift or leader", k, mod)
+			}
+		}
+	}
+	return nil
+}
+
+// split splits a key string into its modifiers and the key itself, e.g.
+// "ctrl+shift+up" into ctrl, shift and up.
+func split(k string) (mods []string, base string) {
+	i := strings.LastIndex(k, "+")
+	if i > 0 && i == len(k)-1 && k[i-1] == '+' {
+		// The key is + itself, as in "ctrl++".
+		i--
+	}
+	if i <= 0 {
+		return nil, k
+	}
+	return strings.Split(k[:i], "+"), k[i+1:]
+}
+
+// expand fills in the leader, wherever it is among the modifiers, and drops
+// duplicate keys.
+func expand(leader string, keys []string) []string {
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts := strings.Split(k, " ")
+		for i, part := range parts {
+			mods, base := split(part)
+			if !slices.Contains(mods, Leader) {
+				continue
+			}
+			for j, mod := range mods {
+				if mod == Leader {
+					mods[j] = leader
+				}
+			}
+			parts[i] = strings.Join(append(mods, base), "+")
+		}
+		k = strings.Join(parts, " ")
+		if !slices.Contains(out, k) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
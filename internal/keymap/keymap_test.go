@@ -0,0 +1,188 @@
+package keymap
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDefaultsHaveNoConflicts(t *testing.T) {
+	for _, leader := range []string{"ctrl", "alt"} {
+		m, err := Resolve(leader, "default", map[string]Keys{"save": {"leader+s"}})
+		if err != nil {
+			t.Errorf("leader %s: %v", leader, err)
+		}
+		if got := m["save"]; !slices.Equal(got, []string{leader + "+s"}) {
+			t.Errorf("leader %s: save = %q", leader, got)
+		}
+	}
+}
+
+func TestPresets(t *testing.T) {
+	known := map[string]bool{}
+	for _, a := range Actions {
+		known[a.Name] = true
+	}
+	for name, preset := range Presets {
+		for action, keys := range preset {
+			if !known[action] {
+				t.Errorf("%s: unknown action %q", name, action)
+			}
+			for _, k := range keys {
+				if err := check("ctrl", k); err != nil {
+					t.Errorf("%s: %s: %v", name, action, err)
+				}
+			}
+		}
+		for _, leader := range []string{"ctrl", "alt"} {
+			// An override makes Resolve check the keys for conflicts.
+			if _, err := Resolve(leader, name, map[string]Keys{"quit": preset["quit"]}); err != nil {
+				t.Errorf("%s with leader %s: %v", name, leader, err)
+			}
+		}
+	}
+
+	m, err := Resolve("ctrl", "emacs", map[string]Keys{"kill_line": {"ctrl+k", "alt+k"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m["cut"]; !slices.Equal(got, []string{"ctrl+w"}) {
+		t.Errorf("cut = %q", got)
+	}
+	if got := m["kill_line"]; !slices.Equal(got, []string{"ctrl+k", "alt+k"}) {
+		t.Errorf("kill_line = %q", got)
+	}
+	if got := m["add_cursor_above"]; !slices.Equal(got, []string{"alt+ctrl+up"}) {
+		t.Errorf("add_cursor_above = %q, want the default", got)
+	}
+}
+
+func TestResolveOverrides(t *testing.T) {
+	m, err := Resolve("ctrl", "default", map[string]Keys{
+		"save":          {"ctrl+w", "f2"},
+		"close_buffer":  {"leader+shift+w"},
+		"global_finder": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m["save"]; !slices.Equal(got, []string{"ctrl+w", "f2"}) {
+		t.Errorf("save = %q", got)
+	}
+	if got := m["close_buffer"]; !slices.Equal(got, []string{"ctrl+shift+w"}) {
+		t.Errorf("close_buffer = %q", got)
+	}
+	if got := m["global_finder"]; len(got) != 0 {
+		t.Errorf("global_finder = %q, want unbound", got)
+	}
+	if got := m["quit"]; !slices.Equal(got, []string{"ctrl+q"}) {
+		t.Errorf("quit = %q, want the default", got)
+	}
+}
+
+func TestLeaderAmongModifiers(t *testing.T) {
+	for leader, want := range map[string]string{"ctrl": "alt+ctrl+shift+left", "cmd": "alt+cmd+shift+left"} {
+		m, err := Resolve(leader, "default", map[string]Keys{"block_select_up": {"alt+leader+shift+up"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m["block_select_left"]; !slices.Equal(got, []string{want}) {
+			t.Errorf("leader %s: block_select_left = %q, want %q", leader, got, want)
+		}
+		if got := m["block_select_up"]; !slices.Equal(got, []string{"alt+" + leader + "+shift+up"}) {
+			t.Errorf("leader %s: block_select_up = %q", leader, got)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		overrides map[string]Keys
+		want      string
+	}{
+		{map[string]Keys{"sav": {"ctrl+s"}}, `unknown action "sav"`},
+		{map[string]Keys{"save": {"ctrl+f"}}, `key "ctrl+f" is bound to both "save" and "search"`},
+		{map[string]Keys{"save": {"Ctrl+s"}}, `unknown modifier "Ctrl"`},
+		{map[string]Keys{"save": {"ctrl+"}}, `no key after its modifiers`},
+		{map[string]Keys{"save": {""}}, `empty key`},
+		{map[string]Keys{"save": {"ctrl+k  s"}}, `extra spaces`},
+		{map[string]Keys{"save": {"ctrl+k s"}}, `key "ctrl+k" of "grow_pane" starts the sequence "ctrl+k s" of "save"`},
+		{map[string]Keys{"grow_pane": {"ctrl+k"}, "save": {"ctrl+k"}}, `key "ctrl+k" is bound to both "save" and "grow_pane"`},
+	}
+	for _, tt := range tests {
+		m, err := Resolve("ctrl", "default", tt.overrides)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Resolve(%v) error = %v, want %q", tt.overrides, err, tt.want)
+		}
+		if got := m["save"]; !slices.Equal(got, []string{"ctrl+s"}) {
+			t.Errorf("Resolve(%v) save = %q, want the default", tt.overrides, got)
+		}
+	}
+}
+
+func TestResolveSequences(t *testing.T) {
+	m, err := Resolve("alt", "default", map[string]Keys{
+		"grow_pane":      {"leader+k leader+k"},
+		"shrink_pane":    {"leader+k leader+j"},
+		"split_vertical": {"leader+k v", "leader+k leader+v x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m["grow_pane"]; !slices.Equal(got, []string{"alt+k alt+k"}) {
+		t.Errorf("grow_pane = %q", got)
+	}
+	if got := m["split_vertical"]; !slices.Equal(got, []string{"alt+k v", "alt+k alt+v x"}) {
+		t.Errorf("split_vertical = %q", got)
+	}
+
+	_, err = Resolve("ctrl", "default", map[string]Keys{
+		"grow_pane":   {"ctrl+k ctrl+k"},
+		"shrink_pane": {"ctrl+k ctrl+k ctrl+j"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `key "ctrl+k ctrl+k" of "grow_pane" starts the sequence`) {
+		t.Errorf("a sequence starting with another one was accepted: %v", err)
+	}
+}
+
+func TestKeysJSON(t *testing.T) {
+	var got map[string]Keys
+	if err := json.Unmarshal([]byte(`{"save": "ctrl+s", "quit": ["ctrl+q", "f10"], "open": []}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got["save"], []string{"ctrl+s"}) || !slices.Equal(got["quit"], []string{"ctrl+q", "f10"}) {
+		t.Errorf("got %q", got)
+	}
+	if keys, ok := got["open"]; !ok || len(keys) != 0 {
+		t.Errorf("open = %q, %v, want an empty list", keys, ok)
+	}
+	if err := json.Unmarshal([]byte(`{"save": 1}`), &got); err == nil {
+		t.Error("a number was accepted as keys")
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"ctrl+s"}, "Ctrl+s"},
+		{[]string{"alt+ctrl+shift+up"}, "Ctrl+Alt+Shift+↑"},
+		{[]string{"shift+left", "shift+right", "shift+up", "shift+down"}, "Shift+Arrow"},
+		{[]string{"ctrl++"}, "Ctrl++"},
+		{[]string{"ctrl+left", "ctrl+right"}, "Ctrl+←/→"},
+		{[]string{"left", "right", "up", "down"}, "←/→/↑/↓"},
+		{[]string{"backspace", "ctrl+h"}, "Backspace/Ctrl+h"},
+		{[]string{"cmd+pgdown", "cmd+pgup"}, "Cmd+PgDn/PgUp"},
+		{[]string{" "}, "Space"},
+		{[]string{"ctrl+k ctrl+c"}, "Ctrl+k, Ctrl+c"},
+		{[]string{"ctrl+k left", "ctrl+k right"}, "Ctrl+k, ←/→"},
+		{[]string{"ctrl+k ctrl+c", "ctrl+j"}, "Ctrl+k, Ctrl+c/Ctrl+j"},
+	}
+	for _, tt := range tests {
+		if got := Labels(tt.keys); got != tt.want {
+			t.Errorf("Labels(%q) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
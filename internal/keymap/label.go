@@ -0,0 +1,116 @@
+package keymap
+
+import (
+	"slices"
+	"strings"
+)
+
+// modOrder is the order modifiers are shown in, after any others such as a
+// leader like cmd.
+var modOrder = []string{"ctrl", "alt", "shift"}
+
+var modLabels = map[string]string{
+	"ctrl":  "Ctrl",
+	"alt":   "Alt",
+	"shift": "Shift",
+}
+
+var keyLabels = map[string]string{
+	"up":        "↑",
+	"down":      "↓",
+	"left":      "←",
+	"right":     "→",
+	"pgup":      "PgUp",
+	"pgdown":    "PgDn",
+	"home":      "Home",
+	"end":       "End",
+	"enter":     "Enter",
+	"esc":       "Esc",
+	"tab":       "Tab",
+	"backspace": "Backspace",
+	"delete":    "Del",
+	"insert":    "Ins",
+	" ":         "Space",
+}
+
+// Label formats a key string for display, e.g. "ctrl+shift+left" as
+// "Ctrl+Shift+←" and the sequence "ctrl+k ctrl+c" as "Ctrl+k, Ctrl+c".
+func Label(k string) string {
+	parts := []string{k}
+	if strings.TrimSpace(k) != "" {
+		parts = strings.Split(k, " ")
+	}
+	for i, part := range parts {
+		mods, base := split(part)
+		parts[i] = modLabel(mods) + baseLabel(base)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Labels formats several keys on one line, sharing their modifiers if they
+// all have the same ones, e.g. "ctrl+left" and "ctrl+right" as "Ctrl+←/→",
+// and "shift+left", "shift+right", "shift+up" and "shift+down" as
+// "Shift+Arrow". Sequences that differ in their last key only share the
+// keys before it.
+func Labels(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	if i := strings.LastIndex(keys[0], " "); i > 0 {
+		prefix := keys[0][:i+1]
+		lasts := make([]string, len(keys))
+		for j, k := range keys {
+			last, ok := strings.CutPrefix(k, prefix)
+			if !ok || last == "" || strings.Contains(last, " ") {
+				lasts = nil
+				break
+			}
+			lasts[j] = last
+		}
+		if lasts != nil {
+			return Label(prefix[:i]) + ", " + Labels(lasts)
+		}
+	}
+	mods, _ := split(keys[0])
+	bases := make([]string, len(keys))
+	for i, k := range keys {
+		m, base := split(k)
+		if strings.Contains(k, " ") || strings.Join(m, "+") != strings.Join(mods, "+") {
+			labels := make([]string, len(keys))
+			for j, k := range keys {
+				labels[j] = Label(k)
+			}
+			return strings.Join(labels, "/")
+		}
+		bases[i] = baseLabel(base)
+	}
+	all := strings.Join(bases, "/")
+	if len(mods) > 0 && all == "←/→/↑/↓" {
+		all = "Arrow"
+	}
+	return modLabel(mods) + all
+}
+
+func modLabel(mods []string) string {
+	mods = slices.Clone(mods)
+	slices.SortStableFunc(mods, func(a, b string) int {
+		return slices.Index(modOrder, a) - slices.Index(modOrder, b)
+	})
+	var sb strings.Builder
+	for _, mod := range mods {
+		if l, ok := modLabels[mod]; ok {
+			mod = l
+		} else if mod != "" {
+			mod = strings.ToUpper(mod[:1]) + mod[1:]
+		}
+		sb.WriteString(mod + "+")
+	}
+	return sb.String()
+}
+
+func baseLabel(base string) string {
+	if l, ok := keyLabels[base]; ok {
+		return l
+	}
+	return base
+}
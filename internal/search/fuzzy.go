@@ -1,6 +1,7 @@
 package search
 
 import (
+	"context"
 	"os"
 	"strings"
 	"sync"
@@ -11,6 +12,8 @@ type FinderMode int
 const (
 	ModeFiles FinderMode = iota
 	ModeGrep
+	ModeBuffers
+	ModeClipboard
 )
 
 type FileResult struct {
@@ -23,10 +26,25 @@ type GrepResult struct {
 	Content string
 }
 
+// BufferResult is an open buffer, identified by its position in the buffer list.
+type BufferResult struct {
+	Index int
+	Name  string
+}
+
+// ClipResult is an entry of the clipboard history, identified by its
+// position in it.
+type ClipResult struct {
+	Index int
+	Text  string
+}
+
 type FinderResult struct {
-	File *FileResult
-	Grep *GrepResult
-	Mode FinderMode
+	File   *FileResult
+	Grep   *GrepResult
+	Buffer *BufferResult
+	Clip   *ClipResult
+	Mode   FinderMode
 }
 
 type FuzzyMatcher struct{}
@@ -152,16 +170,25 @@ func NewLiveGrep() *LiveGrep {
 }
 
 func (lg *LiveGrep) Search(root, pattern string) ([]GrepResult, error) {
+	return lg.SearchWith(root, pattern, Options{})
+}
+
+// SearchWith is Search matching pattern as told by opts. It returns an error
+// if the pattern is not a valid regular expression.
+func (lg *LiveGrep) SearchWith(root, pattern string, opts Options) ([]GrepResult, error) {
 	if pattern == "" {
 		return nil, nil
 	}
 
-	files, err := lg.scanner.Scan(root)
+	searcher, err := NewSearcher(pattern, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	bm := NewBoyerMooreSearch(pattern)
+	files, err := lg.scanner.Scan(root)
+	if err != nil {
+		return nil, err
+	}
 
 	var results []GrepResult
 	var mu sync.Mutex
@@ -195,13 +222,16 @@ func (lg *LiveGrep) Search(root, pattern string) ([]GrepResult, error) {
 			}
 
 			lines := strings.Split(string(content), "\n")
-			for lineIdx, line := range lines {
-				if bm.SearchInText(line) != nil {
-					resultsChan <- GrepResult{
-						Path:    filePath,
-						Line:    lineIdx + 1,
-						Content: strings.TrimSpace(line),
-					}
+			lastLine := -1
+			for _, match := range searcher.SearchInSource(context.Background(), stringLines(lines)) {
+				if match.Line == lastLine {
+					continue
+				}
+				lastLine = match.Line
+				resultsChan <- GrepResult{
+					Path:    filePath,
+					Line:    match.Line + 1,
+					Content: strings.TrimSpace(lines[match.Line]),
 				}
 			}
 		}(file)
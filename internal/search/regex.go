@@ -0,0 +1,151 @@
+package search
+
+import (
+	"context"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Options tells how NewSearcher matches its pattern.
+type Options struct {
+	Regex      bool // the pattern is a regular expression in RE2 syntax
+	IgnoreCase bool // upper and lower case match each other
+	SmartCase  bool // ignore case unless the pattern has an upper case letter
+	WholeWord  bool // matches are neither preceded nor followed by a letter, digit or _
+}
+
+// ignoreCase reports whether pattern matches regardless of case.
+func (o Options) ignoreCase(pattern string) bool {
+	if o.IgnoreCase {
+		return true
+	}
+	if !o.SmartCase {
+		return false
+	}
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		if r == '\\' && o.Regex {
+			// Escapes such as \W or \PL are no upper case letters.
+			_, next := utf8.DecodeRuneInString(pattern[i+size:])
+			size += next
+		} else if unicode.IsUpper(r) {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+// Searcher finds the matches of a pattern in a document.
+type Searcher interface {
+	SearchInSource(ctx context.Context, src LineSource) []SearchMatch
+	// Replacement returns the text replacing match, found in line, as given
+	// by template.
+	Replacement(line string, match SearchMatch, template string) string
+}
+
+// NewSearcher returns a searcher for pattern. It returns an error if the
+// pattern is not a valid regular expression.
+func NewSearcher(pattern string, opts Options) (Searcher, error) {
+	ignoreCase := opts.ignoreCase(pattern)
+	if !opts.Regex && !ignoreCase && !opts.WholeWord {
+		return NewBoyerMooreSearch(pattern), nil
+	}
+
+	// Other literal searches are done by a regex matching the pattern.
+	expr := pattern
+	if !opts.Regex {
+		expr = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	rs, err := NewRegexSearch(expr)
+	if err != nil {
+		return nil, err
+	}
+	rs.literal = !opts.Regex
+	rs.wholeWord = opts.WholeWord
+	return rs, nil
+}
+
+// Replacement returns template, a literal pattern has no groups to refer to.
+func (bms *BoyerMooreSearch) Replacement(line string, match SearchMatch, template string) string {
+	return template
+}
+
+// RegexSearch finds the matches of a regular expression. Matches don't span
+// lines, and matches of nothing, such as the ones of ^ or a*, are left out:
+// there is nothing to highlight or replace.
+type RegexSearch struct {
+	re        *regexp.Regexp
+	literal   bool // replacements don't refer to groups
+	wholeWord bool // only matches of whole words count
+}
+
+// NewRegexSearch compiles pattern, in RE2 syntax.
+func NewRegexSearch(pattern string) (*RegexSearch, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexSearch{re: re}, nil
+}
+
+// SearchInSource returns the matches in src, with their columns and lengths
+// in bytes like the ones of BoyerMooreSearch.
+func (rs *RegexSearch) SearchInSource(ctx context.Context, src LineSource) []SearchMatch {
+	if rs.re.String() == "" {
+		return nil
+	}
+
+	matches := make([]SearchMatch, 0, 100)
+	const checkInterval = 1000
+
+	lineCount := src.LineCount()
+	for lineIdx := 0; lineIdx < lineCount; lineIdx++ {
+		if lineIdx%checkInterval == 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+		}
+
+		line := src.Line(lineIdx)
+		for _, loc := range rs.re.FindAllStringIndex(line, -1) {
+			if loc[1] > loc[0] && (!rs.wholeWord || isWholeWord(line, loc[0], loc[1])) {
+				matches = append(matches, SearchMatch{Line: lineIdx, Col: loc[0], Length: loc[1] - loc[0]})
+			}
+		}
+	}
+
+	return matches
+}
+
+// Replacement expands template for match, with $1 or ${1} standing for the
+// text of the first group, ${name} for the group named name and $$ for a $.
+func (rs *RegexSearch) Replacement(line string, match SearchMatch, template string) string {
+	if rs.literal {
+		return template
+	}
+	for _, loc := range rs.re.FindAllStringSubmatchIndex(line, -1) {
+		if loc[0] == match.Col && loc[1]-loc[0] == match.Length {
+			return string(rs.re.ExpandString(nil, template, line, loc))
+		}
+	}
+	return template
+}
+
+// isWholeWord reports whether line[start:end] is neither preceded nor
+// followed by a word character.
+func isWholeWord(line string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(line[:start])
+	after, _ := utf8.DecodeRuneInString(line[end:])
+	return !isWordChar(before) && !isWordChar(after)
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -11,6 +11,17 @@ type SearchMatch struct {
 	Length int
 }
 
+// LineSource gives access to a document line by line, e.g. a buffer.Buffer.
+type LineSource interface {
+	LineCount() int
+	Line(i int) string
+}
+
+type stringLines []string
+
+func (s stringLines) LineCount() int    { return len(s) }
+func (s stringLines) Line(i int) string { return s[i] }
+
 type BoyerMooreSearch struct {
 	pattern     string
 	patternLen  int
@@ -85,6 +96,11 @@ func (bms *BoyerMooreSearch) SearchInText(text string) []SearchMatch {
 
 // SearchInLines searches for the pattern in multiple lines and returns matches with line/column positions
 func (bms *BoyerMooreSearch) SearchInLines(ctx context.Context, lines []string) []SearchMatch {
+	return bms.SearchInSource(ctx, stringLines(lines))
+}
+
+// SearchInSource is like SearchInLines but reads the lines from src.
+func (bms *BoyerMooreSearch) SearchInSource(ctx context.Context, src LineSource) []SearchMatch {
 	if bms.patternLen == 0 {
 		return nil
 	}
@@ -96,7 +112,8 @@ func (bms *BoyerMooreSearch) SearchInLines(ctx context.Context, lines []string)
 	// Check context every N lines to avoid overhead
 	const checkInterval = 1000
 
-	for lineIdx, line := range lines {
+	lineCount := src.LineCount()
+	for lineIdx := 0; lineIdx < lineCount; lineIdx++ {
 		if lineIdx%checkInterval == 0 {
 			select {
 			case <-ctx.Done():
@@ -105,7 +122,7 @@ func (bms *BoyerMooreSearch) SearchInLines(ctx context.Context, lines []string)
 			}
 		}
 
-		lineMatches := bms.SearchInText(line)
+		lineMatches := bms.SearchInText(src.Line(lineIdx))
 		for _, match := range lineMatches {
 			matches = append(matches, SearchMatch{
 				Line:   lineIdx,
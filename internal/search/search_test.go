@@ -2,6 +2,8 @@ package search
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -71,3 +73,167 @@ func TestBoyerMooreSearchOverlapping(t *testing.T) {
 		}
 	}
 }
+
+func TestNewSearcherInvalidRegex(t *testing.T) {
+	if _, err := NewSearcher("(unclosed", Options{Regex: true}); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+	if _, err := NewSearcher("(unclosed", Options{}); err != nil {
+		t.Errorf("a literal pattern should not be parsed, got %v", err)
+	}
+}
+
+func TestRegexSearch(t *testing.T) {
+	searcher, err := NewSearcher(`\d+`, Options{Regex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{"a1 b22", "none", "é333"}
+
+	results := searcher.SearchInSource(context.Background(), stringLines(lines))
+	expected := []SearchMatch{
+		{Line: 0, Col: 1, Length: 1},
+		{Line: 0, Col: 4, Length: 2},
+		{Line: 2, Col: 2, Length: 3}, // columns are in bytes
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v", len(expected), results)
+	}
+	for i, result := range results {
+		if result != expected[i] {
+			t.Errorf("Expected result %d to be %+v, got %+v", i, expected[i], result)
+		}
+	}
+}
+
+func TestRegexSearchSkipsEmptyMatches(t *testing.T) {
+	searcher, err := NewRegexSearch(`x*`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := searcher.SearchInSource(context.Background(), stringLines{"axxb", ""})
+	if len(results) != 1 || results[0] != (SearchMatch{Line: 0, Col: 1, Length: 2}) {
+		t.Errorf("Expected only the match of xx, got %+v", results)
+	}
+}
+
+func TestReplacement(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		regex    bool
+		line     string
+		match    SearchMatch
+		template string
+		want     string
+	}{
+		{`(\w+)@(\w+)`, true, "mail bob@home now", SearchMatch{0, 5, 8}, "$2 at ${1}", "home at bob"},
+		{`(?P<key>\w+)=(?P<value>\w+)`, true, "a=1 b=2", SearchMatch{0, 4, 3}, "${value}=${key}", "2=b"},
+		{`(\w)x`, true, "axbx", SearchMatch{0, 2, 2}, "${1}_$$", "b_$"},
+		{`$1`, false, "cost $1", SearchMatch{0, 5, 2}, "$2", "$2"},
+	}
+	for _, tt := range tests {
+		searcher, err := NewSearcher(tt.pattern, Options{Regex: tt.regex})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := searcher.Replacement(tt.line, tt.match, tt.template); got != tt.want {
+			t.Errorf("Replacement(%q, %q) = %q, want %q", tt.pattern, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestSearchOptions(t *testing.T) {
+	lines := stringLines{"Foo foo FOO", "food _foo foo.bar", "Straße STRASSE"}
+	tests := []struct {
+		name    string
+		pattern string
+		opts    Options
+		want    []SearchMatch
+	}{
+		{"case sensitive", "foo", Options{}, []SearchMatch{{0, 4, 3}, {1, 0, 3}, {1, 6, 3}, {1, 10, 3}}},
+		{"ignore case", "foo", Options{IgnoreCase: true}, []SearchMatch{{0, 0, 3}, {0, 4, 3}, {0, 8, 3}, {1, 0, 3}, {1, 6, 3}, {1, 10, 3}}},
+		{"ignore case with upper case", "FOO", Options{IgnoreCase: true, SmartCase: true}, []SearchMatch{{0, 0, 3}, {0, 4, 3}, {0, 8, 3}, {1, 0, 3}, {1, 6, 3}, {1, 10, 3}}},
+		{"smart case lower", "foo", Options{SmartCase: true}, []SearchMatch{{0, 0, 3}, {0, 4, 3}, {0, 8, 3}, {1, 0, 3}, {1, 6, 3}, {1, 10, 3}}},
+		{"smart case upper", "Foo", Options{SmartCase: true}, []SearchMatch{{0, 0, 3}}},
+		{"smart case regex escape", `\Afoo`, Options{Regex: true, SmartCase: true}, []SearchMatch{{0, 0, 3}, {1, 0, 3}}},
+		{"ignore case non-ascii", "straße", Options{IgnoreCase: true}, []SearchMatch{{2, 0, 7}}},
+		{"whole word", "foo", Options{WholeWord: true}, []SearchMatch{{0, 4, 3}, {1, 10, 3}}},
+		{"whole word ignore case", "foo", Options{WholeWord: true, IgnoreCase: true}, []SearchMatch{{0, 0, 3}, {0, 4, 3}, {0, 8, 3}, {1, 10, 3}}},
+		{"whole word regex", `fo+`, Options{Regex: true, WholeWord: true}, []SearchMatch{{0, 4, 3}, {1, 10, 3}}},
+		{"whole word underscore", "_foo", Options{WholeWord: true}, []SearchMatch{{1, 5, 4}}},
+		{"literal metacharacters", "o.b", Options{IgnoreCase: true}, []SearchMatch{{1, 12, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searcher, err := NewSearcher(tt.pattern, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results := searcher.SearchInSource(context.Background(), lines)
+			if len(results) != len(tt.want) {
+				t.Fatalf("Expected %+v, got %+v", tt.want, results)
+			}
+			for i, result := range results {
+				if result != tt.want[i] {
+					t.Errorf("Expected result %d to be %+v, got %+v", i, tt.want[i], result)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchOptionsLiteralReplacement(t *testing.T) {
+	searcher, err := NewSearcher("(a)", Options{IgnoreCase: true, WholeWord: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := searcher.Replacement("x (A) y", SearchMatch{0, 2, 3}, "$1"); got != "$1" {
+		t.Errorf("a literal pattern should not expand groups, got %q", got)
+	}
+}
+
+func TestLiveGrepOptions(t *testing.T) {
+	dir := t.TempDir()
+	content := "Error here\nerrors everywhere\nno ERROR at all\n"
+	if err := os.WriteFile(filepath.Join(dir, "log.txt"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lg := NewLiveGrep()
+	tests := []struct {
+		pattern string
+		opts    Options
+		want    []int
+	}{
+		{"error", Options{}, []int{2}},
+		{"error", Options{IgnoreCase: true}, []int{1, 2, 3}},
+		{"error", Options{SmartCase: true, WholeWord: true}, []int{1, 3}},
+		{"Error", Options{SmartCase: true}, []int{1}},
+	}
+	for _, tt := range tests {
+		results, err := lg.SearchWith(dir, tt.pattern, tt.opts)
+		if err != nil {
+			t.Fatalf("SearchWith(%q, %+v) failed: %v", tt.pattern, tt.opts, err)
+		}
+		var lines []int
+		for _, r := range results {
+			lines = append(lines, r.Line)
+		}
+		if len(lines) != len(tt.want) {
+			t.Errorf("SearchWith(%q, %+v) found lines %v, want %v", tt.pattern, tt.opts, lines, tt.want)
+			continue
+		}
+		for i := range lines {
+			if lines[i] != tt.want[i] {
+				t.Errorf("SearchWith(%q, %+v) found lines %v, want %v", tt.pattern, tt.opts, lines, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, err := lg.SearchWith(dir, "(", Options{Regex: true}); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+}
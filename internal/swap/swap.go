@@ -0,0 +1,180 @@
+// Package swap journals unsaved buffers to disk so edits survive a crash.
+// Every edited file gets one swap file under the user cache directory; it is
+// rewritten while the buffer has unsaved changes and removed once the buffer
+// is saved or the editor exits normally. Untitled buffers, which have no file
+// yet, are journaled under a generated id instead, see NewID.
+package swap
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"larry/internal/buffer"
+)
+
+// File is the content of a swap file.
+type File struct {
+	Path    string          `json:"path"`         // absolute path of the edited file
+	ID      string          `json:"id,omitempty"` // id of an untitled buffer, which has no Path
+	PID     int             `json:"pid"`          // process that wrote the swap file
+	Saved   time.Time       `json:"saved"`
+	Content string          `json:"content"`
+	Undo    json.RawMessage `json:"undo,omitempty"` // undo history, owned by the editor
+}
+
+// InUse reports whether the process that wrote the swap file is still
+// running, i.e. another editor instance is editing the file right now.
+func (f File) InUse() bool {
+	return f.PID != os.Getpid() && processAlive(f.PID)
+}
+
+// Dir returns the directory holding swap files.
+func Dir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "larry", "swap"), nil
+}
+
+// PathFor returns the swap file used for the file at path.
+func PathFor(path string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, filepath.Base(abs)+"-"+hex.EncodeToString(sum[:8])+".swp"), nil
+}
+
+// untitledPrefix starts the names of the swap files of untitled buffers.
+const untitledPrefix = "untitled-"
+
+// NewID returns a new id for the swap file of an untitled buffer.
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// pathForID returns the swap file used for the untitled buffer id.
+func pathForID(id string) (string, error) {
+	if id == "" || filepath.Base(id) != id {
+		return "", fmt.Errorf("invalid swap id %q", id)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, untitledPrefix+id+".swp"), nil
+}
+
+// pathOf returns the swap file of f, by its Path or by its ID when untitled.
+func pathOf(f File) (string, error) {
+	if f.Path == "" {
+		return pathForID(f.ID)
+	}
+	return PathFor(f.Path)
+}
+
+// Write stores f as the swap file of f.Path, or of the untitled buffer f.ID
+// if f.Path is empty.
+func Write(f File) error {
+	swapPath, err := pathOf(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(swapPath), 0700); err != nil {
+		return err
+	}
+	f.PID = os.Getpid()
+	f.Saved = time.Now()
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return buffer.WriteFile(swapPath, data, buffer.SaveOptions{})
+}
+
+// Read returns the swap file of the file at path. It returns an error
+// satisfying errors.Is(err, os.ErrNotExist) if there is none.
+func Read(path string) (File, error) {
+	var f File
+	swapPath, err := PathFor(path)
+	if err != nil {
+		return f, err
+	}
+	data, err := os.ReadFile(swapPath)
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// Remove deletes the swap file of the file at path, if any.
+func Remove(path string) error {
+	swapPath, err := PathFor(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(swapPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RemoveID deletes the swap file of the untitled buffer id, if any.
+func RemoveID(id string) error {
+	swapPath, err := pathForID(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(swapPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Untitled returns the swap files of untitled buffers, oldest first. Swap
+// files that can't be read are left out.
+func Untitled() ([]File, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, untitledPrefix+"*.swp"))
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var f File
+		if json.Unmarshal(data, &f) != nil || f.ID == "" {
+			continue
+		}
+		files = append(files, f)
+	}
+	slices.SortFunc(files, func(a, b File) int {
+		return a.Saved.Compare(b.Saved)
+	})
+	return files, nil
+}
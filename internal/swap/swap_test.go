@@ -0,0 +1,91 @@
+package swap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteReadRemove(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "notes.txt")
+
+	if _, err := Read(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no swap file, got %v", err)
+	}
+
+	if err := Write(File{Path: path, Content: "unsaved", Undo: []byte(`[1,2]`)}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	f, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if f.Content != "unsaved" || string(f.Undo) != "[1,2]" {
+		t.Errorf("unexpected swap content %+v", f)
+	}
+	if f.PID != os.Getpid() || f.InUse() {
+		t.Errorf("swap written by this process should not be in use by another one")
+	}
+
+	if err := Remove(path); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := Read(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("swap file still present after Remove")
+	}
+}
+
+func TestPathForIsStable(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	a, _ := PathFor("dir/file.txt")
+	b, _ := PathFor("dir/./file.txt")
+	c, _ := PathFor("other/file.txt")
+	if a != b {
+		t.Errorf("same file got different swap paths: %s, %s", a, b)
+	}
+	if a == c {
+		t.Errorf("different files share the swap path %s", a)
+	}
+}
+
+func TestUntitled(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	first, second := NewID(), NewID()
+	if first == second {
+		t.Fatalf("ids should be unique, got %q twice", first)
+	}
+	if err := Write(File{ID: first, Content: "draft"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := Write(File{ID: second, Content: "later"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := Write(File{Path: filepath.Join(t.TempDir(), "named.txt"), Content: "named"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	files, err := Untitled()
+	if err != nil {
+		t.Fatalf("Untitled failed: %v", err)
+	}
+	if len(files) != 2 || files[0].ID != first || files[0].Content != "draft" || files[1].ID != second {
+		t.Errorf("expected the two untitled swap files oldest first, got %+v", files)
+	}
+
+	if err := RemoveID(first); err != nil {
+		t.Fatalf("RemoveID failed: %v", err)
+	}
+	if files, _ := Untitled(); len(files) != 1 || files[0].ID != second {
+		t.Errorf("expected only %s left, got %+v", second, files)
+	}
+
+	if err := Write(File{ID: "../escape"}); err == nil {
+		t.Error("expected an error for an id that is not a file name")
+	}
+}
@@ -0,0 +1,273 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// blockSelection is a rectangular selection. Its columns are visual columns,
+// with tabs as wide as in the editor view, and may lie beyond the end of
+// short lines. A block of zero width is a column of cursors.
+type blockSelection struct {
+	startRow, startCol int // corner the selection started at
+	row, col           int // corner moved by the keys, where the cursor is
+}
+
+func (b blockSelection) rows() (int, int) { return min(b.startRow, b.row), max(b.startRow, b.row) }
+func (b blockSelection) cols() (int, int) { return min(b.startCol, b.col), max(b.startCol, b.col) }
+
+func (m Model) runeWidth(r rune) int {
+	if r == '\t' {
+		return m.Config.TabWidth
+	}
+	return 1
+}
+
+// visualCol returns the visual column of rune index col in line.
+func (m Model) visualCol(line []rune, col int) int {
+	w := 0
+	for _, r := range line[:min(col, len(line))] {
+		w += m.runeWidth(r)
+	}
+	return w
+}
+
+// runeIndex returns the index of the first rune of line starting at or after
+// visual column vcol, and by how many columns line is too short to reach it.
+// A tab that vcol falls into is not included.
+func (m Model) runeIndex(line []rune, vcol int) (idx, short int) {
+	w := 0
+	for i, r := range line {
+		if w >= vcol {
+			return i, 0
+		}
+		w += m.runeWidth(r)
+	}
+	return len(line), max(vcol-w, 0)
+}
+
+// splitTab replaces a tab in row that visual column vcol falls into by spaces,
+// so that text can be inserted or deleted at vcol.
+func (m *Model) splitTab(row, vcol int) {
+	line := []rune(m.Buffer.Line(row))
+	w := 0
+	for i, r := range line {
+		next := w + m.runeWidth(r)
+		if w < vcol && vcol < next && r == '\t' {
+			spaces := strings.Repeat(" ", m.Config.TabWidth)
+			m.markModified()
+			m.pushUndo(EditOp{Type: OpDelete, Row: row, Col: i, Text: "\t"})
+			m.Buffer.Delete(row, i, row, i+1)
+			m.pushUndo(EditOp{Type: OpInsert, Row: row, Col: i, Text: spaces})
+			m.Buffer.Insert(row, i, spaces)
+			return
+		}
+		if next > vcol {
+			return
+		}
+		w = next
+	}
+}
+
+// extendBlock starts a block selection at the cursor or moves its corner by
+// dr rows and dc columns.
+func (m Model) extendBlock(dr, dc int) Model {
+	if m.block == nil {
+		vc := m.visualCol([]rune(m.Buffer.Line(m.CursorRow)), m.CursorCol)
+		m.block = &blockSelection{m.CursorRow, vc, m.CursorRow, vc}
+		m.selecting = false
+		m.cursors = nil
+	}
+	b := *m.block
+	b.row = min(max(b.row+dr, 0), m.Buffer.LineCount()-1)
+	b.col = max(b.col+dc, 0)
+	if dc > 0 {
+		// Stop at the longest line of the block.
+		r0, r1 := b.rows()
+		widest := 0
+		for r := r0; r <= r1; r++ {
+			line := []rune(m.Buffer.Line(r))
+			widest = max(widest, m.visualCol(line, len(line)))
+		}
+		b.col = min(b.col, max(widest, m.block.col))
+	}
+	m.block = &b
+	return m.placeBlockCursor()
+}
+
+// placeBlockCursor puts the cursor on the moving corner of the block.
+func (m Model) placeBlockCursor() Model {
+	m.CursorRow = m.block.row
+	m.CursorCol, _ = m.runeIndex([]rune(m.Buffer.Line(m.CursorRow)), m.block.col)
+	return m
+}
+
+// blockRanges returns the part of every line inside the block.
+func (m Model) blockRanges() []selection {
+	r0, r1 := m.block.rows()
+	c0, c1 := m.block.cols()
+	var out []selection
+	for r := r0; r <= r1; r++ {
+		line := []rune(m.Buffer.Line(r))
+		i0, _ := m.runeIndex(line, c0)
+		i1, _ := m.runeIndex(line, c1)
+		out = append(out, selection{r, i0, r, i1})
+	}
+	return out
+}
+
+// blockText returns the text inside the block, one line per row. A tab on an
+// edge of the block is copied as the spaces of it inside the block, the ones
+// deleteBlock leaves or removes after splitTab.
+func (m Model) blockText() string {
+	r0, r1 := m.block.rows()
+	c0, c1 := m.block.cols()
+	var lines []string
+	for r := r0; r <= r1; r++ {
+		var sb strings.Builder
+		w := 0
+		for _, ch := range m.Buffer.Line(r) {
+			next := w + m.runeWidth(ch)
+			switch {
+			case next <= c0 || w >= c1:
+			case ch == '\t' && (w < c0 || next > c1):
+				sb.WriteString(strings.Repeat(" ", min(next, c1)-max(w, c0)))
+			default:
+				sb.WriteRune(ch)
+			}
+			w = next
+		}
+		lines = append(lines, sb.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// deleteBlock deletes the text inside the block, leaving a column of cursors
+// at its left edge.
+func (m Model) deleteBlock() Model {
+	r0, r1 := m.block.rows()
+	c0, c1 := m.block.cols()
+	for r := r0; r <= r1; r++ {
+		m.splitTab(r, c0)
+		m.splitTab(r, c1)
+	}
+	for _, s := range m.blockRanges() {
+		if s.startCol == s.endCol {
+			continue
+		}
+		m.markModified()
+		text := m.Buffer.Delete(s.startRow, s.startCol, s.endRow, s.endCol)
+		m.pushUndo(EditOp{Type: OpDelete, Row: s.startRow, Col: s.startCol, Text: text})
+	}
+	m.block = &blockSelection{m.block.startRow, c0, m.block.row, c0}
+	return m.placeBlockCursor()
+}
+
+// insertAtVisual inserts text into row at visual column vcol, padding a short
+// line with spaces to reach it.
+func (m *Model) insertAtVisual(row, vcol int, text string) {
+	if text == "" {
+		return
+	}
+	m.splitTab(row, vcol)
+	idx, short := m.runeIndex([]rune(m.Buffer.Line(row)), vcol)
+	text = strings.Repeat(" ", short) + text
+	m.markModified()
+	m.pushUndo(EditOp{Type: OpInsert, Row: row, Col: idx, Text: text})
+	m.Buffer.Insert(row, idx, text)
+}
+
+// typeBlock replaces the block with text on every line of it.
+func (m Model) typeBlock(text string) Model {
+	if c0, c1 := m.block.cols(); c0 == c1 {
+		m.setEditKind(editTyping)
+	}
+	m = m.deleteBlock()
+	r0, r1 := m.block.rows()
+	c0, _ := m.block.cols()
+	for r := r0; r <= r1; r++ {
+		m.insertAtVisual(r, c0, text)
+	}
+	runes := []rune(text)
+	c := c0 + m.visualCol(runes, len(runes))
+	m.block = &blockSelection{m.block.startRow, c, m.block.row, c}
+	return m.placeBlockCursor()
+}
+
+// backspaceBlock deletes the block, or if it is empty, the character left of
+// it on every line.
+func (m Model) backspaceBlock() Model {
+	if c0, c1 := m.block.cols(); c0 != c1 {
+		return m.deleteBlock()
+	}
+	m.setEditKind(editDeleting)
+	r0, r1 := m.block.rows()
+	c0, _ := m.block.cols()
+	col := max(c0-1, 0)
+	moved := false
+	for r := r0; r <= r1; r++ {
+		m.splitTab(r, c0)
+		line := []rune(m.Buffer.Line(r))
+		idx, short := m.runeIndex(line, c0)
+		if short > 0 || idx == 0 {
+			continue
+		}
+		if !moved {
+			col, moved = m.visualCol(line, idx-1), true
+		}
+		m.markModified()
+		text := m.Buffer.Delete(r, idx-1, r, idx)
+		m.pushUndo(EditOp{Type: OpDelete, Row: r, Col: idx - 1, Text: text})
+	}
+	m.block = &blockSelection{m.block.startRow, col, m.block.row, col}
+	return m.placeBlockCursor()
+}
+
+// pasteBlock inserts the lines of text below each other at the visual column
+// of the cursor, adding lines at the end of the buffer if needed.
+func (m Model) pasteBlock(text string) Model {
+	row, vcol := m.CursorRow, m.visualCol([]rune(m.Buffer.Line(m.CursorRow)), m.CursorCol)
+	if m.block != nil {
+		m = m.deleteBlock()
+		row, _ = m.block.rows()
+		vcol, _ = m.block.cols()
+		m.block = nil
+	}
+	m.selecting = false
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		r := row + i
+		if r >= m.Buffer.LineCount() {
+			last := m.Buffer.LineCount() - 1
+			m.pushUndo(EditOp{Type: OpInsert, Row: last, Col: m.Buffer.LineLen(last), Text: "\n"})
+			m.Buffer.Insert(last, m.Buffer.LineLen(last), "\n")
+		}
+		m.insertAtVisual(r, vcol, line)
+	}
+	m.CursorRow = row
+	m.CursorCol, _ = m.runeIndex([]rune(m.Buffer.Line(row)), vcol)
+	m.CursorCol += len([]rune(lines[0]))
+	return m
+}
+
+// blockKey handles a key while a block is selected. Keys that do not edit
+// the block end block selection and are handled as usual.
+func (m Model) blockKey(msg tea.KeyMsg) Model {
+	switch {
+	case msg.Type == tea.KeyEsc:
+		m.block = nil
+		return m
+	case msg.Type == tea.KeyRunes && !m.KeyMap.bound(msg):
+		return m.typeBlock(string(msg.Runes))
+	case msg.Type == tea.KeySpace:
+		return m.typeBlock(" ")
+	case msg.Type == tea.KeyTab:
+		return m.typeBlock(strings.Repeat(" ", m.Config.TabWidth))
+	case msg.Type == tea.KeyBackspace || msg.Type == tea.KeyDelete || key.Matches(msg, m.KeyMap.Delete):
+		return m.backspaceBlock()
+	}
+	m.block = nil
+	return m.cursorKey(msg)
+}
@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"testing"
+
+	"larry/internal/buffer"
+	"larry/internal/config"
+)
+
+// blockModel returns a model editing text with tabs 4 columns wide and a block
+// selection from column c0 to c1 of rows r0 to r1.
+func blockModel(t *testing.T, text string, r0, c0, r1, c1 int) Model {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Swap = false
+	cfg.Clipboard = []string{"internal"}
+	cfg.TabWidth = 4
+	m := InitialModel("", buffer.New(text), cfg)
+	m.block = &blockSelection{r0, c0, r1, c1}
+	return m.placeBlockCursor()
+}
+
+type blockTest struct {
+	name           string
+	text           string
+	r0, c0, r1, c1 int
+	want           string
+}
+
+func runBlockTests(t *testing.T, tests []blockTest, edit func(Model) Model) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := blockModel(t, tt.text, tt.r0, tt.c0, tt.r1, tt.c1)
+			m.beginEdit()
+			m = edit(m)
+			m.commitEdit()
+			if got := m.Buffer.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			// The edit is undone in one step.
+			m = m.undo()
+			if got := m.Buffer.String(); got != tt.text {
+				t.Errorf("after undo got %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestDeleteBlock(t *testing.T) {
+	runBlockTests(t, []blockTest{
+		{"rectangle", "abcdef\nabcdef", 0, 1, 1, 4, "aef\naef"},
+		{"short line", "abcdef\nab\n\nabcdef", 0, 1, 3, 4, "aef\na\n\naef"},
+		{"tab on the left edge", "\tx\nabcdefg", 0, 2, 1, 5, "  \nabfg"},
+		{"tab on the right edge", "a\tb\nabcdefg", 0, 0, 1, 2, "   b\ncdefg"},
+		{"tab inside", "a\tb\nabcdefg", 0, 1, 1, 5, "ab\nafg"},
+	}, Model.deleteBlock)
+}
+
+func TestTypeBlock(t *testing.T) {
+	runBlockTests(t, []blockTest{
+		{"column", "abc\nabc", 0, 1, 1, 1, "aXbc\naXbc"},
+		{"replaces the block", "abcd\nabcd", 0, 1, 1, 3, "aXd\naXd"},
+		{"short line is padded", "abc\na", 0, 3, 1, 3, "abcX\na  X"},
+		{"tab is split", "\tb\nabcde", 0, 2, 1, 2, "  X  b\nabXcde"},
+	}, func(m Model) Model { return m.typeBlock("X") })
+}
+
+func TestBackspaceBlock(t *testing.T) {
+	runBlockTests(t, []blockTest{
+		{"column", "abc\nabc", 0, 2, 1, 2, "ac\nac"},
+		{"short line is kept", "abc\na", 0, 3, 1, 3, "ab\na"},
+		{"tab is split", "\tb\nabcde", 0, 2, 1, 2, "   b\nacde"},
+		{"start of line", "abc\nabc", 0, 0, 1, 0, "abc\nabc"},
+		{"non-empty block is deleted", "abcd\nabcd", 0, 1, 1, 3, "ad\nad"},
+	}, Model.backspaceBlock)
+}
+
+func TestPasteBlock(t *testing.T) {
+	runBlockTests(t, []blockTest{
+		{"into a column", "ab\nab", 0, 1, 1, 1, "aXb\naYb"},
+		{"short line is padded", "abc\na", 0, 3, 1, 3, "abcX\na  Y"},
+		{"tab is split", "\tb\nabcde", 0, 2, 1, 2, "  X  b\nabYcde"},
+		{"replaces the block", "abcd\nabcd", 0, 1, 1, 3, "aXd\naYd"},
+	}, func(m Model) Model { return m.pasteBlock("X\nY") })
+
+	runBlockTests(t, []blockTest{
+		{"past the end", "ab\nab", 0, 1, 1, 1, "aXb\naYb\n Z"},
+	}, func(m Model) Model { return m.pasteBlock("X\nY\nZ") })
+}
+
+func TestBlockText(t *testing.T) {
+	tests := []blockTest{
+		{"rectangle", "abcdef\nabcdef", 0, 1, 1, 4, "bcd\nbcd"},
+		{"short line", "abcdef\nab\n", 0, 1, 2, 4, "bcd\nb\n"},
+		{"tab on the left edge", "\tb\nabcde", 0, 2, 1, 5, "  b\ncde"},
+		{"tab on the right edge", "a\tb\nabcdefg", 0, 0, 1, 2, "a \nab"},
+		{"tab inside", "a\tb\nabcdefg", 0, 0, 1, 6, "a\tb\nabcdef"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := blockModel(t, tt.text, tt.r0, tt.c0, tt.r1, tt.c1)
+			if got := m.blockText(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"larry/internal/buffer"
+	"larry/internal/undo"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+)
+
+// document is the per-file state of an open buffer. The active document lives
+// in the Model fields, its entry in Model.docs is only updated by stash.
+type document struct {
+	buffer             *buffer.Buffer
+	fileName           string
+	cursorRow          int
+	cursorCol          int
+	top                visualLine
+	startRow           int
+	startCol           int
+	selecting          bool
+	history            undo.Tree[UndoGroup]
+	modified           bool
+	viewMode           ViewMode
+	markdownRenderer   *glamour.TermRenderer
+	markdownCache      string
+	markdownCacheValid bool
+	swapPending        bool
+	swapChecked        bool
+	swapID             string
+}
+
+func newDocument(filename string, buf *buffer.Buffer) document {
+	return document{buffer: buf, fileName: filename, viewMode: ViewModeEditor}
+}
+
+// stash saves the state of the active document into m.docs.
+func (m Model) stash() Model {
+	m.docs = slices.Clone(m.docs)
+	m.docs[m.active] = document{
+		buffer:             m.Buffer,
+		fileName:           m.FileName,
+		cursorRow:          m.CursorRow,
+		cursorCol:          m.CursorCol,
+		top:                m.top,
+		startRow:           m.startRow,
+		startCol:           m.startCol,
+		selecting:          m.selecting,
+		history:            m.history,
+		modified:           m.Modified,
+		viewMode:           m.viewMode,
+		markdownRenderer:   m.markdownRenderer,
+		markdownCache:      m.markdownCache,
+		markdownCacheValid: m.markdownCacheValid,
+		swapPending:        m.swapPending,
+		swapChecked:        m.swapChecked,
+		swapID:             m.swapID,
+	}
+	return m
+}
+
+// restore makes document i the active one without saving the current state,
+// see stash.
+func (m Model) restore(i int) Model {
+	d := m.docs[i]
+	m.active = i
+	m.Buffer = d.buffer
+	m.FileName = d.fileName
+	m.CursorRow = d.cursorRow
+	m.CursorCol = d.cursorCol
+	m.cursors = nil
+	m.block = nil
+	m.top = d.top
+	m.startRow = d.startRow
+	m.startCol = d.startCol
+	m.selecting = d.selecting
+	m.history = d.history
+	m.Modified = d.modified
+	m.viewMode = d.viewMode
+	m.markdownRenderer = d.markdownRenderer
+	m.markdownCache = d.markdownCache
+	m.markdownCacheValid = d.markdownCacheValid
+	m.swapPending = d.swapPending
+	m.swapChecked = d.swapChecked
+	m.swapID = d.swapID
+	return m
+}
+
+// enter activates document i after the previous one was stashed or closed.
+func (m Model) enter(i int) (Model, tea.Cmd) {
+	m = m.restore(i)
+	m.searchResults = nil
+	m.currentResultIndex = -1
+	m.replaceResults = nil
+	m.currReplaceIndex = -1
+	m = m.updateViewport()
+	m.statusMsg = fmt.Sprintf("Buffer %d/%d: %s", i+1, len(m.docs), m.bufferName(i))
+	if !m.swapChecked {
+		m = m.checkSwap()
+	}
+	return m, WaitForLoadCmd(m.Buffer)
+}
+
+// switchBuffer makes document i the active one.
+func (m Model) switchBuffer(i int) (Model, tea.Cmd) {
+	if i == m.active || i < 0 || i >= len(m.docs) {
+		return m, nil
+	}
+	return m.stash().enter(i)
+}
+
+func (m Model) nextBuffer() (Model, tea.Cmd) {
+	if len(m.docs) < 2 {
+		m.statusMsg = "No other buffers"
+		return m, nil
+	}
+	return m.switchBuffer((m.active + 1) % len(m.docs))
+}
+
+func (m Model) prevBuffer() (Model, tea.Cmd) {
+	if len(m.docs) < 2 {
+		m.statusMsg = "No other buffers"
+		return m, nil
+	}
+	return m.switchBuffer((m.active + len(m.docs) - 1) % len(m.docs))
+}
+
+// closeBuffer closes the active document, dropping unsaved changes, see
+// confirmClose. Closing the last one leaves an empty unnamed buffer.
+func (m Model) closeBuffer() (Model, tea.Cmd) {
+	m.discardSwap()
+
+	closed := m.active
+	if len(m.docs) == 1 {
+		m.docs = []document{newDocument("", buffer.NewBuffer())}
+		m, cmd := m.docClosed(closed, 0).enter(0)
+		m.statusMsg = "Buffer closed"
+		return m, cmd
+	}
+	m.docs = slices.Delete(slices.Clone(m.docs), closed, closed+1)
+	next := min(closed, len(m.docs)-1)
+	return m.docClosed(closed, next).enter(next)
+}
+
+// AddBuffer opens another document in the background, next to the active one.
+func (m Model) AddBuffer(filename string, buf *buffer.Buffer) Model {
+	m = m.stash()
+	d := newDocument(filename, buf)
+	d.history = loadHistory(m.Config, filename, buf)
+	m.docs = append(m.docs, d)
+	return m
+}
+
+// bufferName returns the file name of document i for display.
+func (m Model) bufferName(i int) string {
+	name := m.docs[i].fileName
+	if i == m.active {
+		name = m.FileName
+	}
+	if name == "" {
+		return "[No Name]"
+	}
+	return name
+}
+
+// bufferNames lists all open documents for the buffer list, marking modified ones.
+func (m Model) bufferNames() []string {
+	m = m.stash()
+	names := make([]string, len(m.docs))
+	for i, d := range m.docs {
+		names[i] = m.bufferName(i)
+		if d.modified {
+			names[i] += " [+]"
+		}
+	}
+	return names
+}
+
+// findBuffer returns the index of the document holding path, or -1.
+func (m Model) findBuffer(path string) int {
+	want := absPath(path)
+	for i := range m.docs {
+		name := m.docs[i].fileName
+		if i == m.active {
+			name = m.FileName
+		}
+		if name != "" && absPath(name) == want {
+			return i
+		}
+	}
+	return -1
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// discardAllSwaps removes the swap files of all documents, e.g. when quitting.
+func (m Model) discardAllSwaps() {
+	if !m.Config.Swap {
+		return
+	}
+	m = m.stash()
+	for _, d := range m.docs {
+		if d.fileName != "" || d.swapID != "" {
+			m.swaps.remove(d.fileName, d.swapID)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Besides the primary cursor in CursorRow/CursorCol, the view follows, the
+// model can hold extra cursors in Model.cursors, each with its own selection.
+// Keys handled by cursorKey are applied to every cursor.
+
+// span is a cursor as byte offsets into the buffer.
+type span struct {
+	anchor, head int // selection start and cursor, equal without a selection
+	selecting    bool
+	primary      bool
+}
+
+func (s span) start() int { return min(s.anchor, s.head) }
+func (s span) end() int   { return max(s.anchor, s.head) }
+
+func (m Model) spanOf(c CursorState, primary bool) span {
+	head := m.Buffer.Offset(c.Row, c.Col)
+	anchor := head
+	if c.Selecting {
+		anchor = m.Buffer.Offset(c.StartRow, c.StartCol)
+	}
+	return span{anchor: anchor, head: head, selecting: c.Selecting, primary: primary}
+}
+
+func (m Model) cursorOf(s span) CursorState {
+	c := CursorState{Selecting: s.selecting}
+	c.Row, c.Col = m.Buffer.Position(s.head)
+	if s.selecting {
+		c.StartRow, c.StartCol = m.Buffer.Position(s.anchor)
+	}
+	return c
+}
+
+// spans returns all cursors in document order.
+func (m Model) spans() []span {
+	all := []span{m.spanOf(m.cursorState(), true)}
+	for _, c := range m.cursors {
+		all = append(all, m.spanOf(c, false))
+	}
+	slices.SortStableFunc(all, func(a, b span) int { return a.start() - b.start() })
+	return all
+}
+
+// setSpans replaces all cursors, merging the ones that touch or overlap.
+func (m Model) setSpans(all []span) Model {
+	slices.SortStableFunc(all, func(a, b span) int { return a.start() - b.start() })
+	var merged []span
+	for _, s := range all {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if s.start() < last.end() || s.start() == last.start() || (s.start() == last.end() && !s.selecting) {
+				if s.end() > last.end() {
+					if last.head >= last.anchor {
+						last.head = s.end()
+					} else {
+						last.anchor = s.end()
+					}
+					last.selecting = true
+				}
+				last.primary = last.primary || s.primary
+				continue
+			}
+		}
+		merged = append(merged, s)
+	}
+
+	m.cursors = nil
+	for _, s := range merged {
+		if s.primary {
+			m.setCursorState(m.cursorOf(s))
+		} else {
+			m.cursors = append(m.cursors, m.cursorOf(s))
+		}
+	}
+	return m
+}
+
+// forEachCursor calls f once for every cursor, with that cursor as the
+// primary one and its index in document order. The cursors are visited from
+// the end of the buffer to its start, so edits never move a cursor that is
+// still to be visited; the ones visited before are shifted by the change in
+// length instead.
+func (m Model) forEachCursor(f func(m Model, i int) Model) Model {
+	if len(m.cursors) == 0 {
+		return f(m, 0)
+	}
+	all := m.spans()
+	m.cursors = nil
+	var done []span
+	for i := len(all) - 1; i >= 0; i-- {
+		m.setCursorState(m.cursorOf(all[i]))
+		before := m.Buffer.Len()
+		m = f(m, i)
+		delta := m.Buffer.Len() - before
+		for j := range done {
+			done[j].anchor += delta
+			done[j].head += delta
+		}
+		s := m.spanOf(m.cursorState(), all[i].primary)
+		done = append(done, s)
+	}
+	return m.setSpans(done)
+}
+
+// collapseCursors removes all cursors but the primary one.
+func (m Model) collapseCursors() Model {
+	m.cursors = nil
+	return m
+}
+
+// selectedTexts returns the text of all selections in document order, one
+// per line.
+func (m Model) selectedTexts() (string, bool) {
+	if len(m.cursors) == 0 {
+		return m.getSelectedText(), m.selecting
+	}
+	content := m.Buffer.String()
+	var parts []string
+	for _, s := range m.spans() {
+		if s.selecting {
+			parts = append(parts, content[s.start():s.end()])
+		}
+	}
+	return strings.Join(parts, "\n"), len(parts) > 0
+}
+
+// splitForCursors splits pasted text into one line per cursor if it has
+// exactly as many lines as there are cursors, e.g. when it was copied from
+// the same number of selections. It returns nil otherwise.
+func (m Model) splitForCursors(text string) []string {
+	if len(m.cursors) == 0 {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) != len(m.cursors)+1 {
+		return nil
+	}
+	return lines
+}
+
+// addCursorVertically puts a new primary cursor on the line above (dir -1) or
+// below (dir 1) the primary one, keeping the old one.
+func (m Model) addCursorVertically(dir int) Model {
+	row := m.CursorRow + dir
+	if row < 0 || row >= m.Buffer.LineCount() {
+		m.statusMsg = "No more lines"
+		return m
+	}
+	old := m.cursorState()
+	m.cursors = append(slices.Clone(m.cursors), old)
+	m.setCursorState(CursorState{Row: row, Col: min(old.Col, m.Buffer.LineLen(row))})
+	m = m.setSpans(m.spans())
+	m.statusMsg = fmt.Sprintf("%d cursors", len(m.cursors)+1)
+	return m
+}
+
+// addNextOccurrence selects the word under the cursor, or if text is selected
+// already, adds a cursor selecting its next occurrence.
+func (m Model) addNextOccurrence() Model {
+	// An empty selection, e.g. after shift+right and shift+left or a mark just
+	// set, has no text to look for either.
+	if !m.selecting || (m.startRow == m.CursorRow && m.startCol == m.CursorCol) {
+		line := []rune(m.Buffer.Line(m.CursorRow))
+		start, end := m.CursorCol, m.CursorCol
+		for start > 0 && isWordRune(line[start-1]) {
+			start--
+		}
+		for end < len(line) && isWordRune(line[end]) {
+			end++
+		}
+		if start == end {
+			m.statusMsg = "No word under the cursor"
+			return m
+		}
+		m.selecting = true
+		m.startRow, m.startCol = m.CursorRow, start
+		m.CursorCol = end
+		return m
+	}
+
+	cur := m.spanOf(m.cursorState(), true)
+	content := m.Buffer.String()
+	word := content[cur.start():cur.end()]
+	taken := map[int]bool{}
+	for _, s := range m.spans() {
+		taken[s.start()] = true
+	}
+
+	from := cur.end()
+	for range 2 {
+		for {
+			i := strings.Index(content[from:], word)
+			if i < 0 {
+				break
+			}
+			start := from + i
+			from = start + len(word)
+			if taken[start] {
+				continue
+			}
+			m.cursors = append(slices.Clone(m.cursors), m.cursorState())
+			m.setCursorState(m.cursorOf(span{anchor: start, head: start + len(word), selecting: true}))
+			m = m.setSpans(m.spans())
+			m.statusMsg = fmt.Sprintf("%d cursors", len(m.cursors)+1)
+			return m
+		}
+		// Wrap around to the start of the buffer.
+		from = 0
+	}
+	m.statusMsg = "No more occurrences"
+	return m
+}
+
+// selectAllMatches puts a cursor on every search result, selecting it.
+func (m Model) selectAllMatches() Model {
+	if len(m.searchResults) == 0 {
+		m.statusMsg = "No matches"
+		return m
+	}
+	primary := max(m.currentResultIndex, 0)
+	var all []span
+	for i, r := range m.searchResults {
+		start := m.Buffer.Offset(r.Line, 0) + r.Col
+		all = append(all, span{anchor: start, head: start + r.Length, selecting: true, primary: i == primary})
+	}
+	m = m.setSpans(all)
+	m.statusMsg = fmt.Sprintf("%d cursors", len(m.cursors)+1)
+	return m
+}
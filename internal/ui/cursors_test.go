@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"larry/internal/buffer"
+	"larry/internal/config"
+)
+
+func TestAddNextOccurrenceEmptySelection(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Swap = false
+	cfg.Clipboard = []string{"internal"}
+	m := InitialModel("", buffer.New("foo bar foo"), cfg)
+
+	// As left by shift+right then shift+left, or by setting the mark.
+	m.selecting = true
+	m.startRow, m.startCol = 0, 1
+	m.CursorRow, m.CursorCol = 0, 1
+
+	done := make(chan Model)
+	go func() { done <- m.addNextOccurrence() }()
+	select {
+	case m = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("addNextOccurrence hangs on an empty selection")
+	}
+
+	if len(m.cursors) != 0 || !m.selecting || m.startCol != 0 || m.CursorCol != 3 {
+		t.Errorf("expected the word under the cursor to be selected, got cursor %d:%d from %d:%d and %d more cursors",
+			m.CursorRow, m.CursorCol, m.startRow, m.startCol, len(m.cursors))
+	}
+
+	m = m.addNextOccurrence()
+	if len(m.cursors) != 1 || m.startCol != 8 || m.CursorCol != 11 {
+		t.Errorf("expected a second cursor on the next foo, got %d:%d from %d:%d and %d more cursors",
+			m.CursorRow, m.CursorCol, m.startRow, m.startCol, len(m.cursors))
+	}
+}
@@ -0,0 +1,100 @@
+package ui
+
+import (
+	"larry/internal/buffer"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openFile opens the file at path in a new buffer, or switches to it if it is
+// already open, and puts the cursor on row. A negative row keeps the cursor
+// of an open buffer.
+func (m Model) openFile(path string, row int) (Model, tea.Cmd) {
+	if i := m.findBuffer(path); i >= 0 {
+		m, cmd := m.switchBuffer(i)
+		if row >= 0 {
+			m.CursorRow = clampRow(m.Buffer, row)
+			m.CursorCol = 0
+			m.selecting = false
+			m.cursors = nil
+			m.block = nil
+			m = m.updateViewport()
+		}
+		return m, cmd
+	}
+
+	return m.queueLoad(fileLoad{path: path, row: row})
+}
+
+// clampRow returns row limited to the lines of buf. A row past the part of a
+// large file indexed so far waits for the rest of the index.
+func clampRow(buf *buffer.Buffer, row int) int {
+	if row >= buf.LineCount() {
+		<-buf.Ready()
+	}
+	return min(max(row, 0), buf.LineCount()-1)
+}
+
+// showFile makes buf, just read from path, the current buffer and puts the
+// cursor on row.
+func (m Model) showFile(path string, row int, buf *buffer.Buffer) (Model, tea.Cmd) {
+	// An untouched empty buffer is replaced instead of kept around.
+	if m.FileName != "" || m.Modified || m.Buffer.Len() > 0 {
+		m = m.stash()
+		m.docs = append(m.docs, document{})
+		m.active = len(m.docs) - 1
+	}
+	m.Buffer = buf
+	m.FileName = path
+	m.CursorRow = clampRow(buf, row)
+	m.CursorCol = 0
+	m.top = visualLine{}
+	m.selecting = false
+	m.cursors = nil
+	m.block = nil
+	m.Modified = false
+	m.history = loadHistory(m.Config, path, buf)
+	m.viewMode = ViewModeEditor
+	m.markdownRenderer = nil
+	m.markdownCacheValid = false
+	m.searchResults = nil
+	m.currentResultIndex = -1
+	m = m.updateViewport()
+	m = m.checkSwap()
+	return m, WaitForLoadCmd(buf)
+}
+
+// saveFile writes the buffer to filename and makes it the buffer's file.
+func (m Model) saveFile(filename string) (Model, error) {
+	if err := m.Buffer.Save(filename, buffer.SaveOptions{Backup: m.Config.Backup}); err != nil {
+		return m, err
+	}
+	if filename != m.FileName {
+		m.discardSwap()
+	}
+	m.FileName = filename
+	m.Modified = false
+	m.discardSwap()
+	return m, nil
+}
+
+// save writes the buffer to filename and reports the result in the status
+// bar. Unless force is set, overwriting a file that changed on disk since it
+// was loaded has to be confirmed first.
+func (m Model) save(filename string, force bool) Model {
+	if !force && filename == m.FileName {
+		if _, changed, _ := m.Buffer.Stamp.Changed(filename); changed {
+			return m.confirmOverwrite(filename)
+		}
+	}
+	m, err := m.saveFile(filename)
+	if err != nil {
+		m.statusMsg = "Error saving: " + err.Error()
+		return m
+	}
+	m.statusMsg = "Saved: " + filename
+	if err := storeHistory(m.Config, m.FileName, m.Buffer, m.history); err != nil {
+		m.statusMsg += " (undo history not stored: " + err.Error() + ")"
+	}
+	return m
+}
@@ -15,8 +15,14 @@ type FinderMode int
 const (
 	FinderModeFile FinderMode = iota
 	FinderModeGrep
+	FinderModeBuffers
+	FinderModeClipboard
 )
 
+// clipPreviewLines is how many lines of the selected clipboard entry are
+// shown below the results.
+const clipPreviewLines = 5
+
 type FinderModel struct {
 	textInput textinput.Model
 	mode      FinderMode
@@ -28,6 +34,11 @@ type FinderModel struct {
 	grep      *search.LiveGrep
 	scanner   *search.DirectoryScanner
 	allFiles  []string
+	buffers   []string       // names of the open buffers, in order
+	clips     []string       // the clipboard history, oldest first
+	opts      search.Options // how GREP mode matches, kept between openings
+	keys      KeyMap         // for the keys toggling opts
+	err       string         // why the GREP pattern is invalid
 	loading   bool
 	root      string
 }
@@ -67,11 +78,17 @@ func (m FinderModel) Update(msg tea.Msg) (FinderModel, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "tab":
-			if m.mode == FinderModeFile {
+			switch m.mode {
+			case FinderModeFile:
 				m.mode = FinderModeGrep
-			} else {
+			case FinderModeGrep:
+				m.mode = FinderModeBuffers
+			case FinderModeBuffers:
+				m.mode = FinderModeClipboard
+			default:
 				m.mode = FinderModeFile
 			}
+			m.cursor = 0
 			return m, m.performSearch()
 
 		case "up":
@@ -82,6 +99,12 @@ func (m FinderModel) Update(msg tea.Msg) (FinderModel, tea.Cmd) {
 			if m.cursor < len(m.results)-1 {
 				m.cursor++
 			}
+		default:
+			if opts, ok := toggleOption(m.opts, msg, m.keys); ok && m.mode == FinderModeGrep {
+				m.opts = opts
+				m.cursor = 0
+				return m, m.performSearch()
+			}
 		}
 
 	case searchMsg:
@@ -105,6 +128,51 @@ func (m FinderModel) Update(msg tea.Msg) (FinderModel, tea.Cmd) {
 func (m *FinderModel) performSearch() tea.Cmd {
 	query := m.textInput.Value()
 	m.loading = true
+	m.err = ""
+
+	if m.mode == FinderModeBuffers {
+		buffers := m.buffers
+		matcher := m.matcher
+		return func() tea.Msg {
+			var results []search.FinderResult
+			for i, name := range buffers {
+				if matched, _ := matcher.Match(query, name); matched {
+					results = append(results, search.FinderResult{
+						Buffer: &search.BufferResult{Index: i, Name: name},
+						Mode:   search.ModeBuffers,
+					})
+				}
+			}
+			return searchMsg(results)
+		}
+	}
+
+	if m.mode == FinderModeClipboard {
+		clips := m.clips
+		matcher := m.matcher
+		return func() tea.Msg {
+			var results []search.FinderResult
+			for i := len(clips) - 1; i >= 0; i-- {
+				if matched, _ := matcher.Match(query, clips[i]); matched {
+					results = append(results, search.FinderResult{
+						Clip: &search.ClipResult{Index: i, Text: clips[i]},
+						Mode: search.ModeClipboard,
+					})
+				}
+			}
+			return searchMsg(results)
+		}
+	}
+
+	opts := m.opts
+	if m.mode == FinderModeGrep {
+		if _, err := search.NewSearcher(query, opts); err != nil {
+			m.err = patternError(err)
+			m.results = nil
+			m.loading = false
+			return nil
+		}
+	}
 
 	return func() tea.Msg {
 		if m.mode == FinderModeFile {
@@ -127,7 +195,7 @@ func (m *FinderModel) performSearch() tea.Cmd {
 			}
 			return searchMsg(results)
 		} else {
-			results, _ := m.grep.Search(m.root, query)
+			results, _ := m.grep.SearchWith(m.root, query, opts)
 			var finderResults []search.FinderResult
 			for i := range results {
 				finderResults = append(finderResults, search.FinderResult{
@@ -145,27 +213,27 @@ func (m *FinderModel) performSearch() tea.Cmd {
 
 func (m FinderModel) View() string {
 	var modeStr string
-	if m.mode == FinderModeFile {
+	switch m.mode {
+	case FinderModeFile:
 		modeStr = lipgloss.NewStyle().Background(lipgloss.Color("62")).Foreground(lipgloss.Color("255")).Padding(0, 1).Render(" FILES ")
-	} else {
+	case FinderModeGrep:
 		modeStr = lipgloss.NewStyle().Background(lipgloss.Color("160")).Foreground(lipgloss.Color("255")).Padding(0, 1).Render(" GREP ")
+	case FinderModeBuffers:
+		modeStr = lipgloss.NewStyle().Background(lipgloss.Color("28")).Foreground(lipgloss.Color("255")).Padding(0, 1).Render(" BUFFERS ")
+	default:
+		modeStr = lipgloss.NewStyle().Background(lipgloss.Color("130")).Foreground(lipgloss.Color("255")).Padding(0, 1).Render(" CLIPBOARD ")
 	}
 
 	header := lipgloss.JoinHorizontal(lipgloss.Center, modeStr, " ", m.textInput.View())
-
-	maxResults := m.height - 10
-	if maxResults < 5 {
-		maxResults = 5
+	if m.mode == FinderModeGrep {
+		header = lipgloss.JoinHorizontal(lipgloss.Center, header, optionBadges(m.opts))
 	}
 
+	start, maxResults := m.visibleResults()
+
 	var resultsView strings.Builder
 	count := 0
 
-	start := 0
-	if m.cursor >= maxResults {
-		start = m.cursor - maxResults + 1
-	}
-
 	for i := start; i < len(m.results) && count < maxResults; i++ {
 		res := m.results[i]
 		cursor := "  "
@@ -173,18 +241,24 @@ func (m FinderModel) View() string {
 			cursor = lipgloss.NewStyle().Foreground(lipgloss.Color("62")).Render("» ")
 		}
 
+		maxWidth := m.width - 15
+		if maxWidth < 10 {
+			maxWidth = 10
+		}
+
 		var line string
-		if res.Mode == search.ModeFiles {
+		switch res.Mode {
+		case search.ModeFiles:
 			line = res.File.Path
-		} else {
+		case search.ModeBuffers:
+			line = fmt.Sprintf("%d: %s", res.Buffer.Index+1, res.Buffer.Name)
+		case search.ModeClipboard:
+			line = clipSummary(res.Clip.Text, maxWidth)
+		default:
 			line = fmt.Sprintf("%s:%d: %s", res.Grep.Path, res.Grep.Line, res.Grep.Content)
 		}
 
-		maxWidth := m.width - 15
-		if maxWidth < 10 {
-			maxWidth = 10
-		}
-		if len(line) > maxWidth {
+		if len(line) > maxWidth && res.Mode != search.ModeClipboard {
 			line = "..." + line[len(line)-(maxWidth-3):]
 		}
 
@@ -196,7 +270,10 @@ func (m FinderModel) View() string {
 		count++
 	}
 
-	if len(m.results) == 0 && !m.loading {
+	if m.err != "" {
+		resultsView.WriteString("  " + lipgloss.NewStyle().Foreground(lipgloss.Color("160")).Render(m.err) + "\n")
+		count++
+	} else if len(m.results) == 0 && !m.loading {
 		resultsView.WriteString("  No results found.\n")
 		count++
 	} else if m.loading && len(m.results) == 0 {
@@ -209,5 +286,83 @@ func (m FinderModel) View() string {
 		count++
 	}
 
-	return lipgloss.JoinVertical(lipgloss.Left, header, "\n", resultsView.String())
+	view := lipgloss.JoinVertical(lipgloss.Left, header, "\n", resultsView.String())
+	if m.mode == FinderModeClipboard {
+		view = lipgloss.JoinVertical(lipgloss.Left, view, m.clipPreview())
+	}
+	return view
+}
+
+// clipSummary shows the start of text on one line of at most width
+// characters, with its line breaks as ↵ and its tabs as spaces.
+func clipSummary(text string, width int) string {
+	return truncateRunes(strings.NewReplacer("\n", "↵", "\t", " ").Replace(text), width)
+}
+
+// truncateRunes shortens s to width runes, ending it with … if it is cut.
+func truncateRunes(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return s
+}
+
+// clipPreview shows the start of the selected clipboard entry.
+func (m FinderModel) clipPreview() string {
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	var lines []string
+	if m.cursor < len(m.results) {
+		text := strings.ReplaceAll(m.results[m.cursor].Clip.Text, "\t", "    ")
+		lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+		if len(lines) > clipPreviewLines {
+			more := len(lines) - clipPreviewLines + 1
+			lines = append(lines[:clipPreviewLines-1], fmt.Sprintf("… %d more lines", more))
+		}
+	}
+	maxWidth := max(m.width-15, 10)
+	for i, line := range lines {
+		lines[i] = "  " + style.Render(truncateRunes(line, maxWidth))
+	}
+	for len(lines) < clipPreviewLines {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// finderResultsTop is the line of View showing the first result, below the
+// header and a blank line.
+const finderResultsTop = 3
+
+// visibleResults returns the index of the first result shown and how many
+// lines there are for results.
+func (m FinderModel) visibleResults() (int, int) {
+	maxResults := m.height - 10
+	if m.mode == FinderModeClipboard {
+		maxResults -= clipPreviewLines
+	}
+	if maxResults < 5 {
+		maxResults = 5
+	}
+
+	start := 0
+	if m.cursor >= maxResults {
+		start = m.cursor - maxResults + 1
+	}
+	return start, maxResults
+}
+
+// resultAt returns the index of the result shown on line y of View.
+func (m FinderModel) resultAt(y int) (int, bool) {
+	start, maxResults := m.visibleResults()
+	y -= finderResultsTop
+	if y < 0 || y >= maxResults || start+y >= len(m.results) {
+		return 0, false
+	}
+	return start + y, true
+}
+
+// moveCursor moves the cursor delta results down, or up if delta < 0.
+func (m FinderModel) moveCursor(delta int) FinderModel {
+	m.cursor = max(min(m.cursor+delta, len(m.results)-1), 0)
+	return m
 }
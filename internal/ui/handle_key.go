@@ -5,14 +5,42 @@ import (
 	"os"
 	"strings"
 
+	"larry/internal/buffer"
+
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
-	var cmd tea.Cmd
+	if m.registerAction != nil {
+		return m.registerKey(msg), nil
+	}
+	m, seq, cmd, ok := m.sequenceKey(msg)
+	if !ok {
+		return m, cmd
+	}
+	if m.vim != nil && seq.String() == msg.String() {
+		return m.vimKey(msg)
+	}
+	m, cmd = m.actionKey(seq)
+	// The mark is active as long as the selection it started.
+	if !m.selecting {
+		m.mark = false
+	}
+	return m, cmd
+}
+
+// actionKey handles a key bound to an action, or typed text.
+func (m Model) actionKey(msg tea.KeyMsg) (Model, tea.Cmd) {
+	// yank_pop only works right after a paste, consecutive kill_lines make
+	// one kill.
+	yanked, killing := m.yanked, m.killing
+	m.yanked, m.killing = nil, false
 
 	switch {
+	case msg.Paste:
+		return m.pasteText(string(msg.Runes)), nil
+
 	case key.Matches(msg, m.KeyMap.ToggleMarkdownPreview):
 		if isMarkdownFile(m.FileName) {
 			if m.viewMode == ViewModeSplit {
@@ -33,13 +61,38 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case key.Matches(msg, m.KeyMap.ToggleLineEnding):
+		if m.Buffer.Format.LineEnding == buffer.CRLF {
+			m.Buffer.Format.LineEnding = buffer.LF
+		} else {
+			m.Buffer.Format.LineEnding = buffer.CRLF
+		}
+		m.markModified()
+		m.statusMsg = "Line endings: " + m.Buffer.Format.LineEnding.String()
+		return m, nil
+
+	case key.Matches(msg, m.KeyMap.ReopenWithEncoding), key.Matches(msg, m.KeyMap.SaveWithEncoding):
+		m.choosingEncoding = true
+		m.encodingAction = encodingReopen
+		verb := "Reopen"
+		if key.Matches(msg, m.KeyMap.SaveWithEncoding) {
+			m.encodingAction = encodingSave
+			verb = "Save"
+		}
+		m.textInput.Focus()
+		m.textInput.SetValue(m.Buffer.Format.Encoding.String())
+		m.textInput.Prompt = verb + " with encoding (utf-8, utf-16le, utf-16be, latin1, cp1252): "
+		return m, nil
+
 	case key.Matches(msg, m.KeyMap.ToggleHelp):
 		m.showHelp = !m.showHelp
 		return m, nil
 
 	case key.Matches(msg, m.KeyMap.Quit):
-		m.Quitting = true
-		return m, tea.Quit
+		return m.confirmQuit()
+
+	case key.Matches(msg, m.KeyMap.SaveAllQuit):
+		return m.saveAllAndQuit()
 
 	case key.Matches(msg, m.KeyMap.Save):
 		m.saving = true
@@ -52,32 +105,24 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 		m.goToLine = true
 		m.textInput.Focus()
 		m.textInput.SetValue("")
-		lineCount := len(m.Lines)
-		if lineCount == 0 {
-			lineCount = 1
-		}
-		m.textInput.Prompt = fmt.Sprintf("Go to line (1-%d): ", lineCount)
+		m.textInput.Prompt = fmt.Sprintf("Go to line (1-%d): ", m.Buffer.LineCount())
 		return m, nil
 
 	case key.Matches(msg, m.KeyMap.Search):
-		m.searching = true
-		m.replacing = false
-		m.replaceResults = nil
-		m.textInput.Focus()
-		m.textInput.SetValue(m.searchQuery)
-		m.textInput.Prompt = "Search: "
-		return m, nil
+		return m.startSearch(), nil
+
 	case key.Matches(msg, m.KeyMap.Replace):
 		m.replacing = true
 		m.searching = false
 		m.searchResults = nil
+		m.searchErr = ""
 		m.replaceStep = 1
 		m.textInput.Focus()
 		m.textInput.SetValue(m.replaceQuery)
 		m.textInput.Prompt = "Find: "
 		return m, nil
 
-	case key.Matches(msg, m.KeyMap.GlobalFinder):
+	case key.Matches(msg, m.KeyMap.GlobalFinder), key.Matches(msg, m.KeyMap.BufferList), key.Matches(msg, m.KeyMap.ClipboardHistory):
 		m.finding = true
 		finderWidth := m.Width
 		if finderWidth > 120 {
@@ -87,9 +132,47 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 		if finderHeight > 25 {
 			finderHeight = 25
 		}
+		opts := m.finder.opts
 		m.finder = NewFinderModel(finderWidth, finderHeight)
+		m.finder.opts = opts
+		m.finder.keys = m.KeyMap
+		m.finder.buffers = m.bufferNames()
+		m.finder.clips = m.killRing
+		switch {
+		case key.Matches(msg, m.KeyMap.BufferList):
+			m.finder.mode = FinderModeBuffers
+		case key.Matches(msg, m.KeyMap.ClipboardHistory):
+			m.finder.mode = FinderModeClipboard
+		}
 		return m, m.finder.performSearch()
 
+	case key.Matches(msg, m.KeyMap.NextBuffer):
+		return m.nextBuffer()
+
+	case key.Matches(msg, m.KeyMap.PrevBuffer):
+		return m.prevBuffer()
+
+	case key.Matches(msg, m.KeyMap.CloseBuffer):
+		return m.confirmClose()
+
+	case key.Matches(msg, m.KeyMap.SplitVertical):
+		return m.splitPane(splitVertical), nil
+
+	case key.Matches(msg, m.KeyMap.SplitHorizontal):
+		return m.splitPane(splitHorizontal), nil
+
+	case key.Matches(msg, m.KeyMap.NextPane):
+		return m.nextPane()
+
+	case key.Matches(msg, m.KeyMap.ClosePane):
+		return m.closePane()
+
+	case key.Matches(msg, m.KeyMap.GrowPane):
+		return m.resizePane(paneResizeStep), nil
+
+	case key.Matches(msg, m.KeyMap.ShrinkPane):
+		return m.resizePane(-paneResizeStep), nil
+
 	case key.Matches(msg, m.KeyMap.Open):
 		m.loading = true
 		m.filePicker.CurrentDirectory, _ = os.Getwd()
@@ -103,55 +186,164 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 		m = m.redo()
 		return m, nil
 
-	case key.Matches(msg, m.KeyMap.SelectAll):
-		m.startRow = 0
-		m.startCol = 0
-		m.CursorRow = len(m.Lines) - 1
-		if m.CursorRow < 0 {
-			m.CursorRow = 0
-		}
-		m.CursorCol = len([]rune(m.Lines[m.CursorRow]))
-		m.selecting = true
+	case key.Matches(msg, m.KeyMap.Earlier):
+		m = m.earlier()
+		return m, nil
+
+	case key.Matches(msg, m.KeyMap.Later):
+		m = m.later()
+		return m, nil
+
+	case key.Matches(msg, m.KeyMap.UndoHistory):
+		m.historyView = &historyViewer{selected: m.history.Current}
+		return m, nil
+
+	case key.Matches(msg, m.KeyMap.Cut) && m.block != nil:
+		text := m.blockText()
+		m = m.kill(text, false)
+		m.blockYank = text
+		m = m.deleteBlock()
+		m.statusMsg = m.toClipboard(text, "Cut block")
+		return m, nil
+
+	case key.Matches(msg, m.KeyMap.Copy) && m.block != nil:
+		text := m.blockText()
+		m = m.kill(text, false)
+		m.blockYank = text
+		m.statusMsg = m.toClipboard(text, "Copied block")
 		return m, nil
 
 	case key.Matches(msg, m.KeyMap.Cut):
-		if m.selecting {
-			text := m.getSelectedText()
-			err := m.clipboardWrite(text)
-			if err != nil {
-				m.statusMsg = "Cut Error: " + err.Error()
-			} else {
-				m.pushUndo(EditOp{Type: OpDelete, Row: m.startRow, Col: m.startCol, Text: text})
-				m = m.deleteSelectedText()
-				m.statusMsg = "Cut to clipboard"
-			}
+		if text, ok := m.selectedTexts(); ok {
+			m = m.kill(text, false)
+			m = m.forEachCursor(func(m Model, _ int) Model {
+				return m.deleteSelection()
+			})
+			m.statusMsg = m.toClipboard(text, "Cut")
 		}
 		return m, nil
 
 	case key.Matches(msg, m.KeyMap.Copy):
-		if m.selecting {
-			text := m.getSelectedText()
-			err := m.clipboardWrite(text)
-			if err != nil {
-				m.statusMsg = "Copy Error: " + err.Error()
-			} else {
+		if text, ok := m.selectedTexts(); ok {
+			m = m.kill(text, false)
+			m = m.forEachCursor(func(m Model, _ int) Model {
 				m.selecting = false
-				m.statusMsg = "Copied to clipboard"
-			}
+				return m
+			})
+			m.statusMsg = m.toClipboard(text, "Copied")
 		}
 		return m, nil
 
 	case key.Matches(msg, m.KeyMap.Paste):
-		text, err := m.clipboardRead()
+		var text string
+		var err error
+		m, text, err = m.yankText()
 		if err != nil {
 			m.statusMsg = "Paste Error: " + err.Error()
 		} else {
-			m.pushUndo(EditOp{Type: OpInsert, Row: m.CursorRow, Col: m.CursorCol, Text: text})
-			m = m.insertTextAtCursor(text)
+			if m.block != nil && !strings.Contains(text, "\n") {
+				m = m.typeBlock(text)
+				m.statusMsg = "Pasted on every line"
+				return m, nil
+			}
+			if m.block != nil || (text == m.blockYank && text != "") {
+				m = m.pasteBlock(text)
+				m.statusMsg = "Pasted block from clipboard"
+				return m, nil
+			}
+			if len(m.cursors) == 0 && len(m.killRing) > 0 && m.killRing[len(m.killRing)-1] == text {
+				m.yanked = &yankState{m.CursorRow, m.CursorCol, len(m.killRing) - 1}
+			}
+			lines := m.splitForCursors(text)
+			m = m.forEachCursor(func(m Model, i int) Model {
+				if lines != nil {
+					text = lines[i]
+				}
+				m.pushUndo(EditOp{Type: OpInsert, Row: m.CursorRow, Col: m.CursorCol, Text: text})
+				return m.insertTextAtCursor(text)
+			})
 			m.statusMsg = "Pasted from clipboard"
 		}
 		return m, nil
 
+	case key.Matches(msg, m.KeyMap.SetMark):
+		return m.setMark(), nil
+
+	case key.Matches(msg, m.KeyMap.KillLine):
+		return m.killLine(killing), nil
+
+	case key.Matches(msg, m.KeyMap.YankPop):
+		return m.yankPop(yanked), nil
+
+	case key.Matches(msg, m.KeyMap.CopyToRegister):
+		return m.copyToRegister(), nil
+
+	case key.Matches(msg, m.KeyMap.PasteFromRegister):
+		return m.pasteFromRegister(), nil
+
+	case key.Matches(msg, m.KeyMap.AddCursorAbove):
+		return m.addCursorVertically(-1), nil
+
+	case key.Matches(msg, m.KeyMap.AddCursorBelow):
+		return m.addCursorVertically(1), nil
+
+	case key.Matches(msg, m.KeyMap.AddNextOccurrence):
+		return m.addNextOccurrence(), nil
+
+	case msg.Type == tea.KeyEsc && m.mark:
+		return m.setMark(), nil
+
+	case msg.Type == tea.KeyEsc && len(m.cursors) > 0:
+		return m.collapseCursors(), nil
+
+	case key.Matches(msg, m.KeyMap.BlockSelectUp):
+		return m.extendBlock(-1, 0), nil
+
+	case key.Matches(msg, m.KeyMap.BlockSelectDown):
+		return m.extendBlock(1, 0), nil
+
+	case key.Matches(msg, m.KeyMap.BlockSelectLeft):
+		return m.extendBlock(0, -1), nil
+
+	case key.Matches(msg, m.KeyMap.BlockSelectRight):
+		return m.extendBlock(0, 1), nil
+
+	case m.block != nil:
+		return m.blockKey(msg), nil
+
+	default:
+		m = m.forEachCursor(func(m Model, _ int) Model {
+			return m.cursorKey(msg)
+		})
+	}
+
+	return m, nil
+}
+
+// startSearch opens the search prompt.
+func (m Model) startSearch() Model {
+	m.searching = true
+	m.replacing = false
+	m.replaceResults = nil
+	m.searchErr = ""
+	m.textInput.Focus()
+	m.textInput.SetValue(m.searchQuery)
+	m.textInput.Prompt = "Search: "
+	return m
+}
+
+// cursorKey handles the keys that move or edit at the cursor. With several
+// cursors it is called once for each of them, see forEachCursor. While the
+// mark is active, moving the cursor extends the selection instead of ending
+// it.
+func (m Model) cursorKey(msg tea.KeyMsg) Model {
+	switch {
+	case key.Matches(msg, m.KeyMap.SelectAll):
+		m.startRow = 0
+		m.startCol = 0
+		m.CursorRow, m.CursorCol = MoveToFileEnd(m.Buffer)
+		m.selecting = true
+
 	case key.Matches(msg, m.KeyMap.CursorUp) || key.Matches(msg, m.KeyMap.MoveSelectionUp):
 		if key.Matches(msg, m.KeyMap.MoveSelectionUp) {
 			if !m.selecting {
@@ -159,15 +351,13 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
 		if m.CursorRow > 0 {
 			m.CursorRow--
-			if m.CursorRow < len(m.Lines) {
-				lineLen := len([]rune(m.Lines[m.CursorRow]))
-				if m.CursorCol > lineLen {
-					m.CursorCol = lineLen
-				}
+			lineLen := m.Buffer.LineLen(m.CursorRow)
+			if m.CursorCol > lineLen {
+				m.CursorCol = lineLen
 			}
 		}
 
@@ -178,11 +368,11 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
-		if m.CursorRow < len(m.Lines)-1 {
+		if m.CursorRow < m.Buffer.LineCount()-1 {
 			m.CursorRow++
-			lineLen := len([]rune(m.Lines[m.CursorRow]))
+			lineLen := m.Buffer.LineLen(m.CursorRow)
 			if m.CursorCol > lineLen {
 				m.CursorCol = lineLen
 			}
@@ -195,13 +385,13 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
 		if m.CursorCol > 0 {
 			m.CursorCol--
 		} else if m.CursorRow > 0 {
 			m.CursorRow--
-			m.CursorCol = len([]rune(m.Lines[m.CursorRow]))
+			m.CursorCol = m.Buffer.LineLen(m.CursorRow)
 		}
 
 	case key.Matches(msg, m.KeyMap.CursorRight) || key.Matches(msg, m.KeyMap.MoveSelectionRight):
@@ -211,12 +401,12 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
-		lineLen := len([]rune(m.Lines[m.CursorRow]))
+		lineLen := m.Buffer.LineLen(m.CursorRow)
 		if m.CursorCol < lineLen {
 			m.CursorCol++
-		} else if m.CursorRow < len(m.Lines)-1 {
+		} else if m.CursorRow < m.Buffer.LineCount()-1 {
 			m.CursorRow++
 			m.CursorCol = 0
 		}
@@ -228,9 +418,9 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
-		m.CursorRow, m.CursorCol = FindNextWordBoundary(m.Lines, m.CursorRow, m.CursorCol)
+		m.CursorRow, m.CursorCol = FindNextWordBoundary(m.Buffer, m.CursorRow, m.CursorCol)
 
 	case key.Matches(msg, m.KeyMap.JumpWordLeft) || key.Matches(msg, m.KeyMap.SelectWordLeft):
 		if key.Matches(msg, m.KeyMap.SelectWordLeft) {
@@ -239,9 +429,9 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
-		m.CursorRow, m.CursorCol = FindPrevWordBoundary(m.Lines, m.CursorRow, m.CursorCol)
+		m.CursorRow, m.CursorCol = FindPrevWordBoundary(m.Buffer, m.CursorRow, m.CursorCol)
 
 	case key.Matches(msg, m.KeyMap.JumpLinesUp) || key.Matches(msg, m.KeyMap.SelectLinesUp):
 		if key.Matches(msg, m.KeyMap.SelectLinesUp) {
@@ -250,9 +440,9 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
-		m.CursorRow, m.CursorCol = JumpLinesUp(m.Lines, m.CursorRow, m.CursorCol)
+		m.CursorRow, m.CursorCol = JumpLinesUp(m.Buffer, m.CursorRow, m.CursorCol)
 
 	case key.Matches(msg, m.KeyMap.JumpLinesDown) || key.Matches(msg, m.KeyMap.SelectLinesDown):
 		if key.Matches(msg, m.KeyMap.SelectLinesDown) {
@@ -261,9 +451,9 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
-		m.CursorRow, m.CursorCol = JumpLinesDown(m.Lines, m.CursorRow, m.CursorCol)
+		m.CursorRow, m.CursorCol = JumpLinesDown(m.Buffer, m.CursorRow, m.CursorCol)
 
 	case key.Matches(msg, m.KeyMap.LineStart) || key.Matches(msg, m.KeyMap.SelectToLineStart):
 		if key.Matches(msg, m.KeyMap.SelectToLineStart) {
@@ -272,7 +462,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
 		m.CursorRow, m.CursorCol = MoveToLineStart(m.CursorRow, m.CursorCol)
 
@@ -283,31 +473,27 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.startRow, m.startCol = m.CursorRow, m.CursorCol
 			}
 		} else {
-			m.selecting = false
+			m.selecting = m.mark
 		}
-		m.CursorRow, m.CursorCol = MoveToLineEnd(m.Lines, m.CursorRow)
+		m.CursorRow, m.CursorCol = MoveToLineEnd(m.Buffer, m.CursorRow)
 
 	case key.Matches(msg, m.KeyMap.FileStart):
-		m.selecting = false
+		m.selecting = m.mark
 		m.CursorRow, m.CursorCol = MoveToFileStart()
 
 	case key.Matches(msg, m.KeyMap.FileEnd):
-		m.selecting = false
-		m.CursorRow, m.CursorCol = MoveToFileEnd(m.Lines)
+		m.selecting = m.mark
+		m.CursorRow, m.CursorCol = MoveToFileEnd(m.Buffer)
 
-	case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+	case (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace) && !m.KeyMap.bound(msg):
+		m = m.dropEmptyRegion()
 		if m.selecting {
-			text := m.getSelectedText()
-			m.pushUndo(EditOp{Type: OpDelete, Row: m.startRow, Col: m.startCol, Text: text})
-			m = m.deleteSelectedText()
+			m = m.deleteSelection()
+		} else {
+			m.setEditKind(editTyping)
 		}
-		if m.CursorRow >= 0 && m.CursorRow < len(m.Lines) {
+		if m.CursorRow >= 0 && m.CursorRow < m.Buffer.LineCount() {
 			m.markModified()
-			m.pushUndo(EditOp{Type: OpInsert, Row: m.CursorRow, Col: m.CursorCol, Text: string(msg.Runes)})
-
-			line := []rune(m.Lines[m.CursorRow])
-			prefix := line[:m.CursorCol]
-			suffix := line[m.CursorCol:]
 
 			var runes []rune
 			if msg.Type == tea.KeySpace {
@@ -316,34 +502,26 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				runes = msg.Runes
 			}
 
-			newLine := append(prefix, append(runes, suffix...)...)
-			m.Lines[m.CursorRow] = string(newLine)
-			m.CursorCol += len(runes)
+			m.pushUndo(EditOp{Type: OpInsert, Row: m.CursorRow, Col: m.CursorCol, Text: string(runes)})
+			m.CursorRow, m.CursorCol = m.Buffer.Insert(m.CursorRow, m.CursorCol, string(runes))
 		}
 
 	case msg.Type == tea.KeyBackspace || msg.Type == tea.KeyDelete || key.Matches(msg, m.KeyMap.Delete):
+		m = m.dropEmptyRegion()
 		if m.selecting {
-			text := m.getSelectedText()
-			m.pushUndo(EditOp{Type: OpDelete, Row: m.startRow, Col: m.startCol, Text: text})
-			m = m.deleteSelectedText()
+			m = m.deleteSelection()
 		} else {
 			m.markModified()
+			m.setEditKind(editDeleting)
 			if m.CursorCol > 0 {
-				line := []rune(m.Lines[m.CursorRow])
-				deletedChar := string(line[m.CursorCol-1])
+				deletedChar := m.Buffer.Delete(m.CursorRow, m.CursorCol-1, m.CursorRow, m.CursorCol)
 				m.pushUndo(EditOp{Type: OpDelete, Row: m.CursorRow, Col: m.CursorCol - 1, Text: deletedChar})
-
-				newLine := append(line[:m.CursorCol-1], line[m.CursorCol:]...)
-				m.Lines[m.CursorRow] = string(newLine)
 				m.CursorCol--
 			} else if m.CursorRow > 0 {
-				prevLine := m.Lines[m.CursorRow-1]
-				currLine := m.Lines[m.CursorRow]
-				m.pushUndo(EditOp{Type: OpDelete, Row: m.CursorRow - 1, Col: len([]rune(prevLine)), Text: "\n"})
+				newCol := m.Buffer.LineLen(m.CursorRow - 1)
+				m.pushUndo(EditOp{Type: OpDelete, Row: m.CursorRow - 1, Col: newCol, Text: "\n"})
 
-				newCol := len([]rune(prevLine))
-				m.Lines[m.CursorRow-1] = prevLine + currLine
-				m.Lines = append(m.Lines[:m.CursorRow], m.Lines[m.CursorRow+1:]...)
+				m.Buffer.Delete(m.CursorRow-1, newCol, m.CursorRow, 0)
 				m.CursorRow--
 				m.CursorCol = newCol
 			}
@@ -351,7 +529,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 
 	case msg.Type == tea.KeyTab:
 		if m.selecting {
-			m.Modified = true
+			m.markModified()
 			startRow, _ := m.startRow, m.startCol
 			endRow, _ := m.CursorRow, m.CursorCol
 			if startRow > endRow {
@@ -361,22 +539,21 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			tab := strings.Repeat(" ", m.Config.TabWidth)
 			for i := startRow; i <= endRow; i++ {
 				m.pushUndo(EditOp{Type: OpInsert, Row: i, Col: 0, Text: tab})
-				line := m.Lines[i]
-				m.Lines[i] = tab + line
+				m.Buffer.Insert(i, 0, tab)
 			}
 			m.startCol += len(tab)
 			m.CursorCol += len(tab)
-			return m, nil
+			return m
 		}
 
 		tab := strings.Repeat(" ", m.Config.TabWidth)
 		m.pushUndo(EditOp{Type: OpInsert, Row: m.CursorRow, Col: m.CursorCol, Text: tab})
 		m = m.insertTextAtCursor(tab)
-		return m, nil
+		return m
 
 	case msg.Type == tea.KeyShiftTab:
 		if m.selecting {
-			m.Modified = true
+			m.markModified()
 			startRow, _ := m.startRow, m.startCol
 			endRow, _ := m.CursorRow, m.CursorCol
 			if startRow > endRow {
@@ -384,7 +561,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			}
 
 			for i := startRow; i <= endRow; i++ {
-				line := m.Lines[i]
+				line := m.Buffer.Line(i)
 				spacesToRemove := 0
 				for j, r := range line {
 					if j >= m.Config.TabWidth {
@@ -400,7 +577,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				if spacesToRemove > 0 {
 					dedentText := line[:spacesToRemove]
 					m.pushUndo(EditOp{Type: OpDelete, Row: i, Col: 0, Text: dedentText})
-					m.Lines[i] = line[spacesToRemove:]
+					m.Buffer.Delete(i, 0, i, spacesToRemove)
 					if i == m.startRow {
 						m.startCol -= spacesToRemove
 						if m.startCol < 0 {
@@ -415,10 +592,10 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 					}
 				}
 			}
-			return m, nil
+			return m
 		}
-		if m.CursorRow >= 0 && m.CursorRow < len(m.Lines) {
-			line := m.Lines[m.CursorRow]
+		if m.CursorRow >= 0 && m.CursorRow < m.Buffer.LineCount() {
+			line := m.Buffer.Line(m.CursorRow)
 			spacesToRemove := 0
 			for i, r := range line {
 				if i >= m.Config.TabWidth {
@@ -435,41 +612,27 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.markModified()
 				dedentText := line[:spacesToRemove]
 				m.pushUndo(EditOp{Type: OpDelete, Row: m.CursorRow, Col: 0, Text: dedentText})
-				m.Lines[m.CursorRow] = line[spacesToRemove:]
+				m.Buffer.Delete(m.CursorRow, 0, m.CursorRow, spacesToRemove)
 				m.CursorCol -= spacesToRemove
 				if m.CursorCol < 0 {
 					m.CursorCol = 0
 				}
 			}
 		}
-		return m, nil
+		return m
 
 	case msg.Type == tea.KeyEnter:
+		m = m.dropEmptyRegion()
 		if m.selecting {
-			text := m.getSelectedText()
-			m.pushUndo(EditOp{Type: OpDelete, Row: m.startRow, Col: m.startCol, Text: text})
-			m = m.deleteSelectedText()
+			m = m.deleteSelection()
 		}
 
 		m.markModified()
 		m.pushUndo(EditOp{Type: OpInsert, Row: m.CursorRow, Col: m.CursorCol, Text: "\n"})
 
-		if m.CursorRow >= 0 && m.CursorRow < len(m.Lines) {
-			line := []rune(m.Lines[m.CursorRow])
-			prefix := line[:m.CursorCol]
-			suffix := line[m.CursorCol:]
-
-			m.Lines[m.CursorRow] = string(prefix)
-			newLines := make([]string, 0, len(m.Lines)+1)
-			newLines = append(newLines, m.Lines[:m.CursorRow+1]...)
-			newLines = append(newLines, string(suffix))
-			newLines = append(newLines, m.Lines[m.CursorRow+1:]...)
-			m.Lines = newLines
-
-			m.CursorRow++
-			m.CursorCol = 0
+		if m.CursorRow >= 0 && m.CursorRow < m.Buffer.LineCount() {
+			m.CursorRow, m.CursorCol = m.Buffer.Insert(m.CursorRow, m.CursorCol, "\n")
 		}
 	}
-
-	return m, cmd
+	return m
 }
@@ -22,21 +22,51 @@ func debugKeysEnabled() bool {
 	return os.Getenv("LARRY_DEBUG_KEYS") != ""
 }
 
-func (m Model) getVisualLineCount(lineNum int, textWidth int) int {
-	if lineNum < 0 || lineNum >= len(m.Lines) {
-		return 0
+// promptActive reports whether a prompt is shown below the editor.
+func (m Model) promptActive() bool {
+	return m.prompt != nil || m.saving || m.goToLine || m.searching || m.replacing || m.choosingEncoding
+}
+
+// textWidth returns the width left for the text of an editor view of the
+// given width, next to the border and the line numbers.
+func (m Model) textWidth(width int) int {
+	if m.Config.LineNumbers {
+		width -= 6
 	}
-	line := m.Lines[lineNum]
-	if line == "" {
-		return 1
+	return max(width-1, 1)
+}
+
+// wrapLine returns the indexes of the runes starting the visual lines that
+// line is wrapped into at textWidth. An empty line has one visual line.
+func (m Model) wrapLine(line []rune, textWidth int) []int {
+	starts := []int{0}
+	visualWidth := 0
+	for i, r := range line {
+		charWidth := 1
+		if r == '\t' {
+			charWidth = m.Config.TabWidth
+		}
+		if visualWidth+charWidth > textWidth {
+			starts = append(starts, i)
+			visualWidth = charWidth
+		} else {
+			visualWidth += charWidth
+		}
 	}
+	return starts
+}
 
+func (m Model) getVisualLineCount(lineNum int, textWidth int) int {
+	if lineNum < 0 || lineNum >= m.Buffer.LineCount() {
+		return 0
+	}
+	// As wrapLine, without converting the line to runes.
 	visualWidth := 0
 	count := 1
-	for _, r := range line {
+	for _, r := range m.Buffer.Line(lineNum) {
 		charWidth := 1
 		if r == '\t' {
-			charWidth = 4
+			charWidth = m.Config.TabWidth
 		}
 		if visualWidth+charWidth > textWidth {
 			count++
@@ -49,9 +79,8 @@ func (m Model) getVisualLineCount(lineNum int, textWidth int) int {
 }
 
 // getCursorVisualOffset returns the visual line index of the cursor RELATIVE to the start of the current line.
-// To get absolute visual position relative to viewport top, we need to sum visual heights of lines between yOffset and CursorRow.
 func (m Model) getCursorVisualOffset(textWidth int) int {
-	line := []rune(m.Lines[m.CursorRow])
+	line := []rune(m.Buffer.Line(m.CursorRow))
 	currentLineVisualLine := 0
 	visualWidth := 0
 	for i := 0; i < m.CursorCol && i < len(line); i++ {
@@ -69,63 +98,81 @@ func (m Model) getCursorVisualOffset(textWidth int) int {
 	return currentLineVisualLine
 }
 
-func (m Model) updateViewport() Model {
-	textWidth := m.Width
-	viewportHeight := m.Height - 1
-
-	if m.viewMode == ViewModeSplit {
-		textWidth = m.Width / 2
-		viewportHeight = m.Height - 1
-	}
-
-	if m.saving || m.goToLine || m.searching || m.replacing {
-		viewportHeight -= 2
-	}
-	if viewportHeight < 1 {
-		viewportHeight = 1
-	}
-
-	if m.Config.LineNumbers {
-		textWidth -= 6
-	}
-	textWidth -= 1
-	if textWidth < 1 {
-		textWidth = 1
-	}
+// visualLine is the visual line wrap of the buffer line row, as wrapped by
+// wrapLine. The top of the view is kept as one, so that scrolling only looks
+// at the lines it moves over instead of every line above the view.
+type visualLine struct {
+	row, wrap int
+}
 
-	// Calculate absolute visual position of the cursor
-	cursorVisualAbsPos := 0
-	// 1. Sum visual height of all lines BEFORE CursorRow
-	for i := 0; i < m.CursorRow; i++ {
-		cursorVisualAbsPos += m.getVisualLineCount(i, textWidth)
-	}
-	// 2. Add cursor's visual offset within the current line
-	cursorVisualAbsPos += m.getCursorVisualOffset(textWidth)
+func (v visualLine) before(w visualLine) bool {
+	return v.row < w.row || (v.row == w.row && v.wrap < w.wrap)
+}
 
-	// Adjust yOffset to keep cursor in view
-	// yOffset represents the absolute visual line index at the top of the viewport
+// clampVisual moves v onto the buffer, e.g. after the lines at the top of
+// the view were deleted or the view got wider.
+func (m Model) clampVisual(v visualLine, textWidth int) visualLine {
+	v.row = min(max(v.row, 0), m.Buffer.LineCount()-1)
+	v.wrap = min(max(v.wrap, 0), m.getVisualLineCount(v.row, textWidth)-1)
+	return v
+}
 
-	// If cursor is above the viewport, scroll up
-	if cursorVisualAbsPos < m.yOffset {
-		m.yOffset = cursorVisualAbsPos
+// moveVisual returns the visual line n lines below v, or above it if n < 0,
+// stopping at the start and the end of the buffer.
+func (m Model) moveVisual(v visualLine, n, textWidth int) visualLine {
+	last := m.Buffer.LineCount() - 1
+	for n > 0 {
+		count := m.getVisualLineCount(v.row, textWidth)
+		if v.wrap+n < count || v.row == last {
+			v.wrap = min(v.wrap+n, count-1)
+			return v
+		}
+		n -= count - v.wrap
+		v = visualLine{row: v.row + 1}
 	}
-
-	// If cursor is below the viewport, scroll down
-	// Visible range is [yOffset, yOffset + viewportHeight)
-	// So we need: cursorVisualAbsPos < yOffset + viewportHeight
-	// => yOffset > cursorVisualAbsPos - viewportHeight
-	if cursorVisualAbsPos >= m.yOffset+viewportHeight {
-		m.yOffset = cursorVisualAbsPos - viewportHeight + 1
+	for n < 0 {
+		if v.wrap+n >= 0 || v.row == 0 {
+			v.wrap = max(v.wrap+n, 0)
+			return v
+		}
+		n += v.wrap + 1
+		v = visualLine{row: v.row - 1}
+		v.wrap = m.getVisualLineCount(v.row, textWidth) - 1
 	}
+	return v
+}
 
-	// Ensure yOffset is not negative
-	if m.yOffset < 0 {
-		m.yOffset = 0
+// visualDistance returns how many visual lines w is below v, but at most
+// limit.
+func (m Model) visualDistance(v, w visualLine, limit, textWidth int) int {
+	n := 0
+	for v.row < w.row {
+		n += m.getVisualLineCount(v.row, textWidth) - v.wrap
+		if n >= limit {
+			return limit
+		}
+		v = visualLine{row: v.row + 1}
 	}
+	return min(n+w.wrap-v.wrap, limit)
+}
 
-	if m.CursorRow == 0 {
-		m.yOffset = 0
+// updateViewport scrolls the view of the focused pane so that it shows the
+// cursor. Only the visual lines between the old top of the view and the
+// cursor are looked at, and at most a screenful of them.
+func (m Model) updateViewport() Model {
+	paneWidth, viewportHeight := m.paneSize(m.focus)
+	textWidth := m.textWidth(m.editorWidth(paneWidth))
+	viewportHeight = max(viewportHeight, 1)
+
+	cursor := visualLine{m.CursorRow, m.getCursorVisualOffset(textWidth)}
+	m.top = m.clampVisual(m.top, textWidth)
+	switch {
+	case cursor.before(m.top):
+		// The cursor is above the view, scroll up to it.
+		m.top = cursor
+	case m.visualDistance(m.top, cursor, viewportHeight, textWidth) >= viewportHeight:
+		// The cursor is below the view, scroll down to show it on the last line.
+		m.top = m.moveVisual(cursor, 1-viewportHeight, textWidth)
 	}
-
 	return m
 }
@@ -1,9 +1,18 @@
 package ui
 
-import "github.com/charmbracelet/bubbles/key"
+import (
+	"slices"
+	"strings"
+
+	"larry/internal/keymap"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
 
 type KeyMap struct {
 	Quit               key.Binding
+	SaveAllQuit        key.Binding
 	SelectAll          key.Binding
 	MoveSelectionDown  key.Binding
 	MoveSelectionUp    key.Binding
@@ -21,6 +30,9 @@ type KeyMap struct {
 	Delete             key.Binding
 	Undo               key.Binding
 	Redo               key.Binding
+	Earlier            key.Binding
+	Later              key.Binding
+	UndoHistory        key.Binding
 	GoToLine           key.Binding
 	ToggleHelp         key.Binding
 	Search             key.Binding
@@ -42,50 +54,214 @@ type KeyMap struct {
 	SelectToLineStart     key.Binding
 	SelectToLineEnd       key.Binding
 	ToggleMarkdownPreview key.Binding
+	ToggleLineEnding      key.Binding
+	ReopenWithEncoding    key.Binding
+	SaveWithEncoding      key.Binding
+	NextBuffer            key.Binding
+	PrevBuffer            key.Binding
+	CloseBuffer           key.Binding
+	BufferList            key.Binding
+	SplitVertical         key.Binding
+	SplitHorizontal       key.Binding
+	NextPane              key.Binding
+	ClosePane             key.Binding
+	GrowPane              key.Binding
+	ShrinkPane            key.Binding
+	AddCursorAbove        key.Binding
+	AddCursorBelow        key.Binding
+	AddNextOccurrence     key.Binding
+	SelectAllMatches      key.Binding
+	BlockSelectUp         key.Binding
+	BlockSelectDown       key.Binding
+	BlockSelectLeft       key.Binding
+	BlockSelectRight      key.Binding
+	SetMark               key.Binding
+	KillLine              key.Binding
+	YankPop               key.Binding
+	CopyToRegister        key.Binding
+	PasteFromRegister     key.Binding
+	ClipboardHistory      key.Binding
+	SearchRegex           key.Binding
+	SearchIgnoreCase      key.Binding
+	SearchSmartCase       key.Binding
+	SearchWholeWord       key.Binding
+
+	// byName holds the bindings above by action name. It is built once with
+	// them, so looking up the action of a key does not rebuild it.
+	byName map[string]key.Binding
 }
 
+// NewKeyMap returns the default keys with the given leader.
 func NewKeyMap(leader string) KeyMap {
-	return KeyMap{
-		Quit:               key.NewBinding(key.WithKeys(leader + "+q")),
-		SelectAll:          key.NewBinding(key.WithKeys(leader + "+a")),
-		MoveSelectionDown:  key.NewBinding(key.WithKeys("shift+down")),
-		MoveSelectionLeft:  key.NewBinding(key.WithKeys("shift+left")),
-		MoveSelectionRight: key.NewBinding(key.WithKeys("shift+right")),
-		MoveSelectionUp:    key.NewBinding(key.WithKeys("shift+up")),
-		Copy:               key.NewBinding(key.WithKeys(leader + "+c")),
-		Paste:              key.NewBinding(key.WithKeys(leader + "+v")),
-		Cut:                key.NewBinding(key.WithKeys(leader + "+x")),
-		Save:               key.NewBinding(key.WithKeys(leader + "+s")),
-		Open:               key.NewBinding(key.WithKeys(leader + "+o")),
-		CursorUp:           key.NewBinding(key.WithKeys("up")),
-		CursorDown:         key.NewBinding(key.WithKeys("down")),
-		CursorLeft:         key.NewBinding(key.WithKeys("left")),
-		CursorRight:        key.NewBinding(key.WithKeys("right")),
-		Delete:             key.NewBinding(key.WithKeys("backspace", "delete")),
-		Undo:               key.NewBinding(key.WithKeys(leader + "+z")),
-		Redo:               key.NewBinding(key.WithKeys(leader + "+r")),
-		GoToLine:           key.NewBinding(key.WithKeys(leader + "+g")),
-		ToggleHelp:         key.NewBinding(key.WithKeys(leader + "+h")),
-		Search:             key.NewBinding(key.WithKeys(leader + "+f")),
-		Replace:            key.NewBinding(key.WithKeys(leader + "+t")),
-		GlobalFinder:       key.NewBinding(key.WithKeys(leader + "+p")),
-		// Agile Navigation
-		JumpWordLeft:          key.NewBinding(key.WithKeys(leader + "+left")),
-		JumpWordRight:         key.NewBinding(key.WithKeys(leader + "+right")),
-		JumpLinesUp:           key.NewBinding(key.WithKeys(leader + "+up")),
-		JumpLinesDown:         key.NewBinding(key.WithKeys(leader + "+down")),
-		SelectWordLeft:        key.NewBinding(key.WithKeys(leader + "+shift+left")),
-		SelectWordRight:       key.NewBinding(key.WithKeys(leader + "+shift+right")),
-		SelectLinesUp:         key.NewBinding(key.WithKeys(leader + "+shift+up")),
-		SelectLinesDown:       key.NewBinding(key.WithKeys(leader + "+shift+down")),
-		LineStart:             key.NewBinding(key.WithKeys("home")),
-		LineEnd:               key.NewBinding(key.WithKeys("end")),
-		FileStart:             key.NewBinding(key.WithKeys(leader + "+home")),
-		FileEnd:               key.NewBinding(key.WithKeys(leader + "+end")),
-		SelectToLineStart:     key.NewBinding(key.WithKeys("shift+home")),
-		SelectToLineEnd:       key.NewBinding(key.WithKeys("shift+end")),
-		ToggleMarkdownPreview: key.NewBinding(key.WithKeys(leader + "+u")),
+	k, _ := BuildKeyMap(leader, "default", nil)
+	return k
+}
+
+// BuildKeyMap returns the keys of the preset keymap with the given leader and
+// the keybindings of the config in place of its defaults. If the keybindings
+// are invalid, it returns the default keys of the preset and the error.
+func BuildKeyMap(leader, preset string, keybindings map[string]keymap.Keys) (KeyMap, error) {
+	keys, err := keymap.Resolve(leader, preset, keybindings)
+	var k KeyMap
+	k.byName = map[string]key.Binding{}
+	for name, b := range k.bindings() {
+		*b = key.NewBinding(key.WithKeys(keys[name]...))
+		k.byName[name] = *b
+	}
+	return k, err
+}
+
+// bindings returns the binding of every action by its name in keymap.Actions,
+// to set them. Once set, they are looked up in k.byName.
+func (k *KeyMap) bindings() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"quit":                 &k.Quit,
+		"save_all_quit":        &k.SaveAllQuit,
+		"select_all":           &k.SelectAll,
+		"select_down":          &k.MoveSelectionDown,
+		"select_up":            &k.MoveSelectionUp,
+		"select_left":          &k.MoveSelectionLeft,
+		"select_right":         &k.MoveSelectionRight,
+		"copy":                 &k.Copy,
+		"paste":                &k.Paste,
+		"cut":                  &k.Cut,
+		"save":                 &k.Save,
+		"open":                 &k.Open,
+		"cursor_up":            &k.CursorUp,
+		"cursor_down":          &k.CursorDown,
+		"cursor_left":          &k.CursorLeft,
+		"cursor_right":         &k.CursorRight,
+		"delete":               &k.Delete,
+		"undo":                 &k.Undo,
+		"redo":                 &k.Redo,
+		"earlier":              &k.Earlier,
+		"later":                &k.Later,
+		"undo_history":         &k.UndoHistory,
+		"go_to_line":           &k.GoToLine,
+		"toggle_help":          &k.ToggleHelp,
+		"search":               &k.Search,
+		"replace":              &k.Replace,
+		"global_finder":        &k.GlobalFinder,
+		"jump_word_left":       &k.JumpWordLeft,
+		"jump_word_right":      &k.JumpWordRight,
+		"jump_lines_up":        &k.JumpLinesUp,
+		"jump_lines_down":      &k.JumpLinesDown,
+		"select_word_left":     &k.SelectWordLeft,
+		"select_word_right":    &k.SelectWordRight,
+		"select_lines_up":      &k.SelectLinesUp,
+		"select_lines_down":    &k.SelectLinesDown,
+		"line_start":           &k.LineStart,
+		"line_end":             &k.LineEnd,
+		"file_start":           &k.FileStart,
+		"file_end":             &k.FileEnd,
+		"select_to_line_start": &k.SelectToLineStart,
+		"select_to_line_end":   &k.SelectToLineEnd,
+		"markdown_preview":     &k.ToggleMarkdownPreview,
+		"toggle_line_ending":   &k.ToggleLineEnding,
+		"reopen_with_encoding": &k.ReopenWithEncoding,
+		"save_with_encoding":   &k.SaveWithEncoding,
+		"next_buffer":          &k.NextBuffer,
+		"prev_buffer":          &k.PrevBuffer,
+		"close_buffer":         &k.CloseBuffer,
+		"buffer_list":          &k.BufferList,
+		"split_vertical":       &k.SplitVertical,
+		"split_horizontal":     &k.SplitHorizontal,
+		"next_pane":            &k.NextPane,
+		"close_pane":           &k.ClosePane,
+		"grow_pane":            &k.GrowPane,
+		"shrink_pane":          &k.ShrinkPane,
+		"add_cursor_above":     &k.AddCursorAbove,
+		"add_cursor_below":     &k.AddCursorBelow,
+		"add_next_occurrence":  &k.AddNextOccurrence,
+		"select_all_matches":   &k.SelectAllMatches,
+		"block_select_up":      &k.BlockSelectUp,
+		"block_select_down":    &k.BlockSelectDown,
+		"block_select_left":    &k.BlockSelectLeft,
+		"block_select_right":   &k.BlockSelectRight,
+		"set_mark":             &k.SetMark,
+		"kill_line":            &k.KillLine,
+		"yank_pop":             &k.YankPop,
+		"copy_to_register":     &k.CopyToRegister,
+		"paste_from_register":  &k.PasteFromRegister,
+		"clipboard_history":    &k.ClipboardHistory,
+		"search_regex":         &k.SearchRegex,
+		"search_ignore_case":   &k.SearchIgnoreCase,
+		"search_smart_case":    &k.SearchSmartCase,
+		"search_whole_word":    &k.SearchWholeWord,
+	}
+}
+
+// bound reports whether msg matches the key of any action. Typed text that
+// does is handled as that action.
+func (k KeyMap) bound(msg tea.KeyMsg) bool {
+	for _, b := range k.byName {
+		if key.Matches(msg, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// sequence reports whether keys, separated by spaces, are a key sequence
+// bound to an action or the start of one.
+func (k KeyMap) sequence(keys string) (complete, prefix bool) {
+	for _, b := range k.byName {
+		for _, bk := range b.Keys() {
+			if !strings.Contains(bk, " ") {
+				continue
+			}
+			complete = complete || bk == keys
+			prefix = prefix || strings.HasPrefix(bk, keys+" ")
+		}
+	}
+	return complete, prefix
+}
+
+// continuation is a key that can follow the start of a key sequence.
+type continuation struct {
+	key    string
+	action string // action the key completes, "" if it continues the sequence
+}
+
+// continuations returns the keys that can follow keys, the start of a key
+// sequence, sorted by key.
+func (k KeyMap) continuations(keys string) []continuation {
+	seen := map[string]bool{}
+	var out []continuation
+	for name, b := range k.byName {
+		for _, bk := range b.Keys() {
+			rest, ok := strings.CutPrefix(bk, keys+" ")
+			if !ok {
+				continue
+			}
+			next, _, more := strings.Cut(rest, " ")
+			if seen[next] && more {
+				continue
+			}
+			seen[next] = true
+			c := continuation{key: next}
+			if !more {
+				c.action = name
+			}
+			out = append(out, c)
+		}
+	}
+	slices.SortFunc(out, func(a, b continuation) int { return strings.Compare(a.key, b.key) })
+	return out
+}
+
+// sequenceMsg is the key message a completed key sequence is handled as. Its
+// String() is the sequence, so it matches the binding that holds it.
+func sequenceMsg(keys string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys)}
+}
+
+// keys returns the keys bound to an action.
+func (k KeyMap) keys(action string) []string {
+	if b, ok := k.byName[action]; ok {
+		return b.Keys()
 	}
+	return nil
 }
 
 var DefaultKeyMap = NewKeyMap("ctrl")
@@ -0,0 +1,216 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"larry/internal/buffer"
+	"larry/internal/clipboard"
+)
+
+// Emacs style editing: set_mark starts a selection that the cursor keys
+// extend instead of ending. Text that is cut, copied or killed also goes into
+// a kill ring, so that older kills can be pasted again: right after a paste,
+// yank_pop replaces the pasted text by the kill before it. The kill ring is
+// also the clipboard history that clipboard_history picks from.
+
+// yankState is the text inserted by the last paste, which yank_pop replaces.
+type yankState struct {
+	row, col int // where the text starts, it ends at the cursor
+	index    int // its entry in the kill ring
+}
+
+// setMark starts a selection at every cursor, or ends the selections if the
+// mark is active already.
+func (m Model) setMark() Model {
+	m.block = nil
+	m.mark = !m.mark
+	mark := m.mark
+	m = m.forEachCursor(func(m Model, _ int) Model {
+		m.selecting = mark
+		m.startRow, m.startCol = m.CursorRow, m.CursorCol
+		return m
+	})
+	if mark {
+		m.statusMsg = "Mark set"
+	} else {
+		m.statusMsg = "Mark deactivated"
+	}
+	return m
+}
+
+// dropEmptyRegion ends a selection of nothing, as set_mark leaves before the
+// cursor moves, so that typing and deleting work as without one.
+func (m Model) dropEmptyRegion() Model {
+	if m.selecting && m.startRow == m.CursorRow && m.startCol == m.CursorCol {
+		m.selecting = false
+	}
+	return m
+}
+
+// kill adds text to the kill ring, or to its newest entry with appendLast.
+func (m Model) kill(text string, appendLast bool) Model {
+	if text == "" {
+		return m
+	}
+	ring := slices.Clip(m.killRing)
+	if n := len(ring); appendLast && n > 0 {
+		ring = append(ring[:n-1], ring[n-1]+text)
+	} else {
+		ring = append(ring, text)
+	}
+	if n := max(m.Config.ClipboardHistory, 1); len(ring) > n {
+		ring = ring[len(ring)-n:]
+	}
+	m.killRing = ring
+	return m
+}
+
+// toClipboard writes text to the clipboard and returns the status message
+// for done, e.g. "Copied". The text is in the kill ring even if the
+// clipboard fails.
+func (m Model) toClipboard(text, done string) string {
+	if err := m.clipboardWrite(text); err != nil {
+		return done + ", clipboard unavailable: " + err.Error()
+	}
+	return done + " to clipboard"
+}
+
+// yankText returns the text to paste: the clipboard, which goes into the
+// kill ring if it was copied in another program, or the newest kill if the
+// clipboard can't be read.
+func (m Model) yankText() (Model, string, error) {
+	text, err := m.clipboardRead()
+	n := len(m.killRing)
+	if err != nil {
+		if n == 0 {
+			return m, "", err
+		}
+		return m, m.killRing[n-1], nil
+	}
+	text = buffer.NormalizeLineBreaks(text)
+	if n == 0 || m.killRing[n-1] != text {
+		m = m.kill(text, false)
+	}
+	return m, text, nil
+}
+
+// killLine deletes from every cursor to the end of its line, or the line
+// break if it is there already, into the kill ring. With appendLast the
+// text is added to the newest kill, so that repeated kills paste as one.
+func (m Model) killLine(appendLast bool) Model {
+	m.block = nil
+	m.setEditKind(editDeleting)
+	var killed []string
+	m = m.forEachCursor(func(m Model, _ int) Model {
+		m.selecting = false
+		row, col := m.CursorRow, m.CursorCol
+		endRow, endCol := row, m.Buffer.LineLen(row)
+		if col >= endCol {
+			if row == m.Buffer.LineCount()-1 {
+				return m
+			}
+			endRow, endCol = row+1, 0
+		}
+		m.markModified()
+		text := m.Buffer.Delete(row, col, endRow, endCol)
+		m.pushUndo(EditOp{Type: OpDelete, Row: row, Col: col, Text: text})
+		killed = append(killed, text)
+		return m
+	})
+	if len(killed) == 0 {
+		m.statusMsg = "End of buffer"
+		return m
+	}
+
+	// The cursors were visited from the end of the buffer.
+	slices.Reverse(killed)
+	m = m.kill(strings.Join(killed, "\n"), appendLast)
+	m.killing = true
+	// The kill ring keeps the text if the clipboard fails.
+	_ = m.clipboardWrite(m.killRing[len(m.killRing)-1])
+	return m
+}
+
+// yankPop replaces the text pasted by the last key, y, by the kill before
+// it in the ring.
+func (m Model) yankPop(y *yankState) Model {
+	if y == nil || m.block != nil || len(m.cursors) > 0 {
+		m.statusMsg = "Previous command was not a paste"
+		return m
+	}
+	m.yanked = y
+	if len(m.killRing) < 2 {
+		m.statusMsg = "No older kill"
+		return m
+	}
+
+	index := (y.index + len(m.killRing) - 1) % len(m.killRing)
+	text := m.killRing[index]
+	m.markModified()
+	old := m.Buffer.Delete(y.row, y.col, m.CursorRow, m.CursorCol)
+	m.pushUndo(EditOp{Type: OpDelete, Row: y.row, Col: y.col, Text: old})
+	m.selecting = false
+	m.CursorRow, m.CursorCol = y.row, y.col
+	m.pushUndo(EditOp{Type: OpInsert, Row: y.row, Col: y.col, Text: text})
+	m = m.insertTextAtCursor(text)
+	m.yanked = &yankState{y.row, y.col, index}
+	m.statusMsg = fmt.Sprintf("Kill %d of %d", len(m.killRing)-index, len(m.killRing))
+	return m
+}
+
+// pasteClip pastes text picked from the clipboard history, which makes it
+// the newest entry and puts it on the clipboard, so that it can be pasted
+// again with the paste key.
+func (m Model) pasteClip(text string) Model {
+	ring := slices.Clone(m.killRing)
+	if i := slices.Index(ring, text); i >= 0 {
+		ring = slices.Delete(ring, i, i+1)
+	}
+	m.killRing = ring
+	m = m.kill(text, false)
+	// The text is in the kill ring if the clipboard fails.
+	_ = m.clipboardWrite(text)
+
+	m.beginEdit()
+	m = m.pasteText(text)
+	m.commitEdit()
+	if m.vim != nil {
+		m = m.vimSettle()
+	}
+	return m.updateViewport()
+}
+
+// loadKillRing fills the kill ring with the clipboard history of the last
+// session, if it is kept.
+func (m Model) loadKillRing() (Model, error) {
+	if !m.Config.SaveClipboardHistory {
+		return m, nil
+	}
+	path, err := clipboard.HistoryPath()
+	if err != nil {
+		return m, err
+	}
+	entries, err := clipboard.LoadHistory(path)
+	if err != nil {
+		return m, fmt.Errorf("clipboard history: %w", err)
+	}
+	if n := max(m.Config.ClipboardHistory, 1); len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	m.killRing = entries
+	return m, nil
+}
+
+// saveKillRing keeps the kill ring for the next session, if configured.
+func (m Model) saveKillRing() error {
+	if !m.Config.SaveClipboardHistory {
+		return nil
+	}
+	path, err := clipboard.HistoryPath()
+	if err != nil {
+		return err
+	}
+	return clipboard.SaveHistory(path, m.killRing)
+}
@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"larry/internal/buffer"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// BufferLoadedMsg is sent once a buffer opened with buffer.Load finished
+// building its line index in the background.
+type BufferLoadedMsg struct {
+	Buffer *buffer.Buffer
+}
+
+// WaitForLoadCmd creates a command that reports when buf is fully indexed.
+// It returns nil if the buffer is already loaded.
+func WaitForLoadCmd(buf *buffer.Buffer) tea.Cmd {
+	if buf.Loaded() {
+		return nil
+	}
+	ready := buf.Ready()
+	return func() tea.Msg {
+		<-ready
+		return BufferLoadedMsg{Buffer: buf}
+	}
+}
+
+// fileLoad is a file read in the background by loadFileCmd. Files are read
+// one at a time, in the order they were asked for, see queueLoad.
+type fileLoad struct {
+	path       string
+	row        int  // where to put the cursor, see openFile
+	create     bool // a missing file is a new file, opened empty
+	background bool // add the buffer without switching to it
+}
+
+// fileProgressMsg reports how much of a file was read so far.
+type fileProgressMsg struct {
+	load       fileLoad
+	read, size int64
+	next       <-chan tea.Msg
+}
+
+// fileLoadedMsg is sent once a file was read, or failed to be.
+type fileLoadedMsg struct {
+	load fileLoad
+	buf  *buffer.Buffer
+	err  error
+}
+
+// loadFileCmd reads the file of l, sending progress messages while it runs so
+// the editor keeps drawing and tells how far it got.
+func loadFileCmd(l fileLoad) tea.Cmd {
+	ch := make(chan tea.Msg, 1)
+	go func() {
+		buf, err := buffer.LoadProgress(l.path, func(read, size int64) {
+			// Progress is dropped while the previous one wasn't shown yet.
+			select {
+			case ch <- fileProgressMsg{load: l, read: read, size: size, next: ch}:
+			default:
+			}
+		})
+		ch <- fileLoadedMsg{load: l, buf: buf, err: err}
+	}()
+	return waitForFileCmd(ch)
+}
+
+func waitForFileCmd(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-ch
+	}
+}
+
+// OpenFiles opens paths when the program starts, the first one in the
+// current buffer and the others in buffers of their own. Missing files are
+// new files. The files are read in the background, see Init.
+func (m Model) OpenFiles(paths []string) Model {
+	for i, path := range paths {
+		m.loads = append(m.loads, fileLoad{path: path, row: -1, create: true, background: i > 0})
+	}
+	return m
+}
+
+// queueLoad reads the file of l after the ones already being read.
+func (m Model) queueLoad(l fileLoad) (Model, tea.Cmd) {
+	for _, q := range m.loads {
+		if absPath(q.path) == absPath(l.path) {
+			return m, nil
+		}
+	}
+	m.loads = append(slices.Clip(m.loads), l)
+	if len(m.loads) > 1 {
+		return m, nil
+	}
+	return m, loadFileCmd(l)
+}
+
+// fileProgress shows how much of a file was read so far in the status bar.
+func (m Model) fileProgress(msg fileProgressMsg) (Model, tea.Cmd) {
+	if msg.read < msg.size {
+		m.loadProgress = fmt.Sprintf("%s %d%%", filepath.Base(msg.load.path), msg.read*100/msg.size)
+	}
+	return m, waitForFileCmd(msg.next)
+}
+
+// fileLoaded opens the buffer of a file read by loadFileCmd and starts
+// reading the next one.
+func (m Model) fileLoaded(msg fileLoadedMsg) (Model, tea.Cmd) {
+	var next tea.Cmd
+	if len(m.loads) > 0 {
+		m.loads = m.loads[1:]
+	}
+	if len(m.loads) > 0 {
+		next = loadFileCmd(m.loads[0])
+	}
+	m.loadProgress = ""
+
+	l, buf := msg.load, msg.buf
+	switch {
+	case msg.err != nil && l.create && os.IsNotExist(msg.err):
+		buf = buffer.NewBuffer()
+	case msg.err != nil:
+		m.statusMsg = "Error opening: " + msg.err.Error()
+		return m, next
+	}
+	if l.background {
+		return m.AddBuffer(l.path, buf), tea.Batch(WaitForLoadCmd(buf), next)
+	}
+	m, cmd := m.showFile(l.path, l.row, buf)
+	return m, tea.Batch(cmd, next)
+}
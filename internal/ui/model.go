@@ -1,14 +1,19 @@
 package ui
 
 import (
-	"context"
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"larry/internal/buffer"
+	"larry/internal/clipboard"
 	"larry/internal/config"
+	"larry/internal/keymap"
 	"larry/internal/search"
+	"larry/internal/undo"
 
 	"github.com/charmbracelet/bubbles/filepicker"
 	"github.com/charmbracelet/bubbles/key"
@@ -25,6 +30,13 @@ const (
 	ViewModeSplit
 )
 
+type encodingAction int
+
+const (
+	encodingReopen encodingAction = iota
+	encodingSave
+)
+
 type Model struct {
 	Width              int
 	Height             int
@@ -37,6 +49,8 @@ type Model struct {
 	saving             bool
 	loading            bool
 	goToLine           bool
+	choosingEncoding   bool
+	encodingAction     encodingAction
 	searching          bool
 	replacing          bool
 	finding            bool
@@ -44,18 +58,23 @@ type Model struct {
 	textInput          textinput.Model
 	filePicker         filepicker.Model
 	statusMsg          string
-	yOffset            int
-	Lines              []string
+	top                visualLine // first visual line shown, see updateViewport
+	Buffer             *buffer.Buffer
 	CursorRow          int
 	CursorCol          int
-	UndoStack          []EditOp
-	RedoStack          []EditOp
+	cursors            []CursorState // extra cursors, see forEachCursor
+	block              *blockSelection
+	blockYank          string // last block copied, pasted as a block again
+	history            undo.Tree[UndoGroup]
+	edit               *UndoGroup // open undo transaction, see beginEdit
 	Config             config.Config
 	showHelp           bool
 	searchQuery        string
 	replaceQuery       string
 	replaceWith        string
-	replaceStep        int // 1: Find, 2: Replace with, 3: Replace loop
+	replaceStep        int            // 1: Find, 2: Replace with, 3: Replace loop
+	searchOpts         search.Options // how the search and replace prompts match
+	searchErr          string         // why the pattern typed is invalid, shown in the prompt
 	searchResults      []search.SearchMatch
 	replaceResults     []search.SearchMatch
 	currentResultIndex int
@@ -65,6 +84,34 @@ type Model struct {
 	markdownRenderer   *glamour.TermRenderer
 	markdownCache      string
 	markdownCacheValid bool
+	prompt             *choicePrompt
+	queuedPrompts      []*choicePrompt // shown once prompt is answered, see ask
+	diffView           *diffViewer
+	historyView        *historyViewer
+	pendingKeys        []string   // start of a key sequence, see sequenceKey
+	sequenceID         int        // counts sequences, to match whichKeyMsg
+	whichKey           bool       // show the keys that can follow pendingKeys
+	vim                *vimState  // nil unless the vim keymap is used
+	mark               bool       // the cursor keys extend the selection, see setMark
+	killRing           []string   // cut, copied and killed text, newest last
+	yanked             *yankState // text inserted by the last key if it pasted
+	killing            bool       // the last key killed a line
+	registers          map[string]register
+	registerAction     func(Model, string) Model // waits for a register name, see readRegister
+	clipboard          *clipboard.Clipboard
+	swaps              *swapJournal  // shared by all copies of the model, see journal
+	terminal           *bytes.Buffer // escape sequences for the terminal, see flushTerminal
+	click              mouseClick    // last press of the left button, see mousePress
+	swapPending        bool          // the buffer changed since the swap file was last written
+	swapChecked        bool          // the buffer was checked for a left over swap file
+	swapID             string        // swap file id of an untitled buffer, see swap.NewID
+	docs               []document
+	active             int // index of the active document in docs
+	panes              []pane
+	focus              int // index of the focused pane in panes
+	paneLayout         *layout
+	loads              []fileLoad // files being read, the first one right now
+	loadProgress       string     // how far reading loads[0] got, e.g. "big.log 40%"
 }
 
 func isMarkdownFile(filename string) bool {
@@ -72,7 +119,7 @@ func isMarkdownFile(filename string) bool {
 	return ext == ".md" || ext == ".markdown" || ext == ".mdown" || ext == ".mkd"
 }
 
-func InitialModel(filename string, lines []string, cfg config.Config) Model {
+func InitialModel(filename string, buf *buffer.Buffer, cfg config.Config) Model {
 	SetTheme(cfg.Theme)
 	initStyles()
 
@@ -99,11 +146,15 @@ func InitialModel(filename string, lines []string, cfg config.Config) Model {
 	fp.Styles.Symlink = lipgloss.NewStyle().Foreground(lipgloss.Color("39")).Background(modalStyle.GetBackground())
 	fp.Styles.Selected = styleSelected
 
-	return Model{
+	keys, keysErr := BuildKeyMap(cfg.LeaderKey, cfg.Keymap, cfg.Keybindings)
+	terminal := &bytes.Buffer{}
+	clip, clipErr := clipboard.New(cfg.Clipboard, terminal)
+
+	m := Model{
 		Width:              80,
 		Height:             20,
 		FileName:           filename,
-		KeyMap:             NewKeyMap(cfg.LeaderKey),
+		KeyMap:             keys,
 		Quitting:           false,
 		startRow:           0,
 		startCol:           0,
@@ -112,9 +163,10 @@ func InitialModel(filename string, lines []string, cfg config.Config) Model {
 		saving:             false,
 		loading:            false,
 		filePicker:         fp,
-		Lines:              lines,
+		Buffer:             buf,
 		CursorRow:          0,
 		CursorCol:          0,
+		history:            loadHistory(cfg, filename, buf),
 		Config:             cfg,
 		showHelp:           false,
 		searching:          false,
@@ -129,14 +181,82 @@ func InitialModel(filename string, lines []string, cfg config.Config) Model {
 		Modified:           false,
 		viewMode:           ViewModeEditor,
 		markdownRenderer:   nil,
+		docs:               []document{newDocument(filename, buf)},
+		panes:              []pane{{}},
+		paneLayout:         &layout{},
+		clipboard:          clip,
+		terminal:           terminal,
+		swaps:              newSwapJournal(),
+	}
+	if cfg.Keymap == "vim" {
+		m.vim = &vimState{}
 	}
+	m, historyErr := m.loadKillRing()
+	if err := errors.Join(keysErr, clipErr, historyErr); err != nil {
+		m = m.WithStatus(err.Error())
+	}
+	return m.checkSwap().checkUntitledSwaps()
+}
+
+// WithStatus returns the model showing msg in the status bar, e.g. to report
+// a problem with the config.
+func (m Model) WithStatus(msg string) Model {
+	m.statusMsg = strings.ReplaceAll(msg, "\n", "; ")
+	return m
 }
 
 func (m Model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{WaitForLoadCmd(m.Buffer), watchCmd(m.FileName, m.Buffer.Stamp)}
+	if m.Config.Swap {
+		cmds = append(cmds, swapTickCmd())
+	}
+	if len(m.loads) > 0 {
+		cmds = append(cmds, loadFileCmd(m.loads[0]))
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	return next.(Model).flushTerminal(cmd)
+}
+
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Journaling and watching keep running while a dialog is open.
+	switch msg := msg.(type) {
+	case fileCheckedMsg:
+		return m.fileChecked(msg)
+	case fileProgressMsg:
+		return m.fileProgress(msg)
+	case fileLoadedMsg:
+		return m.fileLoaded(msg)
+	case swapTickMsg:
+		return m.journal()
+	case swapWrittenMsg:
+		if msg.err != nil {
+			m.statusMsg = "Error writing swap file: " + msg.err.Error()
+		}
+		return m, nil
+	case whichKeyMsg:
+		m.whichKey = msg.id == m.sequenceID && len(m.pendingKeys) > 0
+		return m, nil
+	}
+
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		if m.diffView != nil {
+			return m.updateDiffView(keyMsg)
+		}
+		if m.historyView != nil {
+			return m.updateHistoryView(keyMsg)
+		}
+		if m.prompt != nil {
+			return m.updatePrompt(keyMsg)
+		}
+	}
+
+	if mouseMsg, ok := msg.(tea.MouseMsg); ok {
+		return m.handleMouse(mouseMsg)
+	}
 
 	if m.finding {
 		switch msg := msg.(type) {
@@ -147,28 +267,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			case "enter":
 				if len(m.finder.results) > 0 {
-					res := m.finder.results[m.finder.cursor]
-					var path string
-					var targetRow int
-					if res.Mode == search.ModeFiles {
-						path = res.File.Path
-						targetRow = 0
-					} else {
-						path = res.Grep.Path
-						targetRow = res.Grep.Line - 1
-					}
-
-					content, err := os.ReadFile(path)
-					if err == nil {
-						m.Lines = strings.Split(string(content), "\n")
-						m.FileName = path
-						m.CursorRow = targetRow
-						m.CursorCol = 0
-						m = m.updateViewport()
-						m.Modified = false
-					}
-					m.finding = false
-					return m, nil
+					return m.openFinderResult()
 				}
 			}
 		}
@@ -178,31 +277,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	if m.loading {
-		var cmd tea.Cmd
-		m.filePicker, cmd = m.filePicker.Update(msg)
-		if didSelect, path := m.filePicker.DidSelectFile(msg); didSelect {
-			content, err := os.ReadFile(path)
-			if err != nil {
-				m.statusMsg = "Error opening: " + err.Error()
-			} else {
-				m.Lines = strings.Split(string(content), "\n")
-				m.statusMsg = "Opened: " + path
-				m.FileName = path
-				m.CursorRow = 0
-				m.CursorCol = 0
-				m.yOffset = 0
-				m.selecting = false
-				m.Modified = false
-				m.viewMode = ViewModeEditor
-				m.markdownRenderer = nil
-			}
-			m.loading = false
-			return m, cmd
-		}
-		if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.Type == tea.KeyEsc {
-			m.loading = false
-		}
-		return m, cmd
+		return m.updateFilePicker(msg)
 	}
 
 	if m.saving {
@@ -217,17 +292,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if filename == "" {
 					filename = "untitled.txt"
 				}
-				content := strings.Join(m.Lines, "\n")
-				err := os.WriteFile(filename, []byte(content), 0644)
-				if err != nil {
-					m.statusMsg = "Error saving: " + err.Error()
-				} else {
-					m.statusMsg = "Saved: " + filename
-					m.FileName = filename
-					m.Modified = false
-				}
 				m.saving = false
-				return m, nil
+				return m.save(filename, false), nil
 			}
 		}
 		var cmd tea.Cmd
@@ -251,8 +317,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if targetLine < 0 {
 						targetLine = 0
 					}
-					if targetLine >= len(m.Lines) {
-						targetLine = len(m.Lines) - 1
+					if targetLine >= m.Buffer.LineCount() {
+						targetLine = m.Buffer.LineCount() - 1
 					}
 					if targetLine < 0 {
 						targetLine = 0
@@ -270,9 +336,53 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	if m.choosingEncoding {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.Type {
+			case tea.KeyEsc:
+				m.choosingEncoding = false
+				return m, nil
+			case tea.KeyEnter:
+				m.choosingEncoding = false
+				enc, err := buffer.ParseEncoding(m.textInput.Value())
+				if err != nil {
+					m.statusMsg = "Error: " + err.Error()
+					return m, nil
+				}
+				if m.encodingAction == encodingSave {
+					m.Buffer.Format.SetEncoding(enc)
+					m.markModified()
+					m.saving = true
+					m.textInput.SetValue(m.FileName)
+					m.textInput.Prompt = "Filename: "
+					return m, nil
+				}
+				if m.FileName == "" {
+					m.statusMsg = "Nothing to reopen: buffer has no file"
+					return m, nil
+				}
+				return m.confirmUnsaved("reopen", func(m Model) (Model, tea.Cmd) {
+					prev := m.Buffer
+					m, cmd := m.reload(enc)
+					if m.Buffer != prev {
+						m.statusMsg = "Reopened as " + enc.String()
+					}
+					return m, cmd
+				})
+			}
+		}
+		var cmd tea.Cmd
+		m.textInput, cmd = m.textInput.Update(msg)
+		return m, cmd
+	}
+
 	if m.replacing {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
+			if toggled, ok := m.toggleSearchOption(msg); ok {
+				return toggled, nil
+			}
 			switch msg.Type {
 			case tea.KeyEsc:
 				m.replacing = false
@@ -288,21 +398,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.replacing = false
 						return m, nil
 					}
+					if m.searchErr != "" {
+						return m, nil
+					}
 					m.replaceStep = 2
 					m.textInput.SetValue("")
 					m.textInput.Prompt = "With: "
 					return m, nil
 				} else if m.replaceStep == 2 {
 					m.replaceWith = m.textInput.Value()
-					searcher := search.NewBoyerMooreSearch(m.replaceQuery)
-					m.replaceResults = searcher.SearchInLines(context.Background(), m.Lines)
+					m.replaceResults, _ = m.findMatches(m.replaceQuery)
 					m.currReplaceIndex = -1
 					if len(m.replaceResults) > 0 {
 						m.replaceStep = 3
 						m.currReplaceIndex = 0
 						result := m.replaceResults[m.currReplaceIndex]
-						m.CursorRow = result.Line
-						m.CursorCol = result.Col
+						m.CursorRow, m.CursorCol = m.matchStart(result)
 						m = m.updateViewport()
 					} else {
 						m.statusMsg = "No matches found"
@@ -312,26 +423,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else if m.replaceStep == 3 {
 					if m.currReplaceIndex >= 0 && m.currReplaceIndex < len(m.replaceResults) {
 						match := m.replaceResults[m.currReplaceIndex]
+						with := m.replacement(match)
 
-						m.startRow, m.startCol = match.Line, match.Col
-						m.CursorRow, m.CursorCol = match.Line, match.Col+match.Length
+						m.beginEdit()
+						m.startRow, m.startCol = m.matchStart(match)
+						m.CursorRow, m.CursorCol = m.matchEnd(match)
 						m.selecting = true
 
-						m.pushUndo(EditOp{Type: OpDelete, Row: m.startRow, Col: m.startCol, Text: m.replaceQuery})
-						m = m.deleteSelectedText()
-						m.pushUndo(EditOp{Type: OpInsert, Row: m.startRow, Col: m.startCol, Text: m.replaceWith})
-						m = m.insertTextAtCursor(m.replaceWith)
+						m = m.deleteSelection()
+						m.pushUndo(EditOp{Type: OpInsert, Row: m.CursorRow, Col: m.CursorCol, Text: with})
+						m = m.insertTextAtCursor(with)
+						m.commitEdit()
 
-						searcher := search.NewBoyerMooreSearch(m.replaceQuery)
-						m.replaceResults = searcher.SearchInLines(context.Background(), m.Lines)
+						m.replaceResults, _ = m.findMatches(m.replaceQuery)
 
 						if len(m.replaceResults) > 0 {
 							if m.currReplaceIndex >= len(m.replaceResults) {
 								m.currReplaceIndex = 0
 							}
 							result := m.replaceResults[m.currReplaceIndex]
-							m.CursorRow = result.Line
-							m.CursorCol = result.Col
+							m.CursorRow, m.CursorCol = m.matchStart(result)
 							m = m.updateViewport()
 						} else {
 							m.statusMsg = "Done replacing"
@@ -343,28 +454,36 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		var cmd tea.Cmd
+		m.textInput, cmd = m.textInput.Update(msg)
+
+		// Search as the query is typed, to show the matches and errors.
 		if m.replaceStep == 1 {
 			query := m.textInput.Value()
 			if query != m.replaceQuery {
 				m.replaceQuery = query
-				if query != "" {
-					searcher := search.NewBoyerMooreSearch(query)
-					m.replaceResults = searcher.SearchInLines(context.Background(), m.Lines)
-				} else {
-					m.replaceResults = nil
-				}
-				m.currReplaceIndex = -1
+				m = m.findReplaceMatches()
 			}
 		}
-
-		var cmd tea.Cmd
-		m.textInput, cmd = m.textInput.Update(msg)
 		return m, cmd
 	}
 
 	if m.searching {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
+			if m.vim != nil && msg.Type == tea.KeyEnter {
+				return m.vimSearch().updateViewport(), nil
+			}
+			if toggled, ok := m.toggleSearchOption(msg); ok {
+				return toggled, nil
+			}
+			if key.Matches(msg, m.KeyMap.SelectAllMatches) {
+				m = m.selectAllMatches()
+				m.searching = false
+				m.searchResults = nil
+				m.currentResultIndex = -1
+				return m.updateViewport(), nil
+			}
 			switch msg.Type {
 			case tea.KeyEsc:
 				m.searching = false
@@ -376,21 +495,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if len(m.searchResults) > 0 {
 					m.currentResultIndex = (m.currentResultIndex + 1) % len(m.searchResults)
 					result := m.searchResults[m.currentResultIndex]
-					m.CursorRow = result.Line
-					m.CursorCol = result.Col
+					m.CursorRow, m.CursorCol = m.matchStart(result)
 
 					// Center the result in the viewport
 					viewportHeight := m.Height - 3 // Status bar etc
-					m.yOffset = m.CursorRow - (viewportHeight / 2)
-					if m.yOffset < 0 {
-						m.yOffset = 0
-					}
-					// Ensure m.yOffset is valid (redundant check but safe)
-					if m.yOffset >= len(m.Lines) {
-						m.yOffset = len(m.Lines) - 1
-					}
+					m.top = visualLine{row: max(m.CursorRow-viewportHeight/2, 0)}
 
-					// We don't strictly need updateViewport here if we manually set yOffset correctly,
+					// We don't strictly need updateViewport here if we manually set top correctly,
 					// but it handles bounds and bottom-clamping too.
 					// However, updateViewport might override our "center" preference
 					// if it thinks the cursor is visible "enough" (at the very bottom or top).
@@ -401,20 +512,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		var cmd tea.Cmd
+		m.textInput, cmd = m.textInput.Update(msg)
+
 		query := m.textInput.Value()
 		if query != m.searchQuery {
 			m.searchQuery = query
-			if query != "" {
-				searcher := search.NewBoyerMooreSearch(query)
-				m.searchResults = searcher.SearchInLines(context.Background(), m.Lines)
-			} else {
-				m.searchResults = nil
-			}
-			m.currentResultIndex = -1
+			m = m.findSearchMatches()
 		}
-
-		var cmd tea.Cmd
-		m.textInput, cmd = m.textInput.Update(msg)
 		return m, cmd
 	}
 
@@ -428,47 +533,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	switch msg := msg.(type) {
+	case BufferLoadedMsg:
+		if msg.Buffer == m.Buffer {
+			m = m.updateViewport()
+		}
+		return m, nil
+
 	case searchMsg:
 		var cmd tea.Cmd
 		m.finder, cmd = m.finder.Update(msg)
 		return m, cmd
 
-	case SearchResultsMsg:
-		if msg.IsReplace {
-			// Verify if the query matches current replaceQuery
-			// (User might have typed more since this search started)
-			if msg.Query != m.replaceQuery {
-				return m, nil
-			}
-			m.replaceResults = msg.Results
-			m.currReplaceIndex = -1
-			if len(m.replaceResults) > 0 {
-				m.replaceStep = 3
-				m.currReplaceIndex = 0
-				result := m.replaceResults[m.currReplaceIndex]
-				m.CursorRow = result.Line
-				m.CursorCol = result.Col
-				m = m.updateViewport()
-			} else {
-				// Only finish if we are in step 2 (waiting for search)
-				if m.replaceStep == 2 {
-					m.statusMsg = "No matches found"
-					// Stay in replace mode or exit?
-					// m.replacing = false // Maybe let them try another query
-				}
-			}
-		} else {
-			if msg.Query != m.searchQuery {
-				return m, nil
-			}
-			m.searchResults = msg.Results
-			m.currentResultIndex = -1
-		}
-		return m, nil
-
 	case tea.KeyMsg:
 		var cmd tea.Cmd
+		m.beginEdit()
 		m, cmd = m.handleKey(msg)
+		m.commitEdit()
 		if cmd != nil {
 			return m, cmd
 		}
@@ -518,29 +598,38 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) View() string {
-	leader := strings.Title(m.Config.LeaderKey)
-	if leader == "" {
-		leader = "Leader"
+	if m.diffView != nil {
+		return m.viewDiff()
+	}
+	if m.historyView != nil {
+		return m.viewHistory()
 	}
 
 	msg := m.statusMsg
 	if msg == "" {
-		if len(m.searchResults) > 0 {
-			msg = fmt.Sprintf("Search: %s (%d/%d) | %s+h: Help | %s+q: Quit | %s+s: Save | %s+f: Search File | %s+p: Larry Finder",
-				m.searchQuery, m.currentResultIndex+1, len(m.searchResults), leader, leader, leader, leader, leader)
-		} else {
-			msg = fmt.Sprintf("%s+o: Open File | %s+h: Help | %s+q: Quit | %s+s: Save | %s+f: Search File | %s+p: Larry Finder",
-				leader, leader, leader, leader, leader, leader)
-		}
+		msg = m.statusHints(false)
 	}
 
 	fileStatus := m.FileName
 	if fileStatus == "" {
 		fileStatus = "[No Name]"
 	}
+	if len(m.docs) > 1 {
+		fileStatus = fmt.Sprintf("[%d/%d] %s", m.active+1, len(m.docs), fileStatus)
+	}
 	if m.Modified {
 		fileStatus += " [+]"
 	}
+	if !m.Buffer.Loaded() {
+		fileStatus += " [indexing]"
+	}
+	if m.loadProgress != "" {
+		fileStatus += " [" + m.loadProgress + "]"
+	}
+	fileStatus += " [" + m.Buffer.Format.String() + "]"
+	if m.vim != nil {
+		fileStatus = m.vim.status() + " │ " + fileStatus
+	}
 
 	fullStatus := fmt.Sprintf(" %s │ %s", fileStatus, msg)
 
@@ -556,195 +645,80 @@ func (m Model) View() string {
 		return "Tchau!\n"
 	}
 
-	baseView := ""
+	baseView := m.viewPanes()
 
-	if m.viewMode == ViewModeSplit && isMarkdownFile(m.FileName) {
-		baseView = m.viewSplit()
-	} else if len(m.Lines) == 0 && !m.loading {
-		s := strings.Builder{}
-		s.WriteString(borderStyle.Render("│"))
-		if m.Config.LineNumbers {
-			s.WriteString(lineNumStyle.Render("   1 "))
-		}
-		s.WriteString(styleCursor.Render(" "))
-		s.WriteString("\n")
-		baseView = s.String()
-	} else {
-		editorHeight := m.Height - 1
-		if m.saving || m.goToLine || m.searching || m.replacing {
-			editorHeight -= 2
-		}
-		if editorHeight < 1 {
-			editorHeight = 1
-		}
-		baseView = m.viewEditor(editorViewConfig{
-			width:        m.Width,
-			height:       editorHeight,
-			showSearchUI: m.searching,
-		})
+	if m.prompt != nil {
+		return fmt.Sprintf("%s\n\n%s", baseView, m.prompt.View())
 	}
-
 	if m.saving {
 		return fmt.Sprintf("%s\n\n%s", baseView, m.textInput.View())
 	}
-	if m.goToLine {
+	if m.goToLine || m.choosingEncoding {
 		return fmt.Sprintf("%s\n\n%s", baseView, m.textInput.View())
 	}
 	if m.searching {
 		var counter string
 		if len(m.searchResults) > 0 {
 			counter = fmt.Sprintf(" (%d/%d)", m.currentResultIndex+1, len(m.searchResults))
+		} else if m.searchErr != "" {
+			counter = " (" + m.searchErr + ")"
 		} else if m.searchQuery != "" {
 			counter = " (no results)"
 		}
-		searchView := fmt.Sprintf("%s%s", m.textInput.View(), counter)
+		searchView := fmt.Sprintf("%s%s%s", m.textInput.View(), m.searchBadges(), counter)
 		return fmt.Sprintf("%s\n\n%s", baseView, searchView)
 	}
 	if m.replacing {
 		var counter string
 		if len(m.replaceResults) > 0 {
 			counter = fmt.Sprintf(" (%d/%d)", m.currReplaceIndex+1, len(m.replaceResults))
+		} else if m.searchErr != "" && m.replaceStep == 1 {
+			counter = " (" + m.searchErr + ")"
 		} else if m.replaceQuery != "" && m.replaceStep >= 2 {
 			counter = " (no results)"
 		}
-		replaceView := fmt.Sprintf("%s%s", m.textInput.View(), counter)
+		replaceView := fmt.Sprintf("%s%s%s", m.textInput.View(), m.searchBadges(), counter)
 		return fmt.Sprintf("%s\n\n%s", baseView, replaceView)
 	}
 	if m.finding {
-		finderView := m.finder.View()
-		w := m.Width
-		if w > 120 {
-			w = 120
-		}
-		h := lipgloss.Height(finderView)
-
-		maxW := m.Width - 8
-		maxH := m.Height - 4
-
-		if w > maxW {
-			w = maxW
-		}
-		if h > maxH {
-			h = maxH
-		}
-
-		bg := modalStyle.GetBackground()
-		spacerStyle := lipgloss.NewStyle().Background(bg)
-
-		titleText := "Global Larry Finder (Tab: Switch Mode)"
-		titleGap := w - lipgloss.Width(titleText)
-		if titleGap < 0 {
-			titleGap = 0
-		}
-		leftGap := titleGap / 2
-		rightGap := titleGap - leftGap
-		title := modalTitleStyle.Width(w).Render(strings.Repeat(" ", leftGap) + titleText + strings.Repeat(" ", rightGap))
-
-		var allLines []string
-		allLines = append(allLines, title)
-		allLines = append(allLines, spacerStyle.Copy().Width(w).Render(""))
-
-		finderLines := strings.Split(finderView, "\n")
-		for i, line := range finderLines {
-			if i >= maxH-2 {
-				break
-			}
-			styledLine := strings.ReplaceAll(line, " ", spacerStyle.Render(" "))
-			allLines = append(allLines, spacerStyle.Width(w).Render(styledLine))
-		}
-
-		modal := modalStyle.Render(strings.Join(allLines, "\n"))
-
-		return lipgloss.Place(
-			m.Width, m.Height,
-			lipgloss.Center, lipgloss.Center,
-			modal,
-		)
+		return m.placeModal(m.viewFinderModal())
 	}
 	if m.loading {
-		pickerView := m.filePicker.View()
-		w := lipgloss.Width(pickerView)
-		if w < 40 {
-			w = 40
-		}
-
-		bg := modalStyle.GetBackground()
-		spacerStyle := lipgloss.NewStyle().Background(bg)
-
-		titleText := "Open File"
-		titleGap := w - lipgloss.Width(titleText)
-		leftGap := titleGap / 2
-		rightGap := titleGap - leftGap
-		title := modalTitleStyle.Width(w).Render(strings.Repeat(" ", leftGap) + titleText + strings.Repeat(" ", rightGap))
-
-		var allLines []string
-		allLines = append(allLines, title)
-		allLines = append(allLines, spacerStyle.Copy().Width(w).Render(""))
-
-		pickerLines := strings.Split(pickerView, "\n")
-		for _, line := range pickerLines {
-			styledLine := strings.ReplaceAll(line, " ", spacerStyle.Render(" "))
-			allLines = append(allLines, spacerStyle.Width(w).Render(styledLine))
-		}
-
-		maxWidth := 0
-		for _, line := range allLines {
-			lw := lipgloss.Width(line)
-			if lw > maxWidth {
-				maxWidth = lw
-			}
-		}
-
-		for i, line := range allLines {
-			allLines[i] = spacerStyle.Copy().Width(maxWidth).Render(line)
-		}
-
-		modal := modalStyle.Render(strings.Join(allLines, "\n"))
-
-		return lipgloss.Place(
-			m.Width, m.Height,
-			lipgloss.Center, lipgloss.Center,
-			modal,
-		)
+		return m.placeModal(m.viewFilePickerModal())
 	}
 
 	if m.showHelp {
 		return m.viewHelpMenu(baseView)
 	}
 
-	leader = strings.Title(m.Config.LeaderKey)
-
-	if leader == "" {
-		leader = "Leader"
-	}
-
 	msg = m.statusMsg
 
-	if msg == "" {
-		if len(m.searchResults) > 0 {
-			msg = fmt.Sprintf("Search: %s (%d/%d) | %s+h: Help | %s+q: Quit | %s+s: Save | %s+f: Search File | %s+p: Larry Finder",
-				m.searchQuery, m.currentResultIndex+1, len(m.searchResults), leader, leader, leader, leader, leader)
-		} else if isMarkdownFile(m.FileName) {
-			if m.viewMode == ViewModeSplit {
-				msg = fmt.Sprintf("%s+u: Close Preview | %s+h: Help | %s+q: Quit | %s+s: Save | %s+p: Larry Finder",
-					leader, leader, leader, leader, leader)
-			} else {
-				msg = fmt.Sprintf("%s+u: Preview | %s+h: Help | %s+q: Quit | %s+s: Save | %s+p: Larry Finder",
-					leader, leader, leader, leader, leader)
-			}
-		} else {
-			msg = fmt.Sprintf("%s+o: Open File | %s+h: Help | %s+q: Quit | %s+s: Save | %s+f: Search File | %s+p: Larry Finder",
-				leader, leader, leader, leader, leader, leader)
-		}
+	if len(m.pendingKeys) > 0 {
+		msg = keymap.Label(strings.Join(m.pendingKeys, " ")) + ", …"
+	} else if msg == "" {
+		msg = m.statusHints(isMarkdownFile(m.FileName))
 	}
 
 	fileStatus = m.FileName
 	if fileStatus == "" {
 		fileStatus = "[No Name]"
 	}
+	if len(m.docs) > 1 {
+		fileStatus = fmt.Sprintf("[%d/%d] %s", m.active+1, len(m.docs), fileStatus)
+	}
 	if m.Modified {
 		fileStatus += " [+]"
 	}
+	if !m.Buffer.Loaded() {
+		fileStatus += " [indexing]"
+	}
+	if m.loadProgress != "" {
+		fileStatus += " [" + m.loadProgress + "]"
+	}
+	fileStatus += " [" + m.Buffer.Format.String() + "]"
+	if m.vim != nil {
+		fileStatus = m.vim.status() + " │ " + fileStatus
+	}
 
 	fullStatus = fmt.Sprintf(" %s │ %s", fileStatus, msg)
 
@@ -756,5 +730,144 @@ func (m Model) View() string {
 	wrappedMsg = lipgloss.NewStyle().Width(width - 2).Render(fullStatus)
 	status = statusBarStyle.Width(width).Render(wrappedMsg)
 
+	if m.whichKey {
+		baseView = overlayBottom(baseView, m.viewWhichKey(width), m.Height-lipgloss.Height(status))
+	}
+
 	return lipgloss.JoinVertical(lipgloss.Left, baseView, status)
 }
+
+// openFinderResult closes the finder and opens the selected result.
+func (m Model) openFinderResult() (Model, tea.Cmd) {
+	res := m.finder.results[m.finder.cursor]
+	m.finding = false
+	switch res.Mode {
+	case search.ModeFiles:
+		return m.openFile(res.File.Path, -1)
+	case search.ModeBuffers:
+		return m.switchBuffer(res.Buffer.Index)
+	case search.ModeClipboard:
+		return m.pasteClip(res.Clip.Text), nil
+	default:
+		return m.openFile(res.Grep.Path, res.Grep.Line-1)
+	}
+}
+
+// updateFilePicker passes msg to the file picker, opening the file chosen.
+func (m Model) updateFilePicker(msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.filePicker, cmd = m.filePicker.Update(msg)
+	if didSelect, path := m.filePicker.DidSelectFile(msg); didSelect {
+		m.loading = false
+		m.statusMsg = "Opened: " + path
+		var openCmd tea.Cmd
+		m, openCmd = m.openFile(path, -1)
+		return m, tea.Batch(cmd, openCmd)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.Type == tea.KeyEsc {
+		m.loading = false
+	}
+	return m, cmd
+}
+
+// viewFinderModal renders the global finder in a modal.
+func (m Model) viewFinderModal() string {
+	finderView := m.finder.View()
+	w := m.Width
+	if w > 120 {
+		w = 120
+	}
+	h := lipgloss.Height(finderView)
+
+	maxW := m.Width - 8
+	maxH := m.Height - 4
+
+	if w > maxW {
+		w = maxW
+	}
+	if h > maxH {
+		h = maxH
+	}
+
+	bg := modalStyle.GetBackground()
+	spacerStyle := lipgloss.NewStyle().Background(bg)
+
+	titleText := "Global Larry Finder (Tab: Switch Mode)"
+	titleGap := w - lipgloss.Width(titleText)
+	if titleGap < 0 {
+		titleGap = 0
+	}
+	leftGap := titleGap / 2
+	rightGap := titleGap - leftGap
+	title := modalTitleStyle.Width(w).Render(strings.Repeat(" ", leftGap) + titleText + strings.Repeat(" ", rightGap))
+
+	var allLines []string
+	allLines = append(allLines, title)
+	allLines = append(allLines, spacerStyle.Copy().Width(w).Render(""))
+
+	finderLines := strings.Split(finderView, "\n")
+	for i, line := range finderLines {
+		if i >= maxH-2 {
+			break
+		}
+		styledLine := strings.ReplaceAll(line, " ", spacerStyle.Render(" "))
+		allLines = append(allLines, spacerStyle.Width(w).Render(styledLine))
+	}
+
+	modal := modalStyle.Render(strings.Join(allLines, "\n"))
+
+	return modal
+}
+
+// viewFilePickerModal renders the file picker in a modal.
+func (m Model) viewFilePickerModal() string {
+	pickerView := m.filePicker.View()
+	w := lipgloss.Width(pickerView)
+	if w < 40 {
+		w = 40
+	}
+
+	bg := modalStyle.GetBackground()
+	spacerStyle := lipgloss.NewStyle().Background(bg)
+
+	titleText := "Open File"
+	titleGap := w - lipgloss.Width(titleText)
+	leftGap := titleGap / 2
+	rightGap := titleGap - leftGap
+	title := modalTitleStyle.Width(w).Render(strings.Repeat(" ", leftGap) + titleText + strings.Repeat(" ", rightGap))
+
+	var allLines []string
+	allLines = append(allLines, title)
+	allLines = append(allLines, spacerStyle.Copy().Width(w).Render(""))
+
+	pickerLines := strings.Split(pickerView, "\n")
+	for _, line := range pickerLines {
+		styledLine := strings.ReplaceAll(line, " ", spacerStyle.Render(" "))
+		allLines = append(allLines, spacerStyle.Width(w).Render(styledLine))
+	}
+
+	maxWidth := 0
+	for _, line := range allLines {
+		lw := lipgloss.Width(line)
+		if lw > maxWidth {
+			maxWidth = lw
+		}
+	}
+
+	for i, line := range allLines {
+		allLines[i] = spacerStyle.Copy().Width(maxWidth).Render(line)
+	}
+
+	modal := modalStyle.Render(strings.Join(allLines, "\n"))
+
+	return modal
+}
+
+// placeModal centers modal on the screen.
+func (m Model) placeModal(modal string) string {
+	return lipgloss.Place(
+		m.Width, m.Height,
+		lipgloss.Center, lipgloss.Center,
+		modal,
+	)
+}
@@ -0,0 +1,322 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Mouse support: a click places the cursor, dragging selects, a double click
+// selects a word and a triple click a line. The wheel scrolls without moving
+// the cursor. In the finder and the file picker a click selects an entry and
+// a click on the selected entry opens it.
+
+const (
+	doubleClickTime = 400 * time.Millisecond
+	wheelLines      = 3
+)
+
+// mouseClick is the last press of the left button, to count double and
+// triple clicks.
+type mouseClick struct {
+	at       time.Time
+	x, y     int
+	count    int  // 1 to 3
+	dragging bool // the button is held after a press on text, moving selects
+}
+
+func (m Model) handleMouse(msg tea.MouseMsg) (Model, tea.Cmd) {
+	switch {
+	case m.diffView != nil, m.historyView != nil, m.showHelp, m.promptActive():
+		return m, nil
+	case m.finding:
+		return m.finderMouse(msg)
+	case m.loading:
+		return m.filePickerMouse(msg)
+	}
+
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp, msg.Button == tea.MouseButtonWheelDown:
+		i, _, ok := m.paneAt(msg.X, msg.Y)
+		if !ok {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m, cmd = m.focusPane(i)
+		if msg.Button == tea.MouseButtonWheelUp {
+			return m.scroll(-wheelLines), cmd
+		}
+		return m.scroll(wheelLines), cmd
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft:
+		return m.mousePress(msg)
+	case msg.Action == tea.MouseActionMotion && m.click.dragging:
+		return m.mouseDrag(msg), nil
+	case msg.Action == tea.MouseActionRelease:
+		m.click.dragging = false
+	}
+	return m, nil
+}
+
+// mousePress moves the cursor to the position clicked, focusing its pane.
+// With Shift held the selection is extended to it.
+func (m Model) mousePress(msg tea.MouseMsg) (Model, tea.Cmd) {
+	i, r, ok := m.paneAt(msg.X, msg.Y)
+	if !ok {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m, cmd = m.focusPane(i)
+	x, y := msg.X-r.x, msg.Y-r.y
+	width := m.editorWidth(r.w)
+	if y < 0 || x >= width {
+		// The pane title or the markdown preview.
+		return m, cmd
+	}
+
+	count := 1
+	if time.Since(m.click.at) < doubleClickTime && m.click.x == msg.X && m.click.y == msg.Y {
+		count = m.click.count%3 + 1
+	}
+	m.click = mouseClick{at: time.Now(), x: msg.X, y: msg.Y, count: count, dragging: true}
+
+	m.cursors = nil
+	m.block = nil
+	m.mark = false
+	if m.vim != nil && m.vim.mode != vimInsert {
+		m = m.vimSetMode(vimNormal)
+	}
+
+	row, col := m.positionAt(x, y, width)
+	switch {
+	case msg.Shift:
+		if !m.selecting {
+			m.selecting = true
+			m.startRow, m.startCol = m.CursorRow, m.CursorCol
+		}
+		m.CursorRow, m.CursorCol = row, col
+	case count == 2:
+		m = m.selectWordAt(row, col)
+	case count == 3:
+		m = m.selectLineAt(row)
+	default:
+		m.selecting = false
+		m.CursorRow, m.CursorCol = row, col
+	}
+	return m.settleMouse(), cmd
+}
+
+// mouseDrag selects from where the button was pressed to the mouse. Above
+// or below the pane the view scrolls.
+func (m Model) mouseDrag(msg tea.MouseMsg) Model {
+	w, h := m.editorArea()
+	r, _ := m.paneLayout.paneRect(m.focus, rect{0, 0, w, h})
+	if len(m.panes) > 1 {
+		r.y++
+	}
+	row, col := m.positionAt(msg.X-r.x, msg.Y-r.y, m.editorWidth(r.w))
+	if !m.selecting {
+		m.startRow, m.startCol = m.CursorRow, m.CursorCol
+	}
+	m.CursorRow, m.CursorCol = row, col
+	m.selecting = row != m.startRow || col != m.startCol
+	return m.settleMouse()
+}
+
+func (m Model) settleMouse() Model {
+	if m.vim != nil {
+		m = m.vimSettle()
+	}
+	return m.updateViewport()
+}
+
+// selectWordAt selects the word, the blanks or the other characters around
+// row, col, as far as they are of the same kind.
+func (m Model) selectWordAt(row, col int) Model {
+	line := []rune(m.Buffer.Line(row))
+	m.CursorRow, m.CursorCol = row, col
+	m.selecting = false
+	if col >= len(line) {
+		return m
+	}
+	cls := wordClass(line[col], false)
+	start, end := col, col+1
+	for start > 0 && wordClass(line[start-1], false) == cls {
+		start--
+	}
+	for end < len(line) && wordClass(line[end], false) == cls {
+		end++
+	}
+	m.selecting = true
+	m.startRow, m.startCol = row, start
+	m.CursorCol = end
+	return m
+}
+
+// selectLineAt selects line row with its line break.
+func (m Model) selectLineAt(row int) Model {
+	m.selecting = true
+	m.startRow, m.startCol = row, 0
+	if row == m.Buffer.LineCount()-1 {
+		m.CursorRow, m.CursorCol = row, m.Buffer.LineLen(row)
+	} else {
+		m.CursorRow, m.CursorCol = row+1, 0
+	}
+	return m
+}
+
+// paneAt returns the pane at screen position x, y and the area of its text,
+// which starts below the title when the screen is split.
+func (m Model) paneAt(x, y int) (int, rect, bool) {
+	w, h := m.editorArea()
+	i, r, ok := m.paneLayout.paneAt(x, y, rect{0, 0, w, h})
+	if ok && len(m.panes) > 1 {
+		r.y++
+		r.h = max(r.h-1, 1)
+	}
+	return i, r, ok
+}
+
+// editorWidth returns the width of the editor in a pane of width w, which
+// shares it with the markdown preview if that is shown.
+func (m Model) editorWidth(w int) int {
+	if m.viewMode == ViewModeSplit && isMarkdownFile(m.FileName) {
+		return w / 2
+	}
+	return w
+}
+
+// positionAt returns the buffer position shown at x, y of an editor view of
+// the given width, as laid out by viewEditor. Above and below the view it
+// returns the lines there, and past the end of a visual line its end.
+func (m Model) positionAt(x, y, width int) (int, int) {
+	textWidth := m.textWidth(width)
+
+	// Find the visual line y, the last one if the buffer ends before it.
+	v := m.moveVisual(m.clampVisual(m.top, textWidth), y, textWidth)
+	row, chunk := v.row, v.wrap
+	line := []rune(m.Buffer.Line(row))
+	starts := m.wrapLine(line, textWidth)
+
+	// The border, and the line number or its blank space on wrapped lines.
+	x--
+	if m.Config.LineNumbers && chunk == 0 {
+		x -= len(fmt.Sprintf(" %3d ", row+1))
+	} else if m.Config.LineNumbers {
+		x -= 6
+	}
+
+	start, end := starts[chunk], len(line)
+	if chunk+1 < len(starts) {
+		end = starts[chunk+1]
+	}
+	col, w := start, 0
+	for ; col < end; col++ {
+		charWidth := 1
+		if line[col] == '\t' {
+			charWidth = m.Config.TabWidth
+		}
+		if x < w+charWidth {
+			break
+		}
+		w += charWidth
+	}
+	// The end of a wrapped visual line is drawn at the start of the next.
+	if col == end && end < len(line) && end > start {
+		col--
+	}
+	return row, col
+}
+
+// scroll moves the view of the focused pane delta visual lines down, or up
+// if delta < 0, without moving the cursor. It stops when the end of the
+// buffer is at the bottom of the pane.
+func (m Model) scroll(delta int) Model {
+	w, h := m.paneSize(m.focus)
+	textWidth := m.textWidth(m.editorWidth(w))
+	top := m.moveVisual(m.clampVisual(m.top, textWidth), delta, textWidth)
+	// The top of the view with the last visual line at the bottom of the pane.
+	last := m.Buffer.LineCount() - 1
+	end := m.moveVisual(visualLine{last, m.getVisualLineCount(last, textWidth) - 1}, 1-h, textWidth)
+	if end.before(top) {
+		top = end
+	}
+	m.top = top
+	return m
+}
+
+// inModal returns screen position x, y relative to the content of modal, as
+// placed by placeModal, below its title and the blank line after it. It
+// reports false outside of the content.
+func (m Model) inModal(modal string, x, y int) (int, int, bool) {
+	w, h := lipgloss.Width(modal), lipgloss.Height(modal)
+	x -= max((m.Width-w)/2, 0) + modalStyle.GetBorderLeftSize() + modalStyle.GetPaddingLeft()
+	y -= max((m.Height-h)/2, 0) + modalStyle.GetBorderTopSize() + modalStyle.GetPaddingTop() + 2
+	w -= modalStyle.GetHorizontalFrameSize()
+	h -= modalStyle.GetVerticalFrameSize() + 2
+	return x, y, x >= 0 && x < w && y >= 0 && y < h
+}
+
+func (m Model) finderMouse(msg tea.MouseMsg) (Model, tea.Cmd) {
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.finder = m.finder.moveCursor(-1)
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.finder = m.finder.moveCursor(1)
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft:
+		_, y, ok := m.inModal(m.viewFinderModal(), msg.X, msg.Y)
+		if !ok {
+			break
+		}
+		if i, ok := m.finder.resultAt(y); ok {
+			if i == m.finder.cursor {
+				return m.openFinderResult()
+			}
+			m.finder.cursor = i
+		}
+	}
+	return m, nil
+}
+
+// filePickerMouse works the file picker by sending it the keys that move to
+// the entry clicked, or open it if it is selected already.
+func (m Model) filePickerMouse(msg tea.MouseMsg) (Model, tea.Cmd) {
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		return m.updateFilePicker(tea.KeyMsg{Type: tea.KeyUp})
+	case msg.Button == tea.MouseButtonWheelDown:
+		return m.updateFilePicker(tea.KeyMsg{Type: tea.KeyDown})
+	case msg.Action != tea.MouseActionPress || msg.Button != tea.MouseButtonLeft:
+		return m, nil
+	}
+
+	_, y, ok := m.inModal(m.viewFilePickerModal(), msg.X, msg.Y)
+	if !ok {
+		return m, nil
+	}
+	// Only the selected entry starts with the cursor.
+	lines := strings.Split(m.filePicker.View(), "\n")
+	cursor := m.filePicker.Styles.Cursor.Render(m.filePicker.Cursor)
+	selected := slices.IndexFunc(lines, func(l string) bool { return strings.HasPrefix(l, cursor) })
+	if selected < 0 || y >= len(lines) || lines[y] == "" {
+		return m, nil
+	}
+
+	if y == selected {
+		return m.updateFilePicker(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	k := tea.KeyMsg{Type: tea.KeyDown}
+	if y < selected {
+		k.Type = tea.KeyUp
+	}
+	var cmds []tea.Cmd
+	for range max(y-selected, selected-y) {
+		var cmd tea.Cmd
+		m, cmd = m.updateFilePicker(k)
+		cmds = append(cmds, cmd)
+	}
+	return m, tea.Batch(cmds...)
+}
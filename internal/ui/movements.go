@@ -2,29 +2,31 @@ package ui
 
 import (
 	"unicode"
+
+	"larry/internal/buffer"
 )
 
 const JumpLineCount = 5
 
-func FindNextWordBoundary(lines []string, row, col int) (int, int) {
-	if len(lines) == 0 {
+func FindNextWordBoundary(buf *buffer.Buffer, row, col int) (int, int) {
+	if buf.LineCount() == 0 {
 		return 0, 0
 	}
 
 	// Ensure valid row
-	if row >= len(lines) {
-		row = len(lines) - 1
+	if row >= buf.LineCount() {
+		row = buf.LineCount() - 1
 	}
 	if row < 0 {
 		row = 0
 	}
 
-	lineRunes := []rune(lines[row])
+	lineRunes := []rune(buf.Line(row))
 	lineLen := len(lineRunes)
 
 	// If at end of line, move to start of next line
 	if col >= lineLen {
-		if row < len(lines)-1 {
+		if row < buf.LineCount()-1 {
 			return row + 1, 0
 		}
 		return row, col
@@ -41,11 +43,11 @@ func FindNextWordBoundary(lines []string, row, col int) (int, int) {
 	}
 
 	// If we reached end of line, try next line
-	if col >= lineLen && row < len(lines)-1 {
+	if col >= lineLen && row < buf.LineCount()-1 {
 		row++
 		col = 0
 		// Skip leading spaces on new line
-		newLineRunes := []rune(lines[row])
+		newLineRunes := []rune(buf.Line(row))
 		for col < len(newLineRunes) && unicode.IsSpace(newLineRunes[col]) {
 			col++
 		}
@@ -54,26 +56,26 @@ func FindNextWordBoundary(lines []string, row, col int) (int, int) {
 	return row, col
 }
 
-func FindPrevWordBoundary(lines []string, row, col int) (int, int) {
-	if len(lines) == 0 {
+func FindPrevWordBoundary(buf *buffer.Buffer, row, col int) (int, int) {
+	if buf.LineCount() == 0 {
 		return 0, 0
 	}
 
 	// Ensure valid row
-	if row >= len(lines) {
-		row = len(lines) - 1
+	if row >= buf.LineCount() {
+		row = buf.LineCount() - 1
 	}
 	if row < 0 {
 		row = 0
 	}
 
-	lineRunes := []rune(lines[row])
+	lineRunes := []rune(buf.Line(row))
 
 	// If at start of line, move to end of previous line
 	if col <= 0 {
 		if row > 0 {
 			row--
-			lineRunes = []rune(lines[row])
+			lineRunes = []rune(buf.Line(row))
 			col = len(lineRunes)
 		} else {
 			return 0, 0
@@ -102,34 +104,34 @@ func MoveToLineStart(row, col int) (int, int) {
 	return row, 0
 }
 
-func MoveToLineEnd(lines []string, row int) (int, int) {
-	if row < 0 || row >= len(lines) {
+func MoveToLineEnd(buf *buffer.Buffer, row int) (int, int) {
+	if row < 0 || row >= buf.LineCount() {
 		return row, 0
 	}
-	return row, len([]rune(lines[row]))
+	return row, len([]rune(buf.Line(row)))
 }
 
 func MoveToFileStart() (int, int) {
 	return 0, 0
 }
 
-func MoveToFileEnd(lines []string) (int, int) {
-	if len(lines) == 0 {
+func MoveToFileEnd(buf *buffer.Buffer) (int, int) {
+	if buf.LineCount() == 0 {
 		return 0, 0
 	}
-	lastRow := len(lines) - 1
-	return lastRow, len([]rune(lines[lastRow]))
+	lastRow := buf.LineCount() - 1
+	return lastRow, len([]rune(buf.Line(lastRow)))
 }
 
-func JumpLinesUp(lines []string, row, col int) (int, int) {
+func JumpLinesUp(buf *buffer.Buffer, row, col int) (int, int) {
 	newRow := row - JumpLineCount
 	if newRow < 0 {
 		newRow = 0
 	}
 
 	// Adjust column if new line is shorter
-	if newRow < len(lines) {
-		lineLen := len([]rune(lines[newRow]))
+	if newRow < buf.LineCount() {
+		lineLen := len([]rune(buf.Line(newRow)))
 		if col > lineLen {
 			col = lineLen
 		}
@@ -138,9 +140,9 @@ func JumpLinesUp(lines []string, row, col int) (int, int) {
 	return newRow, col
 }
 
-func JumpLinesDown(lines []string, row, col int) (int, int) {
+func JumpLinesDown(buf *buffer.Buffer, row, col int) (int, int) {
 	newRow := row + JumpLineCount
-	maxRow := len(lines) - 1
+	maxRow := buf.LineCount() - 1
 	if maxRow < 0 {
 		maxRow = 0
 	}
@@ -149,8 +151,8 @@ func JumpLinesDown(lines []string, row, col int) (int, int) {
 	}
 
 	// Adjust column if new line is shorter
-	if newRow < len(lines) {
-		lineLen := len([]rune(lines[newRow]))
+	if newRow < buf.LineCount() {
+		lineLen := len([]rune(buf.Line(newRow)))
 		if col > lineLen {
 			col = lineLen
 		}
@@ -158,3 +160,140 @@ func JumpLinesDown(lines []string, row, col int) (int, int) {
 
 	return newRow, col
 }
+
+// runePos walks the runes of a buffer across lines. The position after the
+// last rune of a line stands for its line break.
+type runePos struct {
+	buf      *buffer.Buffer
+	row, col int
+	line     []rune
+}
+
+func newRunePos(buf *buffer.Buffer, row, col int) runePos {
+	line := []rune(buf.Line(row))
+	return runePos{buf: buf, row: row, col: min(col, len(line)), line: line}
+}
+
+// rune returns the rune at p, '\n' at the end of a line.
+func (p runePos) rune() rune {
+	if p.col < len(p.line) {
+		return p.line[p.col]
+	}
+	return '\n'
+}
+
+func (p *runePos) next() bool {
+	switch {
+	case p.col < len(p.line):
+		p.col++
+	case p.row < p.buf.LineCount()-1:
+		p.row++
+		p.line = []rune(p.buf.Line(p.row))
+		p.col = 0
+	default:
+		return false
+	}
+	return true
+}
+
+func (p *runePos) prev() bool {
+	switch {
+	case p.col > 0:
+		p.col--
+	case p.row > 0:
+		p.row--
+		p.line = []rune(p.buf.Line(p.row))
+		p.col = len(p.line)
+	default:
+		return false
+	}
+	return true
+}
+
+// wordClass sorts r for the vim word motions: 0 for blanks and line breaks,
+// 1 for letters, digits and _, 2 for other characters. For WORDs (big) all
+// non-blanks are 1.
+func wordClass(r rune, big bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case big || isWordRune(r):
+		return 1
+	}
+	return 2
+}
+
+// NextWordStart returns the start of the next word as vim's w motion does,
+// or of the next WORD for W. Empty lines count as words.
+func NextWordStart(buf *buffer.Buffer, row, col int, big bool) (int, int) {
+	p := newRunePos(buf, row, col)
+	if cls := wordClass(p.rune(), big); cls != 0 {
+		for wordClass(p.rune(), big) == cls {
+			if !p.next() {
+				return p.row, p.col
+			}
+		}
+	}
+	for wordClass(p.rune(), big) == 0 {
+		if !p.next() || len(p.line) == 0 {
+			break
+		}
+	}
+	return p.row, p.col
+}
+
+// PrevWordStart returns the start of the word before the cursor, as vim's b
+// and B motions do.
+func PrevWordStart(buf *buffer.Buffer, row, col int, big bool) (int, int) {
+	p := newRunePos(buf, row, col)
+	if !p.prev() {
+		return p.row, p.col
+	}
+	for wordClass(p.rune(), big) == 0 {
+		if len(p.line) == 0 || !p.prev() {
+			return p.row, p.col
+		}
+	}
+	cls := wordClass(p.rune(), big)
+	for {
+		q := p
+		if !q.prev() || wordClass(q.rune(), big) != cls {
+			return p.row, p.col
+		}
+		p = q
+	}
+}
+
+// WordEnd returns the last character of the word after the cursor, as vim's
+// e and E motions do.
+func WordEnd(buf *buffer.Buffer, row, col int, big bool) (int, int) {
+	p := newRunePos(buf, row, col)
+	if !p.next() {
+		return row, col
+	}
+	for wordClass(p.rune(), big) == 0 {
+		if !p.next() {
+			return row, col
+		}
+	}
+	cls := wordClass(p.rune(), big)
+	for {
+		q := p
+		if !q.next() || wordClass(q.rune(), big) != cls {
+			return p.row, p.col
+		}
+		p = q
+	}
+}
+
+// FirstNonBlank returns the column of the first non-blank character of row,
+// or its length if it is blank.
+func FirstNonBlank(buf *buffer.Buffer, row int) int {
+	line := []rune(buf.Line(row))
+	for i, r := range line {
+		if r != ' ' && r != '\t' {
+			return i
+		}
+	}
+	return len(line)
+}
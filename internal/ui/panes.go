@@ -0,0 +1,346 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// splitDir says how an inner layout node divides its area.
+type splitDir int
+
+const (
+	splitNone       splitDir = iota // leaf showing a single pane
+	splitVertical                   // children side by side
+	splitHorizontal                 // children stacked
+)
+
+// Pane sizes are kept between these shares of the parent area.
+const (
+	minPaneRatio   = 0.1
+	paneResizeStep = 0.05
+)
+
+// pane is a view onto a document. The focused pane lives in the Model
+// fields (active document, cursor, top), its entry in Model.panes is only
+// updated when focus moves away.
+type pane struct {
+	doc       int // index into Model.docs
+	cursorRow int
+	cursorCol int
+	top       visualLine
+}
+
+// layout is a node of the pane layout tree. Nodes are never modified in
+// place, edits build a new tree so older Model copies stay intact.
+type layout struct {
+	dir    splitDir
+	pane   int     // leaf: index into Model.panes
+	ratio  float64 // inner node: share of the area taken by first
+	first  *layout
+	second *layout
+}
+
+type rect struct {
+	x, y, w, h int
+}
+
+// splitLeaf replaces the leaf showing target by a split of target and newPane.
+func (l *layout) splitLeaf(target, newPane int, dir splitDir) *layout {
+	if l.dir == splitNone {
+		if l.pane != target {
+			return l
+		}
+		return &layout{
+			dir:    dir,
+			ratio:  0.5,
+			first:  &layout{pane: target},
+			second: &layout{pane: newPane},
+		}
+	}
+	n := *l
+	n.first = l.first.splitLeaf(target, newPane, dir)
+	n.second = l.second.splitLeaf(target, newPane, dir)
+	return &n
+}
+
+// removeLeaf drops the leaf showing target, giving its area to the sibling.
+// Panes after target are renumbered to match the removal from Model.panes.
+func (l *layout) removeLeaf(target int) *layout {
+	if l.dir == splitNone {
+		if l.pane > target {
+			return &layout{pane: l.pane - 1}
+		}
+		return l
+	}
+	if l.first.dir == splitNone && l.first.pane == target {
+		return l.second.removeLeaf(target)
+	}
+	if l.second.dir == splitNone && l.second.pane == target {
+		return l.first.removeLeaf(target)
+	}
+	n := *l
+	n.first = l.first.removeLeaf(target)
+	n.second = l.second.removeLeaf(target)
+	return &n
+}
+
+func (l *layout) contains(target int) bool {
+	if l.dir == splitNone {
+		return l.pane == target
+	}
+	return l.first.contains(target) || l.second.contains(target)
+}
+
+// resizeLeaf grows (delta > 0) or shrinks the pane target by changing the
+// ratio of the innermost split containing it. It reports whether a split was
+// found.
+func (l *layout) resizeLeaf(target int, delta float64) (*layout, bool) {
+	if l.dir == splitNone || !l.contains(target) {
+		return l, false
+	}
+	n := *l
+	var ok bool
+	if l.first.contains(target) {
+		if n.first, ok = l.first.resizeLeaf(target, delta); !ok {
+			n.ratio += delta
+		}
+	} else {
+		if n.second, ok = l.second.resizeLeaf(target, delta); !ok {
+			n.ratio -= delta
+		}
+	}
+	n.ratio = min(max(n.ratio, minPaneRatio), 1-minPaneRatio)
+	return &n, true
+}
+
+// divide splits r between the children of an inner node. Vertical splits
+// leave one column for the divider.
+func (l *layout) divide(r rect) (rect, rect) {
+	if l.dir == splitVertical {
+		w := max(int(float64(r.w-1)*l.ratio), 1)
+		return rect{r.x, r.y, w, r.h}, rect{r.x + w + 1, r.y, max(r.w-w-1, 1), r.h}
+	}
+	h := max(int(float64(r.h)*l.ratio), 1)
+	return rect{r.x, r.y, r.w, h}, rect{r.x, r.y + h, r.w, max(r.h-h, 1)}
+}
+
+// paneRect returns the area of pane target inside r.
+func (l *layout) paneRect(target int, r rect) (rect, bool) {
+	if l.dir == splitNone {
+		return r, l.pane == target
+	}
+	a, b := l.divide(r)
+	if pr, ok := l.first.paneRect(target, a); ok {
+		return pr, true
+	}
+	return l.second.paneRect(target, b)
+}
+
+// paneAt returns the pane at x, y inside r and its area, or false on a
+// divider.
+func (l *layout) paneAt(x, y int, r rect) (int, rect, bool) {
+	if l.dir == splitNone {
+		return l.pane, r, true
+	}
+	a, b := l.divide(r)
+	switch {
+	case x >= a.x && x < a.x+a.w && y >= a.y && y < a.y+a.h:
+		return l.first.paneAt(x, y, a)
+	case x >= b.x && x < b.x+b.w && y >= b.y && y < b.y+b.h:
+		return l.second.paneAt(x, y, b)
+	}
+	return 0, rect{}, false
+}
+
+// editorArea returns the size of the screen area shared by all panes.
+func (m Model) editorArea() (int, int) {
+	h := m.Height - 1
+	if m.promptActive() {
+		h -= 2
+	}
+	return m.Width, max(h, 1)
+}
+
+// paneSize returns the size available to the text of pane i, excluding the
+// pane title shown when the screen is split.
+func (m Model) paneSize(i int) (int, int) {
+	w, h := m.editorArea()
+	r, _ := m.paneLayout.paneRect(i, rect{0, 0, w, h})
+	if len(m.panes) > 1 {
+		r.h = max(r.h-1, 1)
+	}
+	return r.w, r.h
+}
+
+// splitPane splits the focused pane. The new pane shows the same document at
+// the same position and gets the focus.
+func (m Model) splitPane(dir splitDir) Model {
+	m = m.storePane()
+	m.panes = append(m.panes, m.panes[m.focus])
+	m.paneLayout = m.paneLayout.splitLeaf(m.focus, len(m.panes)-1, dir)
+	m.focus = len(m.panes) - 1
+	return m.updateViewport()
+}
+
+// closePane closes the focused pane. The document stays open.
+func (m Model) closePane() (Model, tea.Cmd) {
+	if len(m.panes) == 1 {
+		m.statusMsg = "Only one pane"
+		return m, nil
+	}
+	closed := m.focus
+	m.paneLayout = m.paneLayout.removeLeaf(closed)
+	m.panes = slices.Delete(slices.Clone(m.panes), closed, closed+1)
+	m.focus = -1 // nothing to store, the focused pane is gone
+	return m.focusPane(min(closed, len(m.panes)-1))
+}
+
+func (m Model) nextPane() (Model, tea.Cmd) {
+	if len(m.panes) == 1 {
+		m.statusMsg = "Only one pane"
+		return m, nil
+	}
+	return m.focusPane((m.focus + 1) % len(m.panes))
+}
+
+func (m Model) resizePane(delta float64) Model {
+	l, ok := m.paneLayout.resizeLeaf(m.focus, delta)
+	if !ok {
+		m.statusMsg = "Only one pane"
+		return m
+	}
+	m.paneLayout = l
+	return m.updateViewport()
+}
+
+// storePane saves the view of the focused pane into m.panes.
+func (m Model) storePane() Model {
+	m.panes = slices.Clone(m.panes)
+	m.panes[m.focus] = pane{doc: m.active, cursorRow: m.CursorRow, cursorCol: m.CursorCol, top: m.top}
+	return m
+}
+
+// focusPane moves the focus to pane i, activating its document.
+func (m Model) focusPane(i int) (Model, tea.Cmd) {
+	if i == m.focus {
+		return m, nil
+	}
+	if m.focus >= 0 {
+		m = m.storePane()
+	}
+	p := m.panes[i]
+	m.focus = i
+	var cmd tea.Cmd
+	if p.doc != m.active {
+		m, cmd = m.stash().enter(p.doc)
+		m.statusMsg = ""
+	}
+	m = m.showPane(p)
+	return m.updateViewport(), cmd
+}
+
+// showPane moves the cursor and scroll offset of the active document to p.
+func (m Model) showPane(p pane) Model {
+	m.CursorRow = min(p.cursorRow, m.Buffer.LineCount()-1)
+	m.CursorCol = min(p.cursorCol, m.Buffer.LineLen(m.CursorRow))
+	m.top = p.top
+	m.selecting = false
+	m.cursors = nil
+	m.block = nil
+	return m
+}
+
+// paneModel returns the model as seen from pane i, for rendering it.
+func (m Model) paneModel(i int) Model {
+	if i == m.focus {
+		return m
+	}
+	p := m.panes[i]
+	if p.doc != m.active {
+		m = m.stash().restore(p.doc)
+		m.searchResults = nil
+	}
+	m.focus = i
+	// The pane may have shrunk since it lost the focus.
+	return m.showPane(p).updateViewport()
+}
+
+// docClosed updates the unfocused panes after document closed was removed and
+// active became the active document.
+func (m Model) docClosed(closed, active int) Model {
+	m.panes = slices.Clone(m.panes)
+	for i := range m.panes {
+		p := &m.panes[i]
+		switch {
+		case i == m.focus:
+			p.doc = active
+		case p.doc == closed:
+			*p = pane{doc: active}
+		case p.doc > closed:
+			p.doc--
+		}
+	}
+	return m
+}
+
+// viewPanes renders all panes of the layout.
+func (m Model) viewPanes() string {
+	w, h := m.editorArea()
+	return m.viewLayout(m.paneLayout, rect{0, 0, w, h})
+}
+
+func (m Model) viewLayout(l *layout, r rect) string {
+	if l.dir == splitNone {
+		return m.viewPaneAt(l.pane, r)
+	}
+	a, b := l.divide(r)
+	first := lipgloss.NewStyle().Width(a.w).Height(a.h).Render(m.viewLayout(l.first, a))
+	second := lipgloss.NewStyle().Width(b.w).Height(b.h).Render(m.viewLayout(l.second, b))
+	if l.dir == splitHorizontal {
+		return lipgloss.JoinVertical(lipgloss.Left, first, second)
+	}
+	divider := strings.TrimSuffix(strings.Repeat(splitDividerStyle.Render("│")+"\n", r.h), "\n")
+	return lipgloss.JoinHorizontal(lipgloss.Top, first, divider, second)
+}
+
+func (m Model) viewPaneAt(i int, r rect) string {
+	pm := m.paneModel(i)
+	if len(m.panes) == 1 {
+		return pm.viewPane(r.w, r.h)
+	}
+
+	title := pm.FileName
+	if title == "" {
+		title = "[No Name]"
+	}
+	if pm.Modified {
+		title += " [+]"
+	}
+	title = fmt.Sprintf(" %d: %s", pm.active+1, title)
+	if runes := []rune(title); len(runes) > r.w {
+		title = "…" + string(runes[len(runes)-r.w+1:])
+	}
+	titleStyle := borderStyle
+	if i == m.focus {
+		titleStyle = statusBarStyle
+	}
+	header := titleStyle.Width(r.w).Render(title)
+	return header + "\n" + pm.viewPane(r.w, max(r.h-1, 1))
+}
+
+// viewPane renders the document of the model in a w×h area, next to its
+// markdown preview if that is enabled.
+func (m Model) viewPane(w, h int) string {
+	if m.viewMode == ViewModeSplit && isMarkdownFile(m.FileName) {
+		return m.viewSplit(w, h)
+	}
+	return m.viewEditor(editorViewConfig{
+		width:        w,
+		height:       h,
+		showSearchUI: m.searching,
+	})
+}
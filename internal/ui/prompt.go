@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// promptChoice is one answer of a choicePrompt, picked by pressing key.
+type promptChoice struct {
+	key    string
+	label  string
+	action func(Model) (Model, tea.Cmd)
+}
+
+// choicePrompt asks a question answered with a single key press, e.g.
+// "Swap file found  [r] Recover  [d] Diff  [x] Discard". Esc picks the choice
+// bound to "esc" if there is one and otherwise just closes the prompt.
+type choicePrompt struct {
+	question string
+	choices  []promptChoice
+}
+
+func (p *choicePrompt) View() string {
+	var sb strings.Builder
+	sb.WriteString(p.question)
+	for _, c := range p.choices {
+		k := c.key
+		if k == "esc" {
+			k = "Esc"
+		}
+		sb.WriteString(fmt.Sprintf("  [%s] %s", k, c.label))
+	}
+	return textInputStyle.Render(sb.String())
+}
+
+// ask shows p, or queues it until the prompt showing now is answered.
+func (m Model) ask(p *choicePrompt) Model {
+	if m.prompt == nil {
+		m.prompt = p
+	} else {
+		m.queuedPrompts = append(slices.Clip(m.queuedPrompts), p)
+	}
+	return m
+}
+
+// updatePrompt answers the prompt with msg, then shows the next queued one.
+func (m Model) updatePrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	m, cmd := m.answerPrompt(msg)
+	if m.prompt == nil && len(m.queuedPrompts) > 0 {
+		m.prompt = m.queuedPrompts[0]
+		m.queuedPrompts = m.queuedPrompts[1:]
+	}
+	return m, cmd
+}
+
+func (m Model) answerPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.prompt
+	for _, c := range p.choices {
+		if msg.String() == c.key {
+			m.prompt = nil
+			if c.action == nil {
+				return m, nil
+			}
+			return c.action(m)
+		}
+	}
+	if msg.Type == tea.KeyEsc {
+		m.prompt = nil
+	}
+	return m, nil
+}
@@ -0,0 +1,137 @@
+package ui
+
+import (
+	"maps"
+	"strings"
+
+	"larry/internal/buffer"
+	"larry/internal/vim"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Registers hold text inside the editor under a single character name, see
+// vim.IsRegister: a to z and 0 to 9, where A to Z append to the lowercase
+// register, " for the unnamed register of vim and + or * for the system
+// clipboard. copy_to_register and paste_from_register ask for the name, in
+// the vim keymap it is typed before the command, e.g. "ayy.
+
+// register is the text of a register.
+type register struct {
+	text     string
+	linewise bool // whole lines, put below or above the cursor line by vim
+}
+
+// setRegister puts text into register name, "" for the unnamed one. Vim
+// keeps the text of every register in the unnamed one too.
+func (m Model) setRegister(name, text string, linewise bool) Model {
+	if name == "+" || name == "*" {
+		if err := m.clipboardWrite(text); err != nil {
+			m.statusMsg = "Clipboard unavailable: " + err.Error()
+		}
+	}
+	regs := maps.Clone(m.registers)
+	if regs == nil {
+		regs = make(map[string]register)
+	}
+	switch {
+	case name >= "A" && name <= "Z":
+		name = strings.ToLower(name)
+		if old, ok := regs[name]; ok {
+			text = old.text + text
+			linewise = old.linewise || linewise
+		}
+		fallthrough
+	case name != "" && name != `"` && name != "+" && name != "*":
+		regs[name] = register{text, linewise}
+	}
+	if m.vim != nil || name == "" || name == `"` {
+		regs[`"`] = register{text, linewise}
+	}
+	m.registers = regs
+	return m
+}
+
+// getRegister returns the text of register name, "" for the unnamed one.
+// An empty unnamed register holds the clipboard.
+func (m Model) getRegister(name string) (register, error) {
+	switch name {
+	case "", `"`:
+		if r := m.registers[`"`]; r.text != "" {
+			return r, nil
+		}
+	case "+", "*":
+	default:
+		return m.registers[strings.ToLower(name)], nil
+	}
+	text, err := m.clipboardRead()
+	if err != nil {
+		return register{}, err
+	}
+	text = buffer.NormalizeLineBreaks(text)
+	return register{text, strings.HasSuffix(text, "\n")}, nil
+}
+
+// readRegister asks for the name of a register and passes it to action.
+func (m Model) readRegister(question string, action func(Model, string) Model) Model {
+	m.registerAction = action
+	m.statusMsg = question + ` (a-z, 0-9, " or +)`
+	return m
+}
+
+// registerKey handles the key typed after readRegister.
+func (m Model) registerKey(msg tea.KeyMsg) Model {
+	action := m.registerAction
+	m.registerAction = nil
+	switch k := msg.String(); {
+	case msg.Type == tea.KeyEsc:
+		m.statusMsg = ""
+		return m
+	case !vim.IsRegister(k):
+		m.statusMsg = "Not a register: " + k
+		return m
+	default:
+		m.statusMsg = ""
+		return action(m, k)
+	}
+}
+
+// copyToRegister asks for a register and copies the selections into it.
+func (m Model) copyToRegister() Model {
+	text, ok := m.selectedTexts()
+	if !ok {
+		m.statusMsg = "Nothing selected"
+		return m
+	}
+	return m.readRegister("Copy to register", func(m Model, name string) Model {
+		m = m.setRegister(name, text, false)
+		m.mark = false
+		m = m.forEachCursor(func(m Model, _ int) Model {
+			m.selecting = false
+			return m
+		})
+		if m.statusMsg == "" {
+			m.statusMsg = "Copied to register " + name
+		}
+		return m
+	})
+}
+
+// pasteFromRegister asks for a register and pastes its text.
+func (m Model) pasteFromRegister() Model {
+	return m.readRegister("Paste from register", func(m Model, name string) Model {
+		r, err := m.getRegister(name)
+		switch {
+		case err != nil:
+			m.statusMsg = "Paste Error: " + err.Error()
+		case r.text == "":
+			m.statusMsg = "Register " + name + " is empty"
+		default:
+			m = m.pasteText(r.text)
+			if m.statusMsg == "" {
+				m.statusMsg = "Pasted register " + name
+			}
+		}
+		return m
+	})
+}
@@ -2,43 +2,132 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"larry/internal/search"
+	"regexp/syntax"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
-// SearchResultsMsg carries the results of a background search
-type SearchResultsMsg struct {
-	Query     string
-	Results   []search.SearchMatch
-	IsReplace bool // True if this is for the replace feature
+// findMatches returns the matches of pattern in the buffer, or why the
+// pattern is invalid.
+func (m Model) findMatches(pattern string) ([]search.SearchMatch, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	searcher, err := search.NewSearcher(pattern, m.searchOpts)
+	if err != nil {
+		return nil, err
+	}
+	return searcher.SearchInSource(context.Background(), m.Buffer), nil
 }
 
-// PerformSearchCmd creates a command to run the search in a goroutine
-func PerformSearchCmd(lines []string, query string, isReplace bool) (tea.Cmd, context.CancelFunc) {
-	ctx, cancel := context.WithCancel(context.Background())
+// findSearchMatches searches for the query of the search prompt.
+func (m Model) findSearchMatches() Model {
+	var err error
+	m.searchResults, err = m.findMatches(m.searchQuery)
+	m.searchErr = patternError(err)
+	m.currentResultIndex = -1
+	return m
+}
 
-	cmd := func() tea.Msg {
-		if query == "" {
-			return SearchResultsMsg{Query: query, Results: nil, IsReplace: isReplace}
-		}
+// findReplaceMatches searches for the query of the replace prompt.
+func (m Model) findReplaceMatches() Model {
+	var err error
+	m.replaceResults, err = m.findMatches(m.replaceQuery)
+	m.searchErr = patternError(err)
+	m.currReplaceIndex = -1
+	return m
+}
 
-		// Passing 'lines' here passes the slice header at the moment of call.
-		// Since strings are immutable in Go, searching the old lines is thread-safe
-		// even if the main thread replaces them with new strings in a new slice.
-		searcher := search.NewBoyerMooreSearch(query)
-		results := searcher.SearchInLines(ctx, lines)
+// patternError describes an invalid pattern in a few words, e.g.
+// "invalid regex: missing closing )".
+func patternError(err error) string {
+	var syntaxErr *syntax.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &syntaxErr):
+		return "invalid regex: " + syntaxErr.Code.String()
+	}
+	return err.Error()
+}
 
-		if ctx.Err() != nil {
-			return nil
-		}
+// replacement returns the text replacing match, the replace text with the
+// groups of a regex filled in.
+func (m Model) replacement(match search.SearchMatch) string {
+	searcher, err := search.NewSearcher(m.replaceQuery, m.searchOpts)
+	if err != nil {
+		return m.replaceWith
+	}
+	return searcher.Replacement(m.Buffer.Line(match.Line), match, m.replaceWith)
+}
 
-		return SearchResultsMsg{
-			Query:     query,
-			Results:   results,
-			IsReplace: isReplace,
-		}
+// matchStart returns the row and column of the start of match, whose
+// columns are in bytes.
+func (m Model) matchStart(match search.SearchMatch) (int, int) {
+	return m.Buffer.Position(m.Buffer.Offset(match.Line, 0) + match.Col)
+}
+
+// matchEnd returns the row and column of the end of match.
+func (m Model) matchEnd(match search.SearchMatch) (int, int) {
+	return m.Buffer.Position(m.Buffer.Offset(match.Line, 0) + match.Col + match.Length)
+}
+
+// toggleOption switches the option of opts msg is bound to, if any: regular
+// expressions, ignoring case, smart case or whole words.
+func toggleOption(opts search.Options, msg tea.KeyMsg, keys KeyMap) (search.Options, bool) {
+	switch {
+	case key.Matches(msg, keys.SearchRegex):
+		opts.Regex = !opts.Regex
+	case key.Matches(msg, keys.SearchIgnoreCase):
+		opts.IgnoreCase = !opts.IgnoreCase
+	case key.Matches(msg, keys.SearchSmartCase):
+		opts.SmartCase = !opts.SmartCase
+	case key.Matches(msg, keys.SearchWholeWord):
+		opts.WholeWord = !opts.WholeWord
+	default:
+		return opts, false
+	}
+	return opts, true
+}
+
+// toggleSearchOption switches the option of the search and replace prompts
+// bound to msg, if any, and searches again, see toggleOption.
+func (m Model) toggleSearchOption(msg tea.KeyMsg) (Model, bool) {
+	if m.replacing && m.replaceStep == 3 {
+		return m, false
+	}
+	opts, ok := toggleOption(m.searchOpts, msg, m.KeyMap)
+	if !ok {
+		return m, false
 	}
+	m.searchOpts = opts
+	switch {
+	case m.searching:
+		m = m.findSearchMatches()
+	case m.replaceStep == 1:
+		m = m.findReplaceMatches()
+	}
+	return m, true
+}
+
+// searchBadges shows the options of the search and replace prompts.
+func (m Model) searchBadges() string {
+	return optionBadges(m.searchOpts)
+}
 
-	return cmd, cancel
+// optionBadges shows the options of a search, the ones in use highlighted:
+// .* for regular expressions, Aa to ignore case, Sc for smart case and \b
+// for whole words.
+func optionBadges(opts search.Options) string {
+	badge := func(label string, on bool) string {
+		if on {
+			return " " + lipgloss.NewStyle().Background(lipgloss.Color("62")).Foreground(lipgloss.Color("255")).Render(label)
+		}
+		return " " + lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(label)
+	}
+	return badge(".*", opts.Regex) + badge("Aa", opts.IgnoreCase) + badge("Sc", opts.SmartCase) + badge(`\b`, opts.WholeWord)
 }
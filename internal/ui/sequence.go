@@ -0,0 +1,109 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"larry/internal/keymap"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Keys can be bound to sequences such as "ctrl+k ctrl+c". While one is typed
+// the keys so far are kept in Model.pendingKeys, and if no key follows within
+// whichKeyDelay, a popup lists the keys that can.
+
+const whichKeyDelay = 500 * time.Millisecond
+
+// whichKeyMsg shows the popup for the sequence it was started for, unless
+// that one is finished by then.
+type whichKeyMsg struct {
+	id int
+}
+
+// sequenceKey feeds msg to the key sequence state machine. It returns the key
+// to handle, which for a completed sequence is the key of its action, and
+// false if there is none yet.
+func (m Model) sequenceKey(msg tea.KeyMsg) (Model, tea.KeyMsg, tea.Cmd, bool) {
+	keys := append(slices.Clone(m.pendingKeys), msg.String())
+	seq := strings.Join(keys, " ")
+	complete, prefix := m.KeyMap.sequence(seq)
+	switch {
+	case complete:
+		m.pendingKeys = nil
+		m.whichKey = false
+		return m, sequenceMsg(seq), nil, true
+	case prefix:
+		m.pendingKeys = keys
+		m.whichKey = false
+		m.sequenceID++
+		id := m.sequenceID
+		return m, msg, tea.Tick(whichKeyDelay, func(time.Time) tea.Msg { return whichKeyMsg{id} }), false
+	case len(m.pendingKeys) == 0:
+		return m, msg, nil, true
+	}
+
+	// Esc cancels the sequence, any other key is a mistake.
+	m.pendingKeys = nil
+	m.whichKey = false
+	if msg.Type != tea.KeyEsc {
+		m.statusMsg = keymap.Label(seq) + " is not bound"
+	}
+	return m, msg, nil, false
+}
+
+// viewWhichKey renders the popup listing the keys that can follow the ones
+// typed so far, in as many columns as fit into width.
+func (m Model) viewWhichKey(width int) string {
+	bg := modalStyle.GetBackground()
+	spacerStyle := lipgloss.NewStyle().Background(bg)
+
+	var entries []helpEntry
+	for _, c := range m.KeyMap.continuations(strings.Join(m.pendingKeys, " ")) {
+		desc := "+more"
+		if c.action != "" {
+			desc = strings.ReplaceAll(c.action, "_", " ")
+		}
+		entries = append(entries, helpEntry{keymap.Label(c.key), desc})
+	}
+
+	keyWidth, descWidth := 0, 0
+	for _, e := range entries {
+		keyWidth = max(keyWidth, lipgloss.Width(e.Key)+2)
+		descWidth = max(descWidth, lipgloss.Width(e.Desc)+3)
+	}
+	w := max(width-4, keyWidth+descWidth)
+	cols := max(min(w/(keyWidth+descWidth), len(entries)), 1)
+	rows := (len(entries) + cols - 1) / cols
+
+	var lines []string
+	for r := range rows {
+		var cells []string
+		for c := range cols {
+			i := c*rows + r
+			if i >= len(entries) {
+				break
+			}
+			cells = append(cells,
+				keyStyle.Width(keyWidth).Render(entries[i].Key),
+				descStyle.Width(descWidth).Render(entries[i].Desc))
+		}
+		lines = append(lines, spacerStyle.Width(w).Render(lipgloss.JoinHorizontal(lipgloss.Top, cells...)))
+	}
+
+	title := modalTitleStyle.Width(w).Render(keymap.Label(strings.Join(m.pendingKeys, " ")) + ", …")
+	return modalStyle.Render(strings.Join(append([]string{title}, lines...), "\n"))
+}
+
+// overlayBottom draws popup over the last lines of view, which is padded to
+// height lines first.
+func overlayBottom(view, popup string, height int) string {
+	lines := strings.Split(view, "\n")
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	keep := max(len(lines)-lipgloss.Height(popup), 0)
+	return strings.Join(lines[:keep], "\n") + "\n" + popup
+}
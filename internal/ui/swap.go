@@ -0,0 +1,274 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"larry/internal/buffer"
+	"larry/internal/diff"
+	"larry/internal/swap"
+	"larry/internal/undo"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// swapInterval is how often unsaved changes are journaled to the swap file.
+const swapInterval = 2 * time.Second
+
+type swapTickMsg struct{}
+
+type swapWrittenMsg struct {
+	err error
+}
+
+func swapTickCmd() tea.Cmd {
+	return tea.Tick(swapInterval, func(time.Time) tea.Msg {
+		return swapTickMsg{}
+	})
+}
+
+// journal writes every document whose buffer changed since the last tick to
+// its swap file, together with the undo history, and schedules the next tick.
+func (m Model) journal() (Model, tea.Cmd) {
+	if !m.Config.Swap {
+		return m, nil
+	}
+	cmds := []tea.Cmd{swapTickCmd()}
+	m = m.stash()
+	for i := range m.docs {
+		d := &m.docs[i]
+		if !d.swapPending || !d.modified {
+			continue
+		}
+		if d.fileName == "" && d.swapID == "" {
+			d.swapID = swap.NewID()
+		}
+		d.swapPending = false
+		cmds = append(cmds, m.swaps.writeCmd(*d))
+	}
+	m = m.restore(m.active)
+	return m, tea.Batch(cmds...)
+}
+
+// swapJournal orders the writes of swap files, which run in commands, and
+// their removal, which happens right away. Removing a swap file moves on its
+// generation, so that a write started before is dropped instead of bringing
+// back the file.
+type swapJournal struct {
+	mu  sync.Mutex
+	gen map[string]int // by swapKey
+}
+
+func newSwapJournal() *swapJournal {
+	return &swapJournal{gen: map[string]int{}}
+}
+
+// swapKey names the swap file of a file or of an untitled buffer.
+func swapKey(path, id string) string {
+	if path != "" {
+		return path
+	}
+	return "untitled:" + id
+}
+
+// writeCmd journals d in a command. The buffer is a snapshot and the undo
+// history a copy, so editing goes on while it is written.
+func (j *swapJournal) writeCmd(d document) tea.Cmd {
+	key := swapKey(d.fileName, d.swapID)
+	j.mu.Lock()
+	gen := j.gen[key]
+	j.mu.Unlock()
+
+	snap := d.buffer.Snapshot()
+	history := cloneHistory(d.history)
+	path, id := d.fileName, d.swapID
+	return func() tea.Msg {
+		data, err := json.Marshal(history)
+		if err != nil {
+			data = nil
+		}
+		f := swap.File{Path: path, ID: id, Content: snap.String(), Undo: data}
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if j.gen[key] != gen {
+			// Removed since, e.g. saved.
+			return swapWrittenMsg{}
+		}
+		return swapWrittenMsg{err: swap.Write(f)}
+	}
+}
+
+// remove removes the swap file of the file at path, or of the untitled buffer
+// with the given id, and drops any write of it still to come.
+func (j *swapJournal) remove(path, id string) {
+	key := swapKey(path, id)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.gen[key]++
+	if path != "" {
+		swap.Remove(path)
+	} else {
+		swap.RemoveID(id)
+	}
+}
+
+// discardSwap removes the swap file of the current buffer, e.g. after saving
+// or when its unsaved changes are deliberately thrown away.
+func (m *Model) discardSwap() {
+	m.swapPending = false
+	switch {
+	case !m.Config.Swap:
+	case m.FileName != "":
+		m.swaps.remove(m.FileName, "")
+	case m.swapID != "":
+		m.swaps.remove("", m.swapID)
+		m.swapID = ""
+	}
+}
+
+// checkSwap looks for a swap file left behind for the current buffer and asks
+// what to do with it.
+func (m Model) checkSwap() Model {
+	m.swapChecked = true
+	if !m.Config.Swap || m.FileName == "" {
+		return m
+	}
+	f, err := swap.Read(m.FileName)
+	if err != nil || f.PID == os.Getpid() {
+		return m
+	}
+	if f.Content == m.Buffer.String() {
+		m.swaps.remove(m.FileName, "")
+		return m
+	}
+
+	question := fmt.Sprintf("Swap file found for %s (%s)", filepath.Base(m.FileName), f.Saved.Format("2006-01-02 15:04"))
+	if f.InUse() {
+		question += fmt.Sprintf(", still open in pid %d", f.PID)
+	}
+
+	p := &choicePrompt{question: question}
+	p.choices = []promptChoice{
+		{key: "r", label: "Recover", action: func(m Model) (Model, tea.Cmd) {
+			return m.recoverSwap(f), nil
+		}},
+		{key: "d", label: "Diff", action: func(m Model) (Model, tea.Cmd) {
+			lines := diff.Unified(m.Buffer.Lines(), strings.Split(f.Content, "\n"), 3)
+			m.diffView = newDiffViewer("File on disk → swap file", lines)
+			m.prompt = p
+			return m, nil
+		}},
+		{key: "x", label: "Discard", action: func(m Model) (Model, tea.Cmd) {
+			m.swaps.remove(m.FileName, "")
+			m.statusMsg = "Discarded swap file"
+			return m, nil
+		}},
+		{key: "esc", label: "Ignore"},
+	}
+	return m.ask(p)
+}
+
+// checkUntitledSwaps looks for swap files left behind by the untitled buffers
+// of an editor that didn't exit normally and asks what to do with them.
+func (m Model) checkUntitledSwaps() Model {
+	if !m.Config.Swap {
+		return m
+	}
+	all, err := swap.Untitled()
+	if err != nil {
+		return m
+	}
+	var files []swap.File
+	for _, f := range all {
+		if f.PID != os.Getpid() && !f.InUse() {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return m
+	}
+
+	saved := files[len(files)-1].Saved.Format("2006-01-02 15:04")
+	question := fmt.Sprintf("Swap file found for an untitled buffer (%s)", saved)
+	if len(files) > 1 {
+		question = fmt.Sprintf("Swap files found for %d untitled buffers (last %s)", len(files), saved)
+	}
+	p := &choicePrompt{question: question}
+	p.choices = []promptChoice{
+		{key: "r", label: "Recover", action: func(m Model) (Model, tea.Cmd) {
+			return m.recoverUntitled(files)
+		}},
+		{key: "x", label: "Discard", action: func(m Model) (Model, tea.Cmd) {
+			for _, f := range files {
+				m.swaps.remove("", f.ID)
+			}
+			m.statusMsg = "Discarded swap files of untitled buffers"
+			return m, nil
+		}},
+		{key: "esc", label: "Ignore"},
+	}
+	return m.ask(p)
+}
+
+// recoverUntitled opens the untitled buffers journaled in files, each in a
+// buffer of its own, and switches to the first one. An untouched empty
+// buffer is replaced.
+func (m Model) recoverUntitled(files []swap.File) (Model, tea.Cmd) {
+	m = m.stash()
+	first := len(m.docs)
+	for i, f := range files {
+		buf := buffer.New(f.Content)
+		buf.Format = buffer.DefaultFormat()
+		d := newDocument("", buf)
+		d.history = swapHistory(f)
+		d.modified = true
+		d.swapID = f.ID
+		d.swapPending = true // rewritten by this process from now on
+		d.swapChecked = true
+		if i == 0 && m.FileName == "" && !m.Modified && m.Buffer.Len() == 0 {
+			m.docs[m.active] = d
+			first = m.active
+			continue
+		}
+		m.docs = append(m.docs, d)
+	}
+	m, cmd := m.enter(first)
+	m.statusMsg = fmt.Sprintf("Recovered %d untitled buffer(s) from swap files", len(files))
+	return m, cmd
+}
+
+// recoverSwap replaces the buffer content and undo history with the swap file.
+func (m Model) recoverSwap(f swap.File) Model {
+	buf := buffer.New(f.Content)
+	buf.Format = m.Buffer.Format
+	buf.Stamp = m.Buffer.Stamp
+	m.Buffer = buf
+
+	m.history = swapHistory(f)
+
+	m.markModified()
+	m.selecting = false
+	m.cursors = nil
+	m.block = nil
+	m.CursorRow = min(m.CursorRow, buf.LineCount()-1)
+	m.CursorCol = min(m.CursorCol, buf.LineLen(m.CursorRow))
+	m = m.updateViewport()
+	m.statusMsg = "Recovered unsaved changes from swap file"
+	return m
+}
+
+// swapHistory returns the undo history journaled in f, or an empty one if it
+// is missing or damaged.
+func swapHistory(f swap.File) undo.Tree[UndoGroup] {
+	var h undo.Tree[UndoGroup]
+	if json.Unmarshal(f.Undo, &h) != nil || !h.Valid() {
+		return undo.Tree[UndoGroup]{}
+	}
+	return h
+}
@@ -0,0 +1,268 @@
+package ui
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"larry/internal/buffer"
+	"larry/internal/config"
+	"larry/internal/undo"
+)
+
+type OpType int
+
+const (
+	OpInsert OpType = iota
+	OpDelete
+)
+
+// EditOp is a single change of the buffer. Row and Col are the position of
+// the change at the time it was made.
+type EditOp struct {
+	Type OpType
+	Row  int
+	Col  int
+	Text string
+}
+
+// CursorState is the cursor and selection around an undo step.
+type CursorState struct {
+	Row       int
+	Col       int
+	Selecting bool
+	StartRow  int
+	StartCol  int
+}
+
+type editKind int
+
+const (
+	editOther editKind = iota
+	editTyping
+	editDeleting
+)
+
+// UndoGroup is one step of the undo history: the edits made by one command,
+// undone and redone together, and the cursor state before and after them.
+type UndoGroup struct {
+	Ops    []EditOp
+	Before CursorState
+	After  CursorState
+	Kind   editKind `json:"-"`
+}
+
+func (m Model) cursorState() CursorState {
+	return CursorState{
+		Row:       m.CursorRow,
+		Col:       m.CursorCol,
+		Selecting: m.selecting,
+		StartRow:  m.startRow,
+		StartCol:  m.startCol,
+	}
+}
+
+func (m *Model) setCursorState(c CursorState) {
+	m.CursorRow, m.CursorCol = c.Row, c.Col
+	m.selecting = c.Selecting
+	m.startRow, m.startCol = c.StartRow, c.StartCol
+}
+
+// beginEdit opens an undo transaction: every op pushed until commitEdit
+// becomes part of the same undo step.
+func (m *Model) beginEdit() {
+	m.edit = &UndoGroup{Before: m.cursorState()}
+}
+
+// setEditKind marks the open transaction as typing or deleting, so that it
+// can be coalesced with the previous one.
+func (m *Model) setEditKind(k editKind) {
+	if m.edit != nil {
+		m.edit.Kind = k
+	}
+}
+
+// pushUndo records op in the open transaction. Outside of a transaction the
+// op forms an undo step of its own.
+func (m *Model) pushUndo(op EditOp) {
+	if m.edit == nil {
+		m.beginEdit()
+		m.edit.Ops = append(m.edit.Ops, op)
+		m.commitEdit()
+		return
+	}
+	m.edit.Ops = append(m.edit.Ops, op)
+}
+
+// commitEdit closes the transaction opened by beginEdit and adds it to the
+// undo history. Consecutive typing is merged into word sized steps and
+// consecutive deletes into one step.
+func (m *Model) commitEdit() {
+	g := m.edit
+	m.edit = nil
+	if g == nil || len(g.Ops) == 0 {
+		return
+	}
+	g.After = m.cursorState()
+
+	// The history is changed in place, see cloneHistory.
+	if prev := m.history.Last(); prev != nil && coalesce(*prev, *g) {
+		ops := make([]EditOp, 0, len(prev.Ops)+len(g.Ops))
+		ops = append(append(ops, prev.Ops...), g.Ops...)
+		*prev = UndoGroup{Ops: ops, Before: prev.Before, After: g.After, Kind: g.Kind}
+		m.history.Nodes[m.history.Current].Time = time.Now()
+		return
+	}
+	m.history.Push(*g, time.Now())
+}
+
+// cloneHistory copies the nodes of h, the edit groups themselves are never
+// modified in place and can be shared. Editing, undo and redo change the
+// history of the model in place, so a copy of the model that moves through
+// its history on its own, like the preview of the history panel, needs one.
+func cloneHistory(h undo.Tree[UndoGroup]) undo.Tree[UndoGroup] {
+	h.Nodes = slices.Clone(h.Nodes)
+	for i := range h.Nodes {
+		h.Nodes[i].Children = slices.Clip(h.Nodes[i].Children)
+	}
+	return h
+}
+
+// coalesce reports whether g continues prev: both type or both delete, and the
+// cursor did not move in between. Typing starts a new step at every word.
+func coalesce(prev, g UndoGroup) bool {
+	if g.Kind == editOther || g.Kind != prev.Kind || prev.After != g.Before {
+		return false
+	}
+	if g.Kind == editTyping {
+		last, _ := utf8.DecodeLastRuneInString(prev.Ops[len(prev.Ops)-1].Text)
+		next, _ := utf8.DecodeRuneInString(g.Ops[0].Text)
+		if isWordRune(next) && !isWordRune(last) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// applyOp performs op on the buffer, or its inverse.
+func (m *Model) applyOp(op EditOp, inverse bool) {
+	m.markModified()
+	off := m.Buffer.Offset(op.Row, op.Col)
+	if (op.Type == OpInsert) != inverse {
+		m.Buffer.InsertAt(off, op.Text)
+	} else {
+		m.Buffer.DeleteRange(off, off+len(op.Text))
+	}
+}
+
+func (m *Model) revert(g UndoGroup) {
+	m.cursors = nil
+	m.block = nil
+	for i := len(g.Ops) - 1; i >= 0; i-- {
+		m.applyOp(g.Ops[i], true)
+	}
+	m.setCursorState(g.Before)
+}
+
+func (m *Model) replay(g UndoGroup) {
+	m.cursors = nil
+	m.block = nil
+	for _, op := range g.Ops {
+		m.applyOp(op, false)
+	}
+	m.setCursorState(g.After)
+}
+
+func (m Model) undo() Model {
+	g, ok := m.history.Undo()
+	if !ok {
+		m.statusMsg = "Nothing to undo"
+		return m
+	}
+	m.revert(g)
+	m.statusMsg = "Undid change"
+	return m
+}
+
+func (m Model) redo() Model {
+	g, ok := m.history.Redo()
+	if !ok {
+		m.statusMsg = "Nothing to redo"
+		return m
+	}
+	m.replay(g)
+	m.statusMsg = "Redid change"
+	return m
+}
+
+// travel moves the buffer to state n of the undo history, undoing back to the
+// branch point and redoing along the branch that leads to n.
+func (m Model) travel(n int) Model {
+	up, down := m.history.Path(n)
+	for range up {
+		g, _ := m.history.Undo()
+		m.revert(g)
+	}
+	for _, c := range down {
+		g, _ := m.history.RedoTo(c)
+		m.replay(g)
+	}
+	return m
+}
+
+// earlier and later step through the states in the order they were created,
+// across branches.
+func (m Model) earlier() Model {
+	if m.history.Current == 0 {
+		m.statusMsg = "Already at the oldest state"
+		return m
+	}
+	m = m.travel(m.history.Current - 1)
+	m.statusMsg = fmt.Sprintf("State %d of %d", m.history.Current, m.history.Len()-1)
+	return m
+}
+
+func (m Model) later() Model {
+	if m.history.Current >= m.history.Len()-1 {
+		m.statusMsg = "Already at the newest state"
+		return m
+	}
+	m = m.travel(m.history.Current + 1)
+	m.statusMsg = fmt.Sprintf("State %d of %d", m.history.Current, m.history.Len()-1)
+	return m
+}
+
+// contentHash identifies the text of buf as loaded from or saved to disk: the
+// file content and the encoding it was decoded with.
+func contentHash(buf *buffer.Buffer) [sha256.Size]byte {
+	return sha256.Sum256(append(buf.Stamp.Hash[:], buf.Format.Encoding.String()...))
+}
+
+// storeHistory keeps the undo history h of buf, which was just saved to
+// filename, so that it can be restored when the file is opened again.
+func storeHistory(cfg config.Config, filename string, buf *buffer.Buffer, h undo.Tree[UndoGroup]) error {
+	if !cfg.UndoFile || filename == "" || h.Len() == 1 {
+		return nil
+	}
+	return undo.Save(filename, contentHash(buf), h)
+}
+
+// loadHistory returns the stored undo history of filename if buf still holds
+// the text the history was saved with, or an empty history.
+func loadHistory(cfg config.Config, filename string, buf *buffer.Buffer) undo.Tree[UndoGroup] {
+	if !cfg.UndoFile || filename == "" {
+		return undo.Tree[UndoGroup]{}
+	}
+	h, err := undo.Load[UndoGroup](filename, contentHash(buf))
+	if err != nil {
+		return undo.Tree[UndoGroup]{}
+	}
+	return h
+}
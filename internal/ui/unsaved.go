@@ -0,0 +1,138 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"larry/internal/buffer"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// modifiedBuffers returns the names of all documents with unsaved changes.
+func (m Model) modifiedBuffers() []string {
+	m = m.stash()
+	var names []string
+	for i, d := range m.docs {
+		if d.modified {
+			names = append(names, m.bufferName(i))
+		}
+	}
+	return names
+}
+
+// quit leaves the editor without saving anything.
+func (m Model) quit() (Model, tea.Cmd) {
+	m.discardAllSwaps()
+	// Nothing can be shown any more, a lost history is no reason to stay.
+	_ = m.saveKillRing()
+	m.Quitting = true
+	return m, tea.Quit
+}
+
+// confirmQuit quits right away if nothing is modified and asks otherwise.
+func (m Model) confirmQuit() (Model, tea.Cmd) {
+	modified := m.modifiedBuffers()
+	if len(modified) == 0 {
+		return m.quit()
+	}
+
+	question := filepath.Base(modified[0]) + " has unsaved changes"
+	if len(modified) > 1 {
+		question = fmt.Sprintf("%d buffers have unsaved changes", len(modified))
+	}
+	m.prompt = &choicePrompt{
+		question: question,
+		choices: []promptChoice{
+			{key: "a", label: "Save all & quit", action: Model.saveAllAndQuit},
+			{key: "q", label: "Quit without saving", action: Model.quit},
+			{key: "esc", label: "Cancel"},
+		},
+	}
+	return m, nil
+}
+
+// saveAll saves every modified document to its file. It returns a
+// description of each document that could not be saved or whose undo history
+// could not be stored.
+func (m Model) saveAll() (Model, []string) {
+	m = m.stash()
+	var failed []string
+	for i := range m.docs {
+		d := &m.docs[i]
+		if !d.modified {
+			continue
+		}
+		name := m.bufferName(i)
+		if d.fileName == "" {
+			failed = append(failed, name+" (no file name)")
+			continue
+		}
+		if _, changed, _ := d.buffer.Stamp.Changed(d.fileName); changed {
+			failed = append(failed, name+" (changed on disk)")
+			continue
+		}
+		if err := d.buffer.Save(d.fileName, buffer.SaveOptions{Backup: m.Config.Backup}); err != nil {
+			failed = append(failed, name+" ("+err.Error()+")")
+			continue
+		}
+		d.modified = false
+		d.swapPending = false
+		if m.Config.Swap {
+			m.swaps.remove(d.fileName, "")
+		}
+		if err := storeHistory(m.Config, d.fileName, d.buffer, d.history); err != nil {
+			failed = append(failed, name+" (saved, undo history not stored: "+err.Error()+")")
+		}
+	}
+	return m.restore(m.active), failed
+}
+
+// saveAllAndQuit saves every modified document and quits, unless one of them
+// could not be saved or lost its undo history, which is reported instead.
+func (m Model) saveAllAndQuit() (Model, tea.Cmd) {
+	m, failed := m.saveAll()
+	if len(failed) > 0 {
+		m.statusMsg = "Not quitting: " + strings.Join(failed, ", ")
+		return m, nil
+	}
+	return m.quit()
+}
+
+// confirmClose closes the active buffer, asking first if it is modified.
+func (m Model) confirmClose() (Model, tea.Cmd) {
+	return m.confirmUnsaved("close", func(m Model) (Model, tea.Cmd) {
+		m.Modified = false
+		return m.closeBuffer()
+	})
+}
+
+// confirmUnsaved runs action right away if the buffer is unmodified and
+// otherwise asks whether to save first, since action throws the buffer away,
+// e.g. closes or reopens it. verb names action in the choices.
+func (m Model) confirmUnsaved(verb string, action func(Model) (Model, tea.Cmd)) (Model, tea.Cmd) {
+	if !m.Modified {
+		return action(m)
+	}
+	m.prompt = &choicePrompt{
+		question: m.bufferName(m.active) + " has unsaved changes",
+		choices: []promptChoice{
+			{key: "s", label: "Save & " + verb, action: func(m Model) (Model, tea.Cmd) {
+				if m.FileName == "" {
+					m.statusMsg = "No file name, save the buffer first"
+					return m, nil
+				}
+				m = m.save(m.FileName, false)
+				if m.Modified {
+					// Saving failed or needs confirmation, keep the buffer.
+					return m, nil
+				}
+				return action(m)
+			}},
+			{key: "x", label: "Discard & " + verb, action: action},
+			{key: "esc", label: "Cancel"},
+		},
+	}
+	return m, nil
+}
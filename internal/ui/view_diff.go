@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	diffAddStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	diffDelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+	diffHunkStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("81"))
+)
+
+// diffViewer is a scrollable modal showing a unified diff.
+type diffViewer struct {
+	title  string
+	lines  []string
+	offset int
+}
+
+func newDiffViewer(title string, lines []string) *diffViewer {
+	if len(lines) == 0 {
+		lines = []string{"No differences."}
+	}
+	return &diffViewer{title: title, lines: lines}
+}
+
+func (m Model) diffViewHeight() int {
+	return max(m.Height-8, 3)
+}
+
+func (m Model) updateDiffView(msg tea.KeyMsg) (Model, tea.Cmd) {
+	d := *m.diffView
+	page := m.diffViewHeight()
+	switch msg.String() {
+	case "esc", "q", "enter":
+		m.diffView = nil
+		return m, nil
+	case "up", "k":
+		d.offset--
+	case "down", "j":
+		d.offset++
+	case "pgup":
+		d.offset -= page
+	case "pgdown", " ":
+		d.offset += page
+	case "home":
+		d.offset = 0
+	case "end":
+		d.offset = len(d.lines)
+	}
+	d.offset = min(d.offset, len(d.lines)-page)
+	d.offset = max(d.offset, 0)
+	m.diffView = &d
+	return m, nil
+}
+
+func (m Model) viewDiff() string {
+	d := m.diffView
+	w := max(m.Width-8, 20)
+	h := m.diffViewHeight()
+
+	bg := modalStyle.GetBackground()
+	spacerStyle := lipgloss.NewStyle().Background(bg)
+
+	titleText := d.title + " (↑/↓ scroll, Esc close)"
+	title := modalTitleStyle.Width(w).Render(titleText)
+
+	var allLines []string
+	allLines = append(allLines, title)
+	allLines = append(allLines, spacerStyle.Width(w).Render(""))
+
+	end := min(d.offset+h, len(d.lines))
+	for _, line := range d.lines[d.offset:end] {
+		runes := []rune(line)
+		if len(runes) > w {
+			line = string(runes[:w])
+		}
+		style := spacerStyle
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			style = diffHunkStyle.Background(bg)
+		case strings.HasPrefix(line, "+"):
+			style = diffAddStyle.Background(bg)
+		case strings.HasPrefix(line, "-"):
+			style = diffDelStyle.Background(bg)
+		}
+		allLines = append(allLines, style.Width(w).Render(line))
+	}
+	for i := end - d.offset; i < h; i++ {
+		allLines = append(allLines, spacerStyle.Width(w).Render(""))
+	}
+
+	modal := modalStyle.Render(strings.Join(allLines, "\n"))
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, modal)
+}
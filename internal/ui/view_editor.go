@@ -2,7 +2,9 @@ package ui
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -14,64 +16,67 @@ type editorViewConfig struct {
 }
 
 func (m Model) viewEditor(cfg editorViewConfig) string {
-	if len(m.Lines) == 0 {
-		s := strings.Builder{}
-		s.WriteString(borderStyle.Render("│"))
-		if m.Config.LineNumbers {
-			s.WriteString(lineNumStyle.Render("   1 "))
+	// Cursors by position, true if the cursor has a selection.
+	cursorsAt := map[[2]int]bool{}
+	var selections []selection
+	for _, c := range append([]CursorState{m.cursorState()}, m.cursors...) {
+		cursorsAt[[2]int{c.Row, c.Col}] = c.Selecting
+		if c.Selecting {
+			selections = append(selections, newSelection(c))
 		}
-		s.WriteString(styleCursor.Render(" "))
-		s.WriteString("\n")
-		return s.String()
 	}
-
-	selStartRow, selStartCol := -1, -1
-	selEndRow, selEndCol := -1, -1
-
-	if m.selecting {
-		sRow, sCol := m.startRow, m.startCol
-		eRow, eCol := m.CursorRow, m.CursorCol
-
-		if sRow > eRow || (sRow == eRow && sCol > eCol) {
-			sRow, sCol, eRow, eCol = eRow, eCol, sRow, sCol
+	if m.block != nil {
+		for _, sel := range m.blockRanges() {
+			if sel.startCol < sel.endCol {
+				selections = append(selections, sel)
+			} else {
+				cursorsAt[[2]int{sel.startRow, sel.startCol}] = false
+			}
 		}
-		selStartRow, selStartCol = sRow, sCol
-		selEndRow, selEndCol = eRow, eCol
 	}
 
-	cursorRow := m.CursorRow
-	cursorCol := m.CursorCol
+	textWidth := m.textWidth(cfg.width)
 
-	textWidth := cfg.width
-	if m.Config.LineNumbers {
-		textWidth -= 6
-	}
-	textWidth -= 1
-	if textWidth < 1 {
-		textWidth = 1
-	}
-
-	lines := m.Lines
+	lineCount := m.Buffer.LineCount()
 	var s strings.Builder
 
 	maxVisualLines := cfg.height
 	visualLinesRendered := 0
-	currentVisualLineIndex := 0
 
 	if cfg.showSearchUI {
 		maxVisualLines -= 2
 	}
 
-	for lineNum := 0; lineNum < len(lines) && visualLinesRendered < maxVisualLines; lineNum++ {
-		line := lines[lineNum]
+	// Rendering starts at the top of the view. The search results are in
+	// document order, so the ones of each line shown are taken from next on.
+	top := m.clampVisual(m.top, textWidth)
+	next := sort.Search(len(m.searchResults), func(i int) bool { return m.searchResults[i].Line >= top.row })
+
+	for lineNum := top.row; lineNum < lineCount && visualLinesRendered < maxVisualLines; lineNum++ {
+		line := m.Buffer.Line(lineNum)
 		lineRunes := []rune(line)
 
-		renderChunk := func(runes []rune, startIdx, endIdx int, isFirst bool, _ int) {
-			if visualLinesRendered >= maxVisualLines {
-				return
+		// Search results, with their columns in runes.
+		var lineMatches []selection
+		for ; next < len(m.searchResults) && m.searchResults[next].Line <= lineNum; next++ {
+			r := m.searchResults[next]
+			// Results found before an edit may lie past the end of the line.
+			if r.Line == lineNum && r.Col+r.Length <= len(line) {
+				start := utf8.RuneCountInString(line[:r.Col])
+				end := start + utf8.RuneCountInString(line[r.Col:r.Col+r.Length])
+				lineMatches = append(lineMatches, selection{lineNum, start, lineNum, end})
 			}
-			if currentVisualLineIndex < m.yOffset {
-				currentVisualLineIndex++
+		}
+
+		var lineSelections []selection
+		for _, sel := range selections {
+			if sel.startRow <= lineNum && lineNum <= sel.endRow {
+				lineSelections = append(lineSelections, sel)
+			}
+		}
+
+		renderChunk := func(runes []rune, startIdx, endIdx int, isFirst bool) {
+			if visualLinesRendered >= maxVisualLines {
 				return
 			}
 
@@ -85,7 +90,7 @@ func (m Model) viewEditor(cfg editorViewConfig) string {
 				}
 			}
 
-			syntaxStyles := GetLineStyles(m.Lines[lineNum], m.FileName)
+			syntaxStyles := GetLineStyles(line, m.FileName)
 
 			for i := startIdx; i < endIdx; i++ {
 				ch := runes[i]
@@ -97,40 +102,24 @@ func (m Model) viewEditor(cfg editorViewConfig) string {
 					applyStyle = true
 				}
 
-				if len(m.searchResults) > 0 {
-					for _, result := range m.searchResults {
-						if result.Line == lineNum && i >= result.Col && i < result.Col+result.Length {
-							style = styleSearch
-							applyStyle = true
-							break
-						}
+				for _, match := range lineMatches {
+					if match.contains(lineNum, i) {
+						style = styleSearch
+						applyStyle = true
+						break
 					}
 				}
 
-				if m.selecting {
-					isSelected := false
-					if lineNum > selStartRow && lineNum < selEndRow {
-						isSelected = true
-					} else if lineNum == selStartRow && lineNum == selEndRow {
-						if i >= selStartCol && i < selEndCol {
-							isSelected = true
-						}
-					} else if lineNum == selStartRow {
-						if i >= selStartCol {
-							isSelected = true
-						}
-					} else if lineNum == selEndRow {
-						if i < selEndCol {
-							isSelected = true
-						}
-					}
-					if isSelected {
+				for _, sel := range lineSelections {
+					if sel.contains(lineNum, i) {
 						style = styleSelected
 						applyStyle = true
+						break
 					}
 				}
 
-				if lineNum == cursorRow && i == cursorCol {
+				selecting, isCursor := cursorsAt[[2]int{lineNum, i}]
+				if isCursor {
 					style = styleCursor
 					applyStyle = true
 				}
@@ -141,7 +130,7 @@ func (m Model) viewEditor(cfg editorViewConfig) string {
 				}
 
 				if applyStyle {
-					if !m.selecting && lineNum == cursorRow && i == cursorCol {
+					if isCursor && !selecting {
 						if ch == '\t' {
 							s.WriteString(styleCursor.Render(" ") + strings.Repeat(" ", m.Config.TabWidth-1))
 						} else {
@@ -155,7 +144,7 @@ func (m Model) viewEditor(cfg editorViewConfig) string {
 				}
 			}
 
-			if !m.selecting && lineNum == cursorRow && cursorCol == len(runes) && endIdx == len(runes) {
+			if selecting, ok := cursorsAt[[2]int{lineNum, len(runes)}]; ok && !selecting && endIdx == len(runes) {
 				s.WriteString(styleCursor.Render(" "))
 			}
 
@@ -164,36 +153,44 @@ func (m Model) viewEditor(cfg editorViewConfig) string {
 			if visualLinesRendered < maxVisualLines {
 				s.WriteString("\n")
 			}
-			currentVisualLineIndex++
 		}
 
-		if len(lineRunes) == 0 {
-			renderChunk(nil, 0, 0, true, 0)
-			continue
-		}
-
-		chunkStart := 0
-		currentVisualWidth := 0
-		isFirst := true
-		for i := 0; i < len(lineRunes); i++ {
-			charWidth := 1
-			if lineRunes[i] == '\t' {
-				charWidth = m.Config.TabWidth
+		starts := m.wrapLine(lineRunes, textWidth)
+		for i, start := range starts {
+			if lineNum == top.row && i < top.wrap {
+				continue
 			}
-
-			if currentVisualWidth+charWidth > textWidth {
-				renderChunk(lineRunes, chunkStart, i, isFirst, currentVisualWidth)
-				chunkStart = i
-				currentVisualWidth = charWidth
-				isFirst = false
-			} else {
-				currentVisualWidth += charWidth
+			end := len(lineRunes)
+			if i+1 < len(starts) {
+				end = starts[i+1]
 			}
-		}
-		if chunkStart <= len(lineRunes) {
-			renderChunk(lineRunes, chunkStart, len(lineRunes), isFirst, currentVisualWidth)
+			renderChunk(lineRunes, start, end, i == 0)
 		}
 	}
 
 	return s.String()
 }
+
+// selection is a selected range with its start before its end.
+type selection struct {
+	startRow, startCol int
+	endRow, endCol     int
+}
+
+func newSelection(c CursorState) selection {
+	s := selection{c.StartRow, c.StartCol, c.Row, c.Col}
+	if s.startRow > s.endRow || (s.startRow == s.endRow && s.startCol > s.endCol) {
+		s = selection{c.Row, c.Col, c.StartRow, c.StartCol}
+	}
+	return s
+}
+
+func (s selection) contains(row, col int) bool {
+	if row < s.startRow || row > s.endRow {
+		return false
+	}
+	if row == s.startRow && col < s.startCol {
+		return false
+	}
+	return row != s.endRow || col < s.endCol
+}
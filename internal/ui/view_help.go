@@ -1,11 +1,95 @@
 package ui
 
 import (
+	"fmt"
 	"strings"
 
+	"larry/internal/keymap"
+
 	"github.com/charmbracelet/lipgloss"
 )
 
+type helpEntry struct {
+	Key  string
+	Desc string
+}
+
+type helpGroup struct {
+	name    string
+	entries []helpEntry
+}
+
+// helpGroups returns the help menu from the keys in effect. Actions with the
+// same label share a line, showing the first key of each; unbound actions
+// are left out.
+func (m Model) helpGroups() []helpGroup {
+	var groups []helpGroup
+	actions := keymap.Actions
+	for i := 0; i < len(actions); {
+		a := actions[i]
+		j := i + 1
+		for j < len(actions) && actions[j].Help == a.Help && actions[j].Group == a.Group {
+			j++
+		}
+		var keys []string
+		if j-i == 1 {
+			keys = m.KeyMap.keys(a.Name)
+		} else {
+			for _, b := range actions[i:j] {
+				if k := m.KeyMap.keys(b.Name); len(k) > 0 {
+					keys = append(keys, k[0])
+				}
+			}
+		}
+		i = j
+
+		if len(groups) == 0 || groups[len(groups)-1].name != a.Group {
+			groups = append(groups, helpGroup{name: a.Group})
+		}
+		g := &groups[len(groups)-1]
+		if len(keys) > 0 {
+			g.entries = append(g.entries, helpEntry{keymap.Labels(keys), a.Help})
+		}
+		if a.Name == "select_all_matches" {
+			// Esc is not an action, it leaves any mode.
+			g.entries = append(g.entries, helpEntry{"Esc", "Single Cursor"})
+		}
+	}
+	return groups
+}
+
+// statusHints returns the status bar hints for the most used actions, with
+// the keys they are bound to.
+func (m Model) statusHints(markdown bool) string {
+	hints := []helpEntry{
+		{"open", "Open File"},
+		{"toggle_help", "Help"},
+		{"quit", "Quit"},
+		{"save", "Save"},
+		{"search", "Search File"},
+		{"global_finder", "Larry Finder"},
+	}
+	var parts []string
+	switch {
+	case len(m.searchResults) > 0:
+		parts = append(parts, fmt.Sprintf("Search: %s (%d/%d)", m.searchQuery, m.currentResultIndex+1, len(m.searchResults)))
+		hints = hints[1:]
+	case markdown:
+		preview := helpEntry{"markdown_preview", "Preview"}
+		if m.viewMode == ViewModeSplit {
+			preview.Desc = "Close Preview"
+		}
+		hints = append([]helpEntry{preview}, hints[1:4]...)
+		hints = append(hints, helpEntry{"global_finder", "Larry Finder"})
+	}
+	for _, h := range hints {
+		if keys := m.KeyMap.keys(h.Key); len(keys) > 0 {
+			parts = append(parts, keymap.Label(keys[0])+": "+h.Desc)
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
 func (m Model) viewHelpMenu(base string) string {
 	width := m.Width
 	height := m.Height
@@ -17,94 +101,16 @@ func (m Model) viewHelpMenu(base string) string {
 		height = 24
 	}
 
-	leader := strings.Title(m.Config.LeaderKey)
-	if leader == "" {
-		leader = "Leader"
-	}
-
-	generalShortcuts := []struct {
-		Key  string
-		Desc string
-	}{
-		{leader + "+q", "Quit"},
-		{leader + "+s", "Save"},
-		{leader + "+o", "Open File"},
-		{leader + "+g", "Go to Line"},
-		{leader + "+f", "Search"},
-		{leader + "+t", "Replace"},
-		{leader + "+p", "Global Finder"},
-		{leader + "+h", "Toggle Help"},
-		{leader + "+z", "Undo"},
-		{leader + "+r", "Redo"},
-		{leader + "+c", "Copy"},
-		{leader + "+v", "Paste"},
-		{leader + "+x", "Cut"},
-		{leader + "+a", "Select All"},
-		{leader + "+u", "Markdown Preview"},
-	}
-
-	navShortcuts := []struct {
-		Key  string
-		Desc string
-	}{
-		{"←/→/↑/↓", "Move Cursor"},
-		{"Shift+Arrow", "Select Text"},
-		{leader + "+←/→", "Jump Word"},
-		{leader + "+↑/↓", "Jump 5 Lines"},
-		{leader + "+Shift+←/→", "Select Word"},
-		{leader + "+Shift+↑/↓", "Select Lines"},
-		{"Home", "Line Start"},
-		{"End", "Line End"},
-		{"Shift+Home/End", "Select to Start/End"},
-		{leader + "+Home", "File Start"},
-		{leader + "+End", "File End"},
-	}
-
 	bg := helpStyle.GetBackground()
 	spacerStyle := lipgloss.NewStyle().Background(bg)
 
-	colWidth := (width - 20) / 2
-	if colWidth < 25 {
-		colWidth = 25
-	}
-
-	var leftColLines []string
-	leftColLines = append(leftColLines, categoryStyle.Render("General"))
-	for _, s := range generalShortcuts {
-		keyStr := keyStyle.Width(14).Render(s.Key)
-		descStr := descStyle.Width(colWidth - 14).Render(s.Desc)
-		leftColLines = append(leftColLines, lipgloss.JoinHorizontal(lipgloss.Top, keyStr, descStr))
+	// Use three columns if they fit, two otherwise.
+	groups := m.helpGroups()
+	columns := helpColumns(groups, 3, spacerStyle)
+	if lipgloss.Width(columns)+8 > width {
+		columns = helpColumns(groups, 2, spacerStyle)
 	}
 
-	var rightColLines []string
-	rightColLines = append(rightColLines, categoryStyle.Render("Navigation"))
-	for _, s := range navShortcuts {
-		keyStr := keyStyle.Width(18).Render(s.Key)
-		descStr := descStyle.Width(colWidth - 18).Render(s.Desc)
-		rightColLines = append(rightColLines, lipgloss.JoinHorizontal(lipgloss.Top, keyStr, descStr))
-	}
-
-	maxLines := len(leftColLines)
-	if len(rightColLines) > maxLines {
-		maxLines = len(rightColLines)
-	}
-
-	for len(leftColLines) < maxLines {
-		leftColLines = append(leftColLines, spacerStyle.Render(""))
-	}
-	for len(rightColLines) < maxLines {
-		rightColLines = append(rightColLines, spacerStyle.Render(""))
-	}
-
-	leftColStyled := lipgloss.NewStyle().
-		PaddingRight(3).
-		Background(bg).
-		Render(strings.Join(leftColLines, "\n"))
-	rightColStyled := lipgloss.NewStyle().
-		Background(bg).
-		Render(strings.Join(rightColLines, "\n"))
-	columns := lipgloss.JoinHorizontal(lipgloss.Top, leftColStyled, rightColStyled)
-
 	contentWidth := lipgloss.Width(columns)
 
 	title := "Larry - Help Menu"
@@ -113,7 +119,10 @@ func (m Model) viewHelpMenu(base string) string {
 	titleRight := titleGap - titleLeft
 	paddedTitle := helpTitleStyle.Render(strings.Repeat(" ", titleLeft) + title + strings.Repeat(" ", titleRight))
 
-	footer := "Press Esc or " + leader + "+h to close"
+	footer := "Press Esc to close"
+	if keys := m.KeyMap.keys("toggle_help"); len(keys) > 0 {
+		footer = "Press Esc or " + keymap.Label(keys[0]) + " to close"
+	}
 	footerGap := contentWidth - lipgloss.Width(footer)
 	footerLeft := footerGap / 2
 	footerRight := footerGap - footerLeft
@@ -149,3 +158,59 @@ func (m Model) viewHelpMenu(base string) string {
 
 	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, helpMenu)
 }
+
+// helpColumns renders the groups of the help menu in n columns of about the
+// same height, keeping their order.
+func helpColumns(groups []helpGroup, n int, spacerStyle lipgloss.Style) string {
+	total := 0
+	for _, g := range groups {
+		total += len(g.entries) + 2
+	}
+	cols := make([][]helpGroup, n)
+	filled := 0
+	for _, g := range groups {
+		size := len(g.entries) + 2
+		c := min((filled+size/2)*n/max(total, 1), n-1)
+		cols[c] = append(cols[c], g)
+		filled += size
+	}
+
+	rendered := make([][]string, n)
+	height := 0
+	for c, groups := range cols {
+		keyWidth, descWidth := 0, 0
+		for _, g := range groups {
+			for _, e := range g.entries {
+				keyWidth = max(keyWidth, lipgloss.Width(e.Key)+2)
+				descWidth = max(descWidth, lipgloss.Width(e.Desc))
+			}
+		}
+		var colLines []string
+		for i, g := range groups {
+			if i > 0 {
+				colLines = append(colLines, spacerStyle.Render(""))
+			}
+			colLines = append(colLines, categoryStyle.Render(g.name))
+			for _, e := range g.entries {
+				keyStr := keyStyle.Width(keyWidth).Render(e.Key)
+				descStr := descStyle.Width(descWidth).Render(e.Desc)
+				colLines = append(colLines, lipgloss.JoinHorizontal(lipgloss.Top, keyStr, descStr))
+			}
+		}
+		rendered[c] = colLines
+		height = max(height, len(colLines))
+	}
+
+	var styled []string
+	for c, colLines := range rendered {
+		for len(colLines) < height {
+			colLines = append(colLines, spacerStyle.Render(""))
+		}
+		style := lipgloss.NewStyle().Background(spacerStyle.GetBackground())
+		if c < n-1 {
+			style = style.PaddingRight(3)
+		}
+		styled = append(styled, style.Render(strings.Join(colLines, "\n")))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, styled...)
+}
@@ -0,0 +1,167 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// historyViewer is the undo history panel: every state of the document,
+// newest first, next to a preview of the selected one.
+type historyViewer struct {
+	selected int // state, i.e. node of the undo tree
+}
+
+func (m Model) updateHistoryView(msg tea.KeyMsg) (Model, tea.Cmd) {
+	h := *m.historyView
+	last := m.history.Len() - 1
+	page := max(m.diffViewHeight()-1, 1)
+	switch msg.String() {
+	case "esc", "q":
+		m.historyView = nil
+		return m, nil
+	case "enter":
+		m.historyView = nil
+		sel := min(h.selected, last)
+		if sel == m.history.Current {
+			return m, nil
+		}
+		m = m.travel(sel)
+		m.statusMsg = fmt.Sprintf("State %d of %d", m.history.Current, last)
+		return m.updateViewport(), nil
+	case "up", "k":
+		h.selected++
+	case "down", "j":
+		h.selected--
+	case "pgup":
+		h.selected += page
+	case "pgdown", " ":
+		h.selected -= page
+	case "home":
+		h.selected = last
+	case "end":
+		h.selected = 0
+	}
+	h.selected = max(min(h.selected, last), 0)
+	m.historyView = &h
+	return m, nil
+}
+
+func (m Model) viewHistory() string {
+	w := max(m.Width-8, 40)
+	h := m.diffViewHeight()
+	last := m.history.Len() - 1
+	sel := min(m.historyView.selected, last)
+
+	bg := modalStyle.GetBackground()
+	spacerStyle := lipgloss.NewStyle().Background(bg)
+	currentStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Background(bg)
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Background(lipgloss.Color("237"))
+
+	title := modalTitleStyle.Width(w).Render("Undo History (↑/↓ select, Enter jump, Esc close)")
+
+	listW := min(max(w/3, 24), w-10)
+	previewW := w - listW - 1
+
+	// Keep the selection visible, the newest state is at the top.
+	top := max(last-sel-h+1, 0)
+	var list []string
+	for row := top; row < top+h && row <= last; row++ {
+		n := last - row
+		line := m.historySummary(n)
+		marker := "  "
+		if n == m.history.Current {
+			marker = "● "
+		}
+		line = marker + line
+		if runes := []rune(line); len(runes) > listW {
+			line = string(runes[:listW-1]) + "…"
+		}
+		style := spacerStyle
+		switch {
+		case n == sel:
+			style = selectedStyle
+		case n == m.history.Current:
+			style = currentStyle
+		}
+		list = append(list, style.Width(listW).Render(line))
+	}
+	for len(list) < h {
+		list = append(list, spacerStyle.Width(listW).Render(""))
+	}
+
+	preview := m.previewState(sel, previewW, h)
+	body := lipgloss.JoinHorizontal(lipgloss.Top,
+		strings.Join(list, "\n"),
+		spacerStyle.Render(" "),
+		preview,
+	)
+
+	content := strings.Join([]string{title, spacerStyle.Width(w).Render(""), body}, "\n")
+	modal := modalStyle.Render(content)
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, modal)
+}
+
+// historySummary describes state n of the undo history in one line.
+func (m Model) historySummary(n int) string {
+	if n == 0 {
+		return "  0 original"
+	}
+	node := m.history.Nodes[n]
+	g := node.Value
+
+	var what string
+	if len(g.Ops) == 1 {
+		op := g.Ops[0]
+		sign := "+"
+		if op.Type == OpDelete {
+			sign = "-"
+		}
+		text := strings.ReplaceAll(op.Text, "\n", "⏎")
+		text = strings.ReplaceAll(text, "\t", "→")
+		what = fmt.Sprintf("L%d %s%q", op.Row+1, sign, text)
+	} else {
+		what = fmt.Sprintf("L%d %d edits", g.Ops[0].Row+1, len(g.Ops))
+	}
+	if node.Parent != n-1 {
+		what += fmt.Sprintf(" (from %d)", node.Parent)
+	}
+	return fmt.Sprintf("%3d %4s %s", n, age(node.Time), what)
+}
+
+// age formats how long ago t was, in its largest unit.
+func age(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// previewState renders the document as it is in state n in a w×h area,
+// without changing the buffer.
+func (m Model) previewState(n, w, h int) string {
+	pm := m
+	pm.Buffer = m.Buffer.Snapshot()
+	pm.history = cloneHistory(m.history)
+	pm = pm.travel(n)
+	pm.historyView = nil
+	pm.searchResults = nil
+	pm.searching = false
+	pm.viewMode = ViewModeEditor
+	pm.panes = []pane{{}}
+	pm.focus = 0
+	pm.paneLayout = &layout{}
+	pm.Width, pm.Height = w, h+1
+	pm = pm.updateViewport()
+	return pm.viewEditor(editorViewConfig{width: w, height: h})
+}
@@ -74,7 +74,7 @@ func (m *Model) renderMarkdownCached() string {
 		return ""
 	}
 
-	content := strings.Join(m.Lines, "\n")
+	content := m.Buffer.String()
 	rendered, err := m.markdownRenderer.Render(content)
 	if err != nil {
 		return "Error rendering markdown: " + err.Error()
@@ -102,7 +102,7 @@ func (m *Model) viewMarkdownPreview(width, height int) string {
 
 	lines := strings.Split(rendered, "\n")
 
-	totalSourceLines := len(m.Lines)
+	totalSourceLines := m.Buffer.LineCount()
 	totalRenderedLines := len(lines)
 
 	var cursorPositionRatio float64
@@ -9,15 +9,9 @@ import (
 var splitDividerStyle = lipgloss.NewStyle().
 	Foreground(lipgloss.Color("240"))
 
-func (m Model) viewSplit() string {
-	totalWidth := m.Width
-	totalHeight := m.Height - 1
-
-	// Reserve space for prompts that View() appends with "\n\n"
-	if m.saving || m.goToLine || m.searching {
-		totalHeight -= 2
-	}
-
+// viewSplit renders the editor next to the markdown preview in a
+// totalWidth×totalHeight area.
+func (m Model) viewSplit(totalWidth, totalHeight int) string {
 	editorWidth := totalWidth / 2
 	previewWidth := totalWidth - editorWidth - 1
 
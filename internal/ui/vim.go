@@ -0,0 +1,1015 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"larry/internal/search"
+	"larry/internal/vim"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// With "keymap": "vim" in the config, keys go through a modal layer first.
+// In insert mode they are handled as usual. In normal and visual mode they
+// are collected until they form a command, see vim.Parse, which is carried
+// out with the movement helpers and edit ops of the editor. A key that is no
+// vim command but bound to an action, e.g. ctrl+s, still does that action.
+
+type vimMode int
+
+const (
+	vimNormal vimMode = iota
+	vimInsert
+	vimVisual
+	vimVisualLine
+)
+
+func (v vimMode) String() string {
+	switch v {
+	case vimInsert:
+		return "INSERT"
+	case vimVisual:
+		return "VISUAL"
+	case vimVisualLine:
+		return "V-LINE"
+	}
+	return "NORMAL"
+}
+
+// vimState is the state of the vim keymap. Like the undo history it is
+// shared between Model values, so it is replaced rather than changed.
+type vimState struct {
+	mode                 vimMode
+	keys                 []tea.KeyMsg // command typed so far
+	anchorRow, anchorCol int          // where the visual selection started
+	register             string       // named by the command being run, "" for the unnamed one
+	find                 vim.Command  // last f, t, F or T, repeated by ; and ,
+	pattern              string       // last search, repeated by n and N
+	change               []tea.KeyMsg // keys of the change being typed
+	lastChange           []tea.KeyMsg // keys of the last change, repeated by .
+	changeStart          int          // undo state before the last command
+	replaying            bool         // . is repeating lastChange
+}
+
+// status returns the mode and the command typed so far for the status bar.
+func (v *vimState) status() string {
+	s := v.mode.String()
+	if len(v.keys) > 0 {
+		s += " " + strings.Join(vimKeyStrings(v.keys), "")
+	}
+	return s
+}
+
+func vimKeyStrings(keys []tea.KeyMsg) []string {
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = k.String()
+	}
+	return out
+}
+
+// vimPos is a position in the buffer, with the column in runes.
+type vimPos struct {
+	row, col int
+}
+
+func (p vimPos) before(q vimPos) bool {
+	return p.row < q.row || (p.row == q.row && p.col < q.col)
+}
+
+// vimRange is the text an operator works on: from start up to end, or the
+// whole lines from start.row to end.row.
+type vimRange struct {
+	start, end vimPos
+	lines      bool
+}
+
+type motionKind int
+
+const (
+	motionExclusive motionKind = iota // up to the target
+	motionInclusive                   // including the character at the target
+	motionLinewise                    // the whole lines up to the target
+)
+
+// vimKey handles a key with the vim keymap.
+func (m Model) vimKey(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case m.block != nil:
+		return m.actionKey(msg)
+	case m.vim.mode == vimInsert:
+		// Pastes too, so that . repeats them.
+		return m.vimInsertKey(msg)
+	case msg.Paste:
+		return m.actionKey(msg)
+	case msg.Type == tea.KeyRunes && len(msg.Runes) > 1:
+		// Keys typed faster than they are read arrive together.
+		for _, r := range msg.Runes {
+			m, _ = m.vimKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+		return m, nil
+	}
+
+	v := *m.vim
+	visual := v.mode != vimNormal
+	if msg.Type == tea.KeyEsc {
+		if len(v.keys) == 0 && !visual {
+			return m.actionKey(msg)
+		}
+		v.keys = nil
+		v.mode = vimNormal
+		m.vim = &v
+		m.selecting = false
+		return m.vimSettle(), nil
+	}
+
+	keys := append(slices.Clip(v.keys), msg)
+	c, state := vim.Parse(vimKeyStrings(keys), visual)
+	switch state {
+	case vim.Incomplete:
+		v.keys = keys
+		m.vim = &v
+		return m, nil
+	case vim.Invalid:
+		v.keys = nil
+		if len(keys) == 1 && m.KeyMap.bound(msg) {
+			v.mode = vimNormal
+			m.vim = &v
+			return m.actionKey(msg)
+		}
+		m.vim = &v
+		return m, nil
+	}
+
+	v.keys = nil
+	v.register = c.Register
+	v.changeStart = m.history.Current
+	if c.Motion == "f" || c.Motion == "t" || c.Motion == "F" || c.Motion == "T" {
+		v.find = c
+	}
+	m.vim = &v
+	if visual {
+		m = m.vimVisualCommand(c)
+	} else {
+		m.selecting = false
+		m = m.vimCommand(c)
+		if vimChanges(c) && !m.vim.replaying {
+			v = *m.vim
+			if v.mode == vimInsert {
+				v.change = keys
+			} else {
+				v.lastChange = keys
+			}
+			m.vim = &v
+		}
+	}
+	return m.vimSettle(), nil
+}
+
+// vimChanges reports whether c changes the buffer in normal mode, so that .
+// can repeat it.
+func vimChanges(c vim.Command) bool {
+	switch c.Operator {
+	case "d", "c", ">", "<":
+		return true
+	}
+	switch c.Action {
+	case "x", "X", "D", "C", "s", "S", "p", "P", "J", "~", "r", "delete",
+		"i", "a", "I", "A", "o", "O":
+		return true
+	}
+	return false
+}
+
+// vimInsertKey handles a key in insert mode. Esc goes back to normal mode
+// and makes everything done since entering insert mode one undo step.
+func (m Model) vimInsertKey(msg tea.KeyMsg) (Model, tea.Cmd) {
+	v := *m.vim
+	if v.change != nil {
+		v.change = append(slices.Clip(v.change), msg)
+	}
+	if msg.Type != tea.KeyEsc {
+		m.vim = &v
+		var cmd tea.Cmd
+		m, cmd = m.actionKey(msg)
+		if m.history.Current == v.changeStart {
+			// Keep the typing out of the undo step before the change.
+			m.setEditKind(editOther)
+		}
+		return m, cmd
+	}
+
+	v.mode = vimNormal
+	if v.change != nil && !v.replaying {
+		v.lastChange = v.change
+	}
+	v.change = nil
+	m.vim = &v
+	m.history.Squash(v.changeStart, mergeUndoGroups)
+	m.cursors = nil
+	m.selecting = false
+	if m.CursorCol > 0 {
+		m.CursorCol--
+	}
+	return m.vimSettle(), nil
+}
+
+// mergeUndoGroups joins two consecutive undo steps into one.
+func mergeUndoGroups(a, b UndoGroup) UndoGroup {
+	return UndoGroup{Ops: slices.Concat(a.Ops, b.Ops), Before: a.Before, After: b.After}
+}
+
+// vimSettle keeps the cursor on a character outside of insert mode and shows
+// the visual selection, which includes the characters at both of its ends.
+func (m Model) vimSettle() Model {
+	v := m.vim
+	if v.mode == vimInsert || (v.mode == vimNormal && m.selecting) {
+		return m
+	}
+	m.CursorRow = min(max(m.CursorRow, 0), m.Buffer.LineCount()-1)
+	m.CursorCol = min(max(m.CursorCol, 0), max(m.Buffer.LineLen(m.CursorRow)-1, 0))
+
+	anchorRow := min(v.anchorRow, m.Buffer.LineCount()-1)
+	switch v.mode {
+	case vimVisual:
+		m.selecting = true
+		m.startRow, m.startCol = anchorRow, v.anchorCol
+		if (vimPos{m.CursorRow, m.CursorCol}).before(vimPos{anchorRow, v.anchorCol}) {
+			m.startCol = min(v.anchorCol+1, m.Buffer.LineLen(anchorRow))
+		}
+	case vimVisualLine:
+		m.selecting = true
+		m.startRow, m.startCol = anchorRow, 0
+		if m.CursorRow < anchorRow {
+			m.startCol = m.Buffer.LineLen(anchorRow)
+		}
+	}
+	return m
+}
+
+func (m Model) vimSetMode(mode vimMode) Model {
+	v := *m.vim
+	if v.mode == vimNormal && (mode == vimVisual || mode == vimVisualLine) {
+		v.anchorRow, v.anchorCol = m.CursorRow, m.CursorCol
+	}
+	v.mode = mode
+	m.vim = &v
+	if mode == vimNormal || mode == vimInsert {
+		m.selecting = false
+	}
+	return m
+}
+
+// vimCommand carries out a command typed in normal mode.
+func (m Model) vimCommand(c vim.Command) Model {
+	switch {
+	case c.Operator != "":
+		r, ok := m.vimOperatorRange(c)
+		if !ok {
+			return m
+		}
+		return m.vimOperate(c.Operator, r)
+	case c.Action != "":
+		return m.vimAction(c)
+	}
+	if p, _, ok := m.vimMotion(c); ok {
+		m.CursorRow, m.CursorCol = p.row, p.col
+	}
+	return m
+}
+
+// vimMotion returns where motion c moves the cursor.
+func (m Model) vimMotion(c vim.Command) (vimPos, motionKind, bool) {
+	n := c.Times()
+	row, col := m.CursorRow, m.CursorCol
+	last := m.Buffer.LineCount() - 1
+	p := vimPos{row, col}
+
+	switch c.Motion {
+	case "h":
+		return vimPos{row, max(col-n, 0)}, motionExclusive, true
+	case "l":
+		return vimPos{row, min(col+n, m.Buffer.LineLen(row))}, motionExclusive, true
+	case "j", "k", "+", "-":
+		r := row + n
+		if c.Motion == "k" || c.Motion == "-" {
+			r = row - n
+		}
+		r = min(max(r, 0), last)
+		if c.Motion == "j" || c.Motion == "k" {
+			return vimPos{r, min(col, m.Buffer.LineLen(r))}, motionLinewise, true
+		}
+		return vimPos{r, FirstNonBlank(m.Buffer, r)}, motionLinewise, true
+	case "w", "W":
+		for range n {
+			p.row, p.col = NextWordStart(m.Buffer, p.row, p.col, c.Motion == "W")
+		}
+		return p, motionExclusive, true
+	case "b", "B":
+		for range n {
+			p.row, p.col = PrevWordStart(m.Buffer, p.row, p.col, c.Motion == "B")
+		}
+		return p, motionExclusive, true
+	case "e", "E":
+		for range n {
+			p.row, p.col = WordEnd(m.Buffer, p.row, p.col, c.Motion == "E")
+		}
+		return p, motionInclusive, true
+	case "0":
+		return vimPos{row, 0}, motionExclusive, true
+	case "^":
+		return vimPos{row, FirstNonBlank(m.Buffer, row)}, motionExclusive, true
+	case "$":
+		r := min(row+n-1, last)
+		return vimPos{r, max(m.Buffer.LineLen(r)-1, 0)}, motionInclusive, true
+	case "gg", "G":
+		r := 0
+		if c.Count > 0 {
+			r = min(c.Count-1, last)
+		} else if c.Motion == "G" {
+			r = last
+		}
+		return vimPos{r, FirstNonBlank(m.Buffer, r)}, motionLinewise, true
+	case "f", "t", "F", "T", ";", ",":
+		f := c
+		if c.Motion == ";" || c.Motion == "," {
+			f = m.vim.find
+			if f.Motion == "" {
+				return p, 0, false
+			}
+			if c.Motion == "," {
+				f.Motion = map[string]string{"f": "F", "F": "f", "t": "T", "T": "t"}[f.Motion]
+			}
+		}
+		line := []rune(m.Buffer.Line(row))
+		to, ok := vimFind(line, col, f.Motion, []rune(f.Char)[0], n, f != c)
+		kind := motionInclusive
+		if f.Motion == "F" || f.Motion == "T" {
+			kind = motionExclusive
+		}
+		return vimPos{row, to}, kind, ok
+	case "%":
+		to, ok := m.vimMatchBracket(row, col)
+		return to, motionInclusive, ok
+	}
+	return p, 0, false
+}
+
+// vimFind returns the column of the count-th ch after (f) or before (F) col
+// in line, or for t and T the column next to it. A repeated t or T does not
+// stop at the ch right next to the cursor.
+func vimFind(line []rune, col int, motion string, ch rune, count int, repeat bool) (int, bool) {
+	step := 1
+	if motion == "F" || motion == "T" {
+		step = -1
+	}
+	i := col
+	if repeat && (motion == "t" || motion == "T") {
+		i += step
+	}
+	for count > 0 {
+		i += step
+		if i < 0 || i >= len(line) {
+			return col, false
+		}
+		if line[i] == ch {
+			count--
+		}
+	}
+	switch motion {
+	case "t":
+		i--
+	case "T":
+		i++
+	}
+	return i, true
+}
+
+// vimPairs maps opening brackets to their closing ones.
+var vimPairs = map[rune]rune{'(': ')', '[': ']', '{': '}', '<': '>'}
+
+// vimMatchBracket finds the bracket matching the first one at or after col in
+// row, as vim's % motion does.
+func (m Model) vimMatchBracket(row, col int) (vimPos, bool) {
+	line := []rune(m.Buffer.Line(row))
+	for col < len(line) && !strings.ContainsRune("()[]{}", line[col]) {
+		col++
+	}
+	if col == len(line) {
+		return vimPos{}, false
+	}
+	p := newRunePos(m.Buffer, row, col)
+	if closer, ok := vimPairs[line[col]]; ok {
+		return vimScanPair(p, line[col], closer, true)
+	}
+	for open, closer := range vimPairs {
+		if closer == line[col] {
+			return vimScanPair(p, closer, open, false)
+		}
+	}
+	return vimPos{}, false
+}
+
+// vimScanPair walks from the bracket inc at p forward or backward to the
+// bracket dec that matches it.
+func vimScanPair(p runePos, inc, dec rune, forward bool) (vimPos, bool) {
+	depth := 0
+	for {
+		switch p.rune() {
+		case inc:
+			depth++
+		case dec:
+			depth--
+			if depth == 0 {
+				return vimPos{p.row, p.col}, true
+			}
+		}
+		var ok bool
+		if forward {
+			ok = p.next()
+		} else {
+			ok = p.prev()
+		}
+		if !ok {
+			return vimPos{}, false
+		}
+	}
+}
+
+// vimOperatorRange returns the text operator command c works on.
+func (m Model) vimOperatorRange(c vim.Command) (vimRange, bool) {
+	cur := vimPos{m.CursorRow, m.CursorCol}
+	last := m.Buffer.LineCount() - 1
+	switch {
+	case c.Motion == vim.Line:
+		return vimRange{cur, vimPos{min(cur.row+c.Times()-1, last), 0}, true}, true
+	case c.Object != "":
+		return m.vimObject(c.Object)
+	}
+
+	big := c.Motion == "W" || c.Motion == "E"
+	line := []rune(m.Buffer.Line(cur.row))
+	onWord := cur.col < len(line) && wordClass(line[cur.col], big) != 0
+	if c.Operator == "c" && (c.Motion == "w" || c.Motion == "W") && onWord {
+		// cw changes up to the end of the word, like ce, but not further
+		// if the cursor is on its last character.
+		to := cur
+		if cur.col+1 < len(line) && wordClass(line[cur.col+1], big) == wordClass(line[cur.col], big) {
+			to.row, to.col = WordEnd(m.Buffer, to.row, to.col, big)
+		}
+		for range c.Times() - 1 {
+			to.row, to.col = WordEnd(m.Buffer, to.row, to.col, big)
+		}
+		return vimRange{cur, vimPos{to.row, min(to.col+1, m.Buffer.LineLen(to.row))}, false}, true
+	}
+
+	to, kind, ok := m.vimMotion(c)
+	if !ok {
+		return vimRange{}, false
+	}
+	if (c.Motion == "w" || c.Motion == "W") && to.row > cur.row && to.col <= FirstNonBlank(m.Buffer, to.row) {
+		// dw on the last word of a line stops at the end of the line.
+		to = vimPos{to.row - 1, m.Buffer.LineLen(to.row - 1)}
+	}
+	start, end := cur, to
+	if end.before(start) {
+		start, end = end, start
+	}
+	switch kind {
+	case motionLinewise:
+		return vimRange{start, end, true}, true
+	case motionInclusive:
+		end.col = min(end.col+1, m.Buffer.LineLen(end.row))
+	}
+	return vimRange{start, end, false}, true
+}
+
+// vimObject returns the text object obj around the cursor, e.g. iw for the
+// word or a( for the brackets and what is between them.
+func (m Model) vimObject(obj string) (vimRange, bool) {
+	around := obj[0] == 'a'
+	kind := []rune(obj[1:])[0]
+	row, col := m.CursorRow, m.CursorCol
+	line := []rune(m.Buffer.Line(row))
+
+	switch kind {
+	case 'w', 'W':
+		if len(line) == 0 {
+			return vimRange{}, false
+		}
+		big := kind == 'W'
+		col = min(col, len(line)-1)
+		cls := wordClass(line[col], big)
+		s, e := col, col+1
+		for s > 0 && wordClass(line[s-1], big) == cls {
+			s--
+		}
+		for e < len(line) && wordClass(line[e], big) == cls {
+			e++
+		}
+		if around && cls != 0 {
+			// The blanks after the word, or before it if there are none.
+			t := e
+			for t < len(line) && wordClass(line[t], big) == 0 {
+				t++
+			}
+			if t > e {
+				e = t
+			} else {
+				for s > 0 && wordClass(line[s-1], big) == 0 {
+					s--
+				}
+			}
+		} else if around && e < len(line) {
+			// The blanks and the word after them.
+			next := wordClass(line[e], big)
+			for e < len(line) && wordClass(line[e], big) == next {
+				e++
+			}
+		}
+		return vimRange{vimPos{row, s}, vimPos{row, e}, false}, true
+
+	case '"', '\'', '`':
+		var quotes []int
+		for i, r := range line {
+			if r == kind && (i == 0 || line[i-1] != '\\') {
+				quotes = append(quotes, i)
+			}
+		}
+		// The pair around the cursor, or else the first one after it.
+		for i := 0; i+1 < len(quotes); i += 2 {
+			s, e := quotes[i], quotes[i+1]
+			if col > e {
+				continue
+			}
+			if around {
+				e++
+				for e < len(line) && (line[e] == ' ' || line[e] == '\t') {
+					e++
+				}
+			} else {
+				s++
+			}
+			return vimRange{vimPos{row, s}, vimPos{row, e}, false}, true
+		}
+		return vimRange{}, false
+	}
+
+	// Walk back to the unmatched opening bracket, then on to its match.
+	closer := vimPairs[kind]
+	p := newRunePos(m.Buffer, row, col)
+	for depth := 0; ; {
+		r := p.rune()
+		if r == kind {
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if r == closer && (p.row != row || p.col != col) {
+			depth++
+		}
+		if !p.prev() {
+			return vimRange{}, false
+		}
+	}
+	end, ok := vimScanPair(p, kind, closer, true)
+	if !ok {
+		return vimRange{}, false
+	}
+	start := vimPos{p.row, p.col}
+	if around {
+		end.col++
+	} else {
+		p.next()
+		start = vimPos{p.row, p.col}
+	}
+	return vimRange{start, end, false}, true
+}
+
+// vimOperate applies operator op to r. d and c put the text they delete
+// into the register, like y.
+func (m Model) vimOperate(op string, r vimRange) Model {
+	switch op {
+	case ">", "<":
+		return m.vimIndent(r.start.row, r.end.row, op == ">")
+	case "y":
+		m = m.vimYank(r)
+		m.CursorRow = r.start.row
+		if !r.lines {
+			m.CursorCol = r.start.col
+		}
+		return m
+	}
+	m = m.vimYank(r)
+	if r.lines && op == "c" {
+		// cc keeps the line to type on.
+		r = vimRange{vimPos{r.start.row, 0}, vimPos{r.end.row, m.Buffer.LineLen(r.end.row)}, false}
+	}
+	m = m.vimDelete(r)
+	if op == "c" {
+		m = m.vimSetMode(vimInsert)
+	}
+	return m
+}
+
+func (m Model) vimYank(r vimRange) Model {
+	var text string
+	if r.lines {
+		text = m.Buffer.Slice(r.start.row, 0, r.end.row, m.Buffer.LineLen(r.end.row)) + "\n"
+	} else {
+		text = m.Buffer.Slice(r.start.row, r.start.col, r.end.row, r.end.col)
+	}
+	return m.setRegister(m.vim.register, text, r.lines)
+}
+
+// vimDelete deletes r and records it for undo.
+func (m Model) vimDelete(r vimRange) Model {
+	start, end := r.start, r.end
+	if r.lines {
+		start.col = 0
+		switch {
+		case end.row < m.Buffer.LineCount()-1:
+			end = vimPos{end.row + 1, 0}
+		case start.row > 0:
+			start = vimPos{start.row - 1, m.Buffer.LineLen(start.row - 1)}
+			end.col = m.Buffer.LineLen(end.row)
+		default:
+			end.col = m.Buffer.LineLen(end.row)
+		}
+	}
+	if start != end {
+		m.markModified()
+		text := m.Buffer.Delete(start.row, start.col, end.row, end.col)
+		m.pushUndo(EditOp{Type: OpDelete, Row: start.row, Col: start.col, Text: text})
+	}
+	if r.lines {
+		m.CursorRow = min(r.start.row, m.Buffer.LineCount()-1)
+		m.CursorCol = FirstNonBlank(m.Buffer, m.CursorRow)
+	} else {
+		m.CursorRow, m.CursorCol = start.row, start.col
+	}
+	return m
+}
+
+// vimReplace replaces the text of row from column c0 to c1 by text.
+func (m Model) vimReplace(row, c0, c1 int, text string) Model {
+	m.markModified()
+	old := m.Buffer.Delete(row, c0, row, c1)
+	m.pushUndo(EditOp{Type: OpDelete, Row: row, Col: c0, Text: old})
+	m.pushUndo(EditOp{Type: OpInsert, Row: row, Col: c0, Text: text})
+	m.Buffer.Insert(row, c0, text)
+	return m
+}
+
+// vimIndent indents or dedents rows r0 to r1 like Tab and Shift+Tab on a
+// selection of them.
+func (m Model) vimIndent(r0, r1 int, right bool) Model {
+	m.selecting = true
+	m.startRow, m.startCol = r0, 0
+	m.CursorRow, m.CursorCol = r1, 0
+	msg := tea.KeyMsg{Type: tea.KeyShiftTab}
+	if right {
+		msg = tea.KeyMsg{Type: tea.KeyTab}
+	}
+	m = m.cursorKey(msg)
+	m.selecting = false
+	m.CursorRow, m.CursorCol = r0, FirstNonBlank(m.Buffer, r0)
+	return m
+}
+
+// vimAction carries out a command of normal mode that is no operator or
+// motion.
+func (m Model) vimAction(c vim.Command) Model {
+	n := c.Times()
+	row, col := m.CursorRow, m.CursorCol
+	lineLen := m.Buffer.LineLen(row)
+	lines := vimRange{vimPos{row, 0}, vimPos{min(row+n-1, m.Buffer.LineCount()-1), 0}, true}
+
+	switch c.Action {
+	case "x", "delete", "s":
+		op := "d"
+		if c.Action == "s" {
+			op = "c"
+		}
+		return m.vimOperate(op, vimRange{vimPos{row, col}, vimPos{row, min(col+n, lineLen)}, false})
+	case "X":
+		return m.vimOperate("d", vimRange{vimPos{row, max(col-n, 0)}, vimPos{row, col}, false})
+	case "D", "C":
+		op := "d"
+		if c.Action == "C" {
+			op = "c"
+		}
+		r := lines.end.row
+		return m.vimOperate(op, vimRange{vimPos{row, col}, vimPos{r, m.Buffer.LineLen(r)}, false})
+	case "S":
+		return m.vimOperate("c", lines)
+	case "Y":
+		return m.vimOperate("y", lines)
+	case "p", "P":
+		reg, err := m.getRegister(m.vim.register)
+		if err != nil {
+			m.statusMsg = "Paste Error: " + err.Error()
+			return m
+		}
+		return m.vimPut(reg, c.Action == "P", n)
+	case "u":
+		for range n {
+			m = m.undo()
+		}
+	case "ctrl+r":
+		for range n {
+			m = m.redo()
+		}
+	case "J":
+		return m.vimJoin(row, max(n, 2)-1)
+	case "~":
+		end := min(col+n, lineLen)
+		if col < end {
+			line := []rune(m.Buffer.Line(row))
+			m = m.vimReplace(row, col, end, toggleCase(string(line[col:end])))
+			m.CursorCol = end
+		}
+	case "r":
+		if col+n <= lineLen {
+			m = m.vimReplace(row, col, col+n, strings.Repeat(c.Char, n))
+			m.CursorCol = col + n - 1
+		}
+	case "i":
+		return m.vimSetMode(vimInsert)
+	case "a":
+		m.CursorCol = min(col+1, lineLen)
+		return m.vimSetMode(vimInsert)
+	case "I":
+		m.CursorCol = FirstNonBlank(m.Buffer, row)
+		return m.vimSetMode(vimInsert)
+	case "A":
+		m.CursorCol = lineLen
+		return m.vimSetMode(vimInsert)
+	case "o", "O":
+		at := vimPos{row, 0}
+		if c.Action == "o" {
+			at = vimPos{row, lineLen}
+		}
+		m.markModified()
+		m.pushUndo(EditOp{Type: OpInsert, Row: at.row, Col: at.col, Text: "\n"})
+		m.Buffer.Insert(at.row, at.col, "\n")
+		m.CursorRow, m.CursorCol = at.row, 0
+		if c.Action == "o" {
+			m.CursorRow++
+		}
+		return m.vimSetMode(vimInsert)
+	case "v":
+		return m.vimSetMode(vimVisual)
+	case "V":
+		return m.vimSetMode(vimVisualLine)
+	case ".":
+		return m.vimRepeat(n)
+	case "n", "N":
+		return m.vimSearchNext(c.Action == "n", n)
+	case "/":
+		return m.startSearch()
+	}
+	return m
+}
+
+func toggleCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// vimPut inserts reg n times after or before the cursor, or below or above
+// its line if it holds whole lines.
+func (m Model) vimPut(reg register, before bool, n int) Model {
+	if reg.text == "" {
+		m.statusMsg = "Nothing to paste"
+		return m
+	}
+	text, linewise := strings.Repeat(reg.text, n), reg.linewise
+	row, col := m.CursorRow, m.CursorCol
+	m.markModified()
+
+	if linewise {
+		at, target := vimPos{row, 0}, row
+		if !before {
+			target = row + 1
+			if row < m.Buffer.LineCount()-1 {
+				at = vimPos{row + 1, 0}
+			} else {
+				at = vimPos{row, m.Buffer.LineLen(row)}
+				text = "\n" + strings.TrimSuffix(text, "\n")
+			}
+		}
+		m.pushUndo(EditOp{Type: OpInsert, Row: at.row, Col: at.col, Text: text})
+		m.Buffer.Insert(at.row, at.col, text)
+		m.CursorRow, m.CursorCol = target, FirstNonBlank(m.Buffer, target)
+		return m
+	}
+
+	if !before && m.Buffer.LineLen(row) > 0 {
+		col++
+	}
+	m.pushUndo(EditOp{Type: OpInsert, Row: row, Col: col, Text: text})
+	m.CursorRow, m.CursorCol = m.Buffer.Insert(row, col, text)
+	m.CursorCol = max(m.CursorCol-1, 0)
+	return m
+}
+
+// vimJoin joins the next count lines to row, replacing the indent of each
+// by a single space.
+func (m Model) vimJoin(row, count int) Model {
+	for range count {
+		if row >= m.Buffer.LineCount()-1 {
+			break
+		}
+		line, next := m.Buffer.Line(row), m.Buffer.Line(row+1)
+		trimmed := strings.TrimLeft(next, " \t")
+		col := m.Buffer.LineLen(row)
+		m.markModified()
+		text := m.Buffer.Delete(row, col, row+1, len(next)-len(trimmed))
+		m.pushUndo(EditOp{Type: OpDelete, Row: row, Col: col, Text: text})
+		if line != "" && trimmed != "" && !strings.HasSuffix(line, " ") {
+			m.pushUndo(EditOp{Type: OpInsert, Row: row, Col: col, Text: " "})
+			m.Buffer.Insert(row, col, " ")
+		}
+		m.CursorRow, m.CursorCol = row, col
+	}
+	return m
+}
+
+// vimRepeat repeats the last change n times by replaying its keys.
+func (m Model) vimRepeat(n int) Model {
+	keys := m.vim.lastChange
+	if len(keys) == 0 {
+		return m
+	}
+	v := *m.vim
+	v.replaying = true
+	m.vim = &v
+	for range n {
+		for _, k := range keys {
+			m, _ = m.vimKey(k)
+		}
+	}
+	v = *m.vim
+	v.replaying = false
+	m.vim = &v
+	// The replayed typing is a step of its own.
+	m.setEditKind(editOther)
+	return m
+}
+
+// vimSearch ends the search typed after / and moves to the first match
+// after the cursor.
+func (m Model) vimSearch() Model {
+	v := *m.vim
+	v.pattern = m.textInput.Value()
+	m.vim = &v
+	m.searching = false
+	m.searchQuery = ""
+	m.searchResults = nil
+	m.currentResultIndex = -1
+	if v.pattern == "" {
+		return m
+	}
+	return m.vimSearchNext(true, 1).vimSettle()
+}
+
+// vimSearchNext moves to the n-th match of the last search after or before
+// the cursor, wrapping around the end of the buffer.
+func (m Model) vimSearchNext(forward bool, n int) Model {
+	pattern := m.vim.pattern
+	if pattern == "" {
+		m.statusMsg = "No previous search"
+		return m
+	}
+	matches, err := m.findMatches(pattern)
+	if err != nil {
+		m.statusMsg = "Invalid pattern: " + patternError(err)
+		return m
+	}
+	if len(matches) == 0 {
+		m.statusMsg = "Pattern not found: " + pattern
+		return m
+	}
+
+	// Match columns are in bytes.
+	row := m.CursorRow
+	col := m.Buffer.Offset(row, m.CursorCol) - m.Buffer.Offset(row, 0)
+	var i int
+	if forward {
+		i = slices.IndexFunc(matches, func(s search.SearchMatch) bool {
+			return s.Line > row || (s.Line == row && s.Col > col)
+		})
+		i = max(i, 0) + n - 1
+	} else {
+		i = slices.IndexFunc(matches, func(s search.SearchMatch) bool {
+			return s.Line > row || (s.Line == row && s.Col >= col)
+		})
+		if i < 0 {
+			i = len(matches)
+		}
+		i -= n
+	}
+	i = (i%len(matches) + len(matches)) % len(matches)
+	m.CursorRow, m.CursorCol = m.matchStart(matches[i])
+	return m
+}
+
+// vimVisualCommand carries out a command typed in visual mode.
+func (m Model) vimVisualCommand(c vim.Command) Model {
+	v := *m.vim
+	anchor := vimPos{min(v.anchorRow, m.Buffer.LineCount()-1), v.anchorCol}
+	start, end := anchor, vimPos{m.CursorRow, m.CursorCol}
+	if end.before(start) {
+		start, end = end, start
+	}
+	r := vimRange{start, end, v.mode == vimVisualLine}
+	if !r.lines {
+		// Include the character at the end, or the line break of an empty line.
+		p := newRunePos(m.Buffer, end.row, end.col)
+		p.next()
+		r.end = vimPos{p.row, p.col}
+	}
+	lines := vimRange{vimPos{start.row, 0}, vimPos{end.row, 0}, true}
+
+	switch {
+	case c.Object != "":
+		obj, ok := m.vimObject(c.Object)
+		if !ok || obj.start == obj.end {
+			return m
+		}
+		p := newRunePos(m.Buffer, obj.end.row, obj.end.col)
+		p.prev()
+		v.mode = vimVisual
+		v.anchorRow, v.anchorCol = obj.start.row, obj.start.col
+		m.vim = &v
+		m.CursorRow, m.CursorCol = p.row, p.col
+		return m
+	case c.Operator != "":
+		return m.vimSetMode(vimNormal).vimOperate(c.Operator, r)
+	case c.Action == "":
+		if p, _, ok := m.vimMotion(c); ok {
+			m.CursorRow, m.CursorCol = p.row, p.col
+		}
+		return m
+	}
+
+	switch c.Action {
+	case "x", "delete":
+		return m.vimSetMode(vimNormal).vimOperate("d", r)
+	case "X", "D":
+		return m.vimSetMode(vimNormal).vimOperate("d", lines)
+	case "s":
+		return m.vimSetMode(vimNormal).vimOperate("c", r)
+	case "S":
+		return m.vimSetMode(vimNormal).vimOperate("c", lines)
+	case "Y":
+		return m.vimSetMode(vimNormal).vimOperate("y", lines)
+	case "p", "P":
+		// Replace the selection, keeping the register.
+		reg, err := m.getRegister(m.vim.register)
+		if err != nil {
+			m.statusMsg = "Paste Error: " + err.Error()
+			return m.vimSetMode(vimNormal)
+		}
+		m = m.vimSetMode(vimNormal).vimDelete(r)
+		return m.vimPut(reg, !r.lines || r.start.row < m.Buffer.LineCount(), 1)
+	case "J":
+		m = m.vimSetMode(vimNormal)
+		return m.vimJoin(start.row, max(end.row-start.row, 1))
+	case "~":
+		m = m.vimSetMode(vimNormal)
+		if r.lines {
+			r = vimRange{vimPos{start.row, 0}, vimPos{end.row, m.Buffer.LineLen(end.row)}, false}
+		}
+		text := m.Buffer.Slice(r.start.row, r.start.col, r.end.row, r.end.col)
+		m.markModified()
+		m.Buffer.Delete(r.start.row, r.start.col, r.end.row, r.end.col)
+		m.pushUndo(EditOp{Type: OpDelete, Row: r.start.row, Col: r.start.col, Text: text})
+		m.pushUndo(EditOp{Type: OpInsert, Row: r.start.row, Col: r.start.col, Text: toggleCase(text)})
+		m.Buffer.Insert(r.start.row, r.start.col, toggleCase(text))
+		m.CursorRow, m.CursorCol = start.row, start.col
+		return m
+	case "o":
+		v.anchorRow, v.anchorCol = m.CursorRow, m.CursorCol
+		m.vim = &v
+		m.CursorRow, m.CursorCol = anchor.row, anchor.col
+		return m
+	case "v", "V":
+		mode := vimVisual
+		if c.Action == "V" {
+			mode = vimVisualLine
+		}
+		if v.mode == mode {
+			mode = vimNormal
+		}
+		return m.vimSetMode(mode)
+	}
+	return m
+}
@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"larry/internal/buffer"
+	"larry/internal/diff"
+	"larry/internal/undo"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// watchInterval is how often the open file is checked for changes made by
+// other programs.
+const watchInterval = time.Second
+
+// fileCheckedMsg reports the state of path on disk compared to base, the
+// stamp the buffer had when the check was scheduled.
+type fileCheckedMsg struct {
+	path    string
+	base    buffer.Stamp
+	stamp   buffer.Stamp
+	changed bool
+	err     error
+}
+
+func watchCmd(path string, base buffer.Stamp) tea.Cmd {
+	return tea.Tick(watchInterval, func(time.Time) tea.Msg {
+		if path == "" {
+			return fileCheckedMsg{base: base}
+		}
+		stamp, changed, err := base.Changed(path)
+		return fileCheckedMsg{path: path, base: base, stamp: stamp, changed: changed, err: err}
+	})
+}
+
+// fileChecked reacts to the result of a watch tick: a clean buffer is
+// reloaded, a modified one asks the user what to do.
+func (m Model) fileChecked(msg fileCheckedMsg) (Model, tea.Cmd) {
+	// The buffer was switched, saved or reloaded since the check was scheduled.
+	if msg.path != m.FileName || !msg.base.Equal(m.Buffer.Stamp) || m.prompt != nil {
+		return m, watchCmd(m.FileName, m.Buffer.Stamp)
+	}
+
+	switch {
+	case errors.Is(msg.err, fs.ErrNotExist):
+		if !m.Buffer.Stamp.IsZero() {
+			m.Buffer.Stamp = buffer.Stamp{}
+			m.statusMsg = filepath.Base(m.FileName) + " was deleted on disk"
+		}
+	case msg.err != nil || !msg.changed:
+		m.Buffer.Stamp = msg.stamp
+	case !m.Modified:
+		var cmd tea.Cmd
+		m, cmd = m.reload(m.Buffer.Format.Encoding)
+		m.statusMsg = "Reloaded " + filepath.Base(m.FileName) + ", it changed on disk"
+		return m, tea.Batch(cmd, watchCmd(m.FileName, m.Buffer.Stamp))
+	default:
+		m = m.askExternalChange(msg.stamp)
+	}
+	return m, watchCmd(m.FileName, m.Buffer.Stamp)
+}
+
+// askExternalChange asks whether to reload a modified buffer whose file was
+// changed by another program.
+func (m Model) askExternalChange(stamp buffer.Stamp) Model {
+	p := &choicePrompt{question: filepath.Base(m.FileName) + " changed on disk"}
+	p.choices = []promptChoice{
+		{key: "r", label: "Reload", action: func(m Model) (Model, tea.Cmd) {
+			m, cmd := m.reload(m.Buffer.Format.Encoding)
+			m.statusMsg = "Reloaded " + filepath.Base(m.FileName)
+			return m, cmd
+		}},
+		{key: "d", label: "Diff", action: func(m Model) (Model, tea.Cmd) {
+			m = m.showDiskDiff()
+			m.prompt = p
+			return m, nil
+		}},
+		{key: "esc", label: "Keep mine", action: func(m Model) (Model, tea.Cmd) {
+			// Saving will overwrite the other version, don't ask again.
+			m.Buffer.Stamp = stamp
+			return m, nil
+		}},
+	}
+	m.prompt = p
+	return m
+}
+
+// confirmOverwrite asks before saving over a file that changed on disk since
+// it was loaded.
+func (m Model) confirmOverwrite(filename string) Model {
+	p := &choicePrompt{question: filepath.Base(filename) + " changed on disk since it was loaded"}
+	p.choices = []promptChoice{
+		{key: "o", label: "Overwrite", action: func(m Model) (Model, tea.Cmd) {
+			return m.save(filename, true), nil
+		}},
+		{key: "d", label: "Diff", action: func(m Model) (Model, tea.Cmd) {
+			m = m.showDiskDiff()
+			m.prompt = p
+			return m, nil
+		}},
+		{key: "esc", label: "Cancel"},
+	}
+	m.prompt = p
+	return m
+}
+
+// showDiskDiff opens the diff viewer with the changes between the buffer and
+// the file on disk.
+func (m Model) showDiskDiff() Model {
+	disk, err := buffer.LoadWithEncoding(m.FileName, m.Buffer.Format.Encoding)
+	if err != nil {
+		m.statusMsg = "Error reading: " + err.Error()
+		return m
+	}
+	lines := diff.Unified(m.Buffer.Lines(), disk.Lines(), 3)
+	m.diffView = newDiffViewer("Buffer → file on disk", lines)
+	return m
+}
+
+// reload replaces the buffer with the file on disk decoded with enc, keeping
+// the cursor where it was as far as possible.
+func (m Model) reload(enc buffer.Encoding) (Model, tea.Cmd) {
+	buf, err := buffer.LoadWithEncoding(m.FileName, enc)
+	if err != nil {
+		m.statusMsg = "Error opening: " + err.Error()
+		return m, nil
+	}
+	m.discardSwap()
+	m.Buffer = buf
+	m.selecting = false
+	m.cursors = nil
+	m.block = nil
+	m.Modified = false
+	m.markdownCacheValid = false
+	m.history = undo.Tree[UndoGroup]{}
+	m.CursorRow = min(m.CursorRow, buf.LineCount()-1)
+	m.CursorCol = min(m.CursorCol, buf.LineLen(m.CursorRow))
+	return m.updateViewport(), WaitForLoadCmd(buf)
+}
@@ -0,0 +1,95 @@
+package undo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"larry/internal/buffer"
+)
+
+// ErrStale is returned by Load if the file was changed since its history was
+// stored, so the history no longer applies to it.
+var ErrStale = errors.New("undo history is for another version of the file")
+
+// file is the content of an undo file.
+type file[T any] struct {
+	Path  string    `json:"path"` // absolute path of the edited file
+	Hash  string    `json:"hash"` // sha256 of the content the history ends in
+	Saved time.Time `json:"saved"`
+	Tree  Tree[T]   `json:"tree"`
+}
+
+// Dir returns the directory holding undo files.
+func Dir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "larry", "undo"), nil
+}
+
+// PathFor returns the undo file used for the file at path.
+func PathFor(path string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, filepath.Base(abs)+"-"+hex.EncodeToString(sum[:8])+".undo"), nil
+}
+
+// Save stores t as the history of the file at path, whose content has the
+// sha256 hash. The current state of t must be that content.
+func Save[T any](path string, hash [sha256.Size]byte, t Tree[T]) error {
+	undoPath, err := PathFor(path)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(undoPath), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(file[T]{Path: abs, Hash: hex.EncodeToString(hash[:]), Saved: time.Now(), Tree: t})
+	if err != nil {
+		return err
+	}
+	return buffer.WriteFile(undoPath, data, buffer.SaveOptions{})
+}
+
+// Load returns the stored history of the file at path if its content still
+// has the sha256 hash it had when the history was saved. It returns an error
+// satisfying errors.Is(err, os.ErrNotExist) if there is none, and ErrStale if
+// the file changed since.
+func Load[T any](path string, hash [sha256.Size]byte) (Tree[T], error) {
+	undoPath, err := PathFor(path)
+	if err != nil {
+		return Tree[T]{}, err
+	}
+	data, err := os.ReadFile(undoPath)
+	if err != nil {
+		return Tree[T]{}, err
+	}
+	var f file[T]
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Tree[T]{}, err
+	}
+	if f.Hash != hex.EncodeToString(hash[:]) {
+		return Tree[T]{}, ErrStale
+	}
+	if !f.Tree.Valid() {
+		return Tree[T]{}, errors.New("corrupt undo history")
+	}
+	return f.Tree, nil
+}
@@ -0,0 +1,59 @@
+package undo
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	hash := sha256.Sum256([]byte("hello"))
+
+	if _, err := Load[string](path, hash); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no undo file, got %v", err)
+	}
+
+	var tr Tree[string]
+	tr.Push("a", time.Now())
+	tr.Push("b", time.Now())
+	tr.Undo()
+	tr.Push("c", time.Now())
+	if err := Save(path, hash, tr); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	got, err := Load[string](path, hash)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got.Len() != 4 || got.Current != 3 || got.Nodes[2].Value != "b" {
+		t.Errorf("unexpected history %+v", got)
+	}
+
+	if _, err := Load[string](path, sha256.Sum256([]byte("changed"))); !errors.Is(err, ErrStale) {
+		t.Errorf("expected ErrStale for changed content, got %v", err)
+	}
+}
+
+func TestLoadRejectsCorruptTree(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	hash := sha256.Sum256(nil)
+
+	var tr Tree[string]
+	tr.Push("a", time.Now())
+	tr.Current = 5
+	if err := Save(path, hash, tr); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := Load[string](path, hash); err == nil {
+		t.Error("Load accepted a history with an out of range state")
+	}
+}
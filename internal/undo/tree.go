@@ -0,0 +1,187 @@
+// Package undo stores an edit history as a tree, so that undoing and then
+// making a new change starts a branch instead of throwing the undone changes
+// away. Every node is a state of the document; node 0 is the state the
+// history started from and every other node is reached from its parent by
+// applying the node's Value. Histories can be stored in the user cache
+// directory and restored when the same file is opened again.
+package undo
+
+import "time"
+
+// Node is a state in the history.
+type Node[T any] struct {
+	Parent   int   // -1 for the root
+	Children []int // in creation order
+	Redo     int   // child that Redo moves to, the one most recently left or created
+	Time     time.Time
+	Value    T // change leading from the parent to this state
+}
+
+// Tree is an undo history. Node indexes grow in the order the states were
+// created, so they double as a chronological sequence number. The zero Tree
+// is an empty history.
+type Tree[T any] struct {
+	Nodes   []Node[T]
+	Current int
+}
+
+func (t *Tree[T]) init() {
+	if len(t.Nodes) == 0 {
+		t.Nodes = []Node[T]{{Parent: -1, Redo: -1}}
+		t.Current = 0
+	}
+}
+
+// Len returns the number of states in the history, including the root.
+func (t *Tree[T]) Len() int {
+	t.init()
+	return len(t.Nodes)
+}
+
+// Push records a change made in the current state and moves to the new state.
+func (t *Tree[T]) Push(v T, now time.Time) {
+	t.init()
+	n := len(t.Nodes)
+	t.Nodes = append(t.Nodes, Node[T]{Parent: t.Current, Redo: -1, Time: now, Value: v})
+	parent := &t.Nodes[t.Current]
+	parent.Children = append(parent.Children, n)
+	parent.Redo = n
+	t.Current = n
+}
+
+// Last returns the change that led to the current state if nothing was undone
+// since, so it can still be extended. It returns nil otherwise.
+func (t *Tree[T]) Last() *T {
+	t.init()
+	cur := &t.Nodes[t.Current]
+	if t.Current == 0 || len(cur.Children) > 0 {
+		return nil
+	}
+	return &cur.Value
+}
+
+// Squash merges the changes made since state n into one, so that a single
+// Undo goes back to n. It only does so if they are the newest states and
+// follow each other without branches; merge combines two changes in order.
+func (t *Tree[T]) Squash(n int, merge func(a, b T) T) bool {
+	t.init()
+	last := len(t.Nodes) - 1
+	if n < 0 || n >= t.Current || t.Current != last {
+		return false
+	}
+	for i := n + 1; i <= last; i++ {
+		if t.Nodes[i].Parent != i-1 || (i < last && len(t.Nodes[i].Children) != 1) {
+			return false
+		}
+	}
+	if len(t.Nodes[last].Children) > 0 {
+		return false
+	}
+
+	first := &t.Nodes[n+1]
+	for _, node := range t.Nodes[n+2:] {
+		first.Value = merge(first.Value, node.Value)
+		first.Time = node.Time
+	}
+	first.Children = nil
+	first.Redo = -1
+	t.Nodes = t.Nodes[:n+2]
+	t.Current = n + 1
+	return true
+}
+
+// Undo moves to the parent state and returns the change to revert.
+func (t *Tree[T]) Undo() (T, bool) {
+	t.init()
+	cur := t.Nodes[t.Current]
+	if cur.Parent < 0 {
+		var zero T
+		return zero, false
+	}
+	t.Nodes[cur.Parent].Redo = t.Current
+	t.Current = cur.Parent
+	return cur.Value, true
+}
+
+// Redo moves to the most recently visited child state and returns the change
+// to apply.
+func (t *Tree[T]) Redo() (T, bool) {
+	t.init()
+	return t.RedoTo(t.Nodes[t.Current].Redo)
+}
+
+// RedoTo moves to child, which must be a child of the current state, and
+// returns the change to apply.
+func (t *Tree[T]) RedoTo(child int) (T, bool) {
+	t.init()
+	if child <= 0 || child >= len(t.Nodes) || t.Nodes[child].Parent != t.Current {
+		var zero T
+		return zero, false
+	}
+	t.Nodes[t.Current].Redo = child
+	t.Current = child
+	return t.Nodes[child].Value, true
+}
+
+// Path returns how to get from the current state to target: the number of
+// Undo steps up to their common ancestor, followed by RedoTo each of down.
+func (t *Tree[T]) Path(target int) (up int, down []int) {
+	t.init()
+	if target < 0 || target >= len(t.Nodes) {
+		return 0, nil
+	}
+
+	ancestors := map[int]bool{}
+	for n := target; n >= 0; n = t.Nodes[n].Parent {
+		ancestors[n] = true
+	}
+	common := t.Current
+	for !ancestors[common] {
+		common = t.Nodes[common].Parent
+		up++
+	}
+	for n := target; n != common; n = t.Nodes[n].Parent {
+		down = append(down, n)
+	}
+	for i, j := 0, len(down)-1; i < j; i, j = i+1, j-1 {
+		down[i], down[j] = down[j], down[i]
+	}
+	return up, down
+}
+
+// Depth returns the number of changes between the root and node n.
+func (t *Tree[T]) Depth(n int) int {
+	d := 0
+	for ; n > 0; n = t.Nodes[n].Parent {
+		d++
+	}
+	return d
+}
+
+// Valid reports whether the links between the nodes of t are consistent, so
+// that a history read from a damaged file cannot make the editor index out of
+// range.
+func (t *Tree[T]) Valid() bool {
+	if len(t.Nodes) == 0 {
+		return t.Current == 0
+	}
+	if t.Current < 0 || t.Current >= len(t.Nodes) || t.Nodes[0].Parent != -1 {
+		return false
+	}
+	for i, n := range t.Nodes[1:] {
+		if n.Parent < 0 || n.Parent > i {
+			return false
+		}
+	}
+	for i, n := range t.Nodes {
+		for _, c := range n.Children {
+			if c <= 0 || c >= len(t.Nodes) || t.Nodes[c].Parent != i {
+				return false
+			}
+		}
+		if n.Redo != -1 && (n.Redo <= 0 || n.Redo >= len(t.Nodes) || t.Nodes[n.Redo].Parent != i) {
+			return false
+		}
+	}
+	return true
+}
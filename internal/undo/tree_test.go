@@ -0,0 +1,170 @@
+package undo
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUndoRedo(t *testing.T) {
+	var tr Tree[string]
+	if _, ok := tr.Undo(); ok {
+		t.Fatal("Undo on an empty history succeeded")
+	}
+
+	tr.Push("a", time.Now())
+	tr.Push("b", time.Now())
+
+	if v, ok := tr.Undo(); !ok || v != "b" {
+		t.Fatalf("Undo = %q, %v, want b", v, ok)
+	}
+	if v, ok := tr.Redo(); !ok || v != "b" {
+		t.Fatalf("Redo = %q, %v, want b", v, ok)
+	}
+	if _, ok := tr.Redo(); ok {
+		t.Fatal("Redo past the newest state succeeded")
+	}
+}
+
+func TestBranchKeepsUndoneStates(t *testing.T) {
+	var tr Tree[string]
+	tr.Push("a", time.Now()) // 1
+	tr.Push("b", time.Now()) // 2
+	tr.Undo()
+	tr.Push("c", time.Now()) // 3, sibling of 2
+
+	if tr.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", tr.Len())
+	}
+	if got := tr.Nodes[1].Children; !slices.Equal(got, []int{2, 3}) {
+		t.Errorf("children of 1 = %v, want [2 3]", got)
+	}
+
+	// Redo follows the branch that was left last.
+	tr.Undo()
+	if v, _ := tr.Redo(); v != "c" {
+		t.Errorf("Redo = %q, want c", v)
+	}
+}
+
+func TestPath(t *testing.T) {
+	var tr Tree[string]
+	tr.Push("a", time.Now()) // 1
+	tr.Push("b", time.Now()) // 2
+	tr.Push("c", time.Now()) // 3
+	tr.Undo()
+	tr.Undo()
+	tr.Push("d", time.Now()) // 4, child of 1
+	tr.Push("e", time.Now()) // 5
+
+	up, down := tr.Path(3)
+	if up != 2 || !slices.Equal(down, []int{2, 3}) {
+		t.Fatalf("Path(3) = %d, %v, want 2, [2 3]", up, down)
+	}
+	for i := 0; i < up; i++ {
+		tr.Undo()
+	}
+	for _, n := range down {
+		if _, ok := tr.RedoTo(n); !ok {
+			t.Fatalf("RedoTo(%d) failed", n)
+		}
+	}
+	if tr.Current != 3 {
+		t.Errorf("Current = %d, want 3", tr.Current)
+	}
+
+	if up, down := tr.Path(0); up != 3 || len(down) != 0 {
+		t.Errorf("Path(0) = %d, %v, want 3, []", up, down)
+	}
+}
+
+func TestLast(t *testing.T) {
+	var tr Tree[string]
+	if tr.Last() != nil {
+		t.Error("Last on an empty history is not nil")
+	}
+	tr.Push("a", time.Now())
+	*tr.Last() += "b"
+	if tr.Nodes[1].Value != "ab" {
+		t.Errorf("value = %q, want ab", tr.Nodes[1].Value)
+	}
+	tr.Undo()
+	if tr.Last() != nil {
+		t.Error("Last at the root is not nil")
+	}
+	tr.Redo()
+	tr.Push("c", time.Now())
+	tr.Undo()
+	if tr.Last() != nil {
+		t.Error("Last returned a state that has children")
+	}
+}
+
+func TestSquash(t *testing.T) {
+	concat := func(a, b string) string { return a + b }
+
+	var tr Tree[string]
+	tr.Push("a", time.Now())
+	tr.Push("b", time.Now())
+	tr.Push("c", time.Now())
+	tr.Push("d", time.Now())
+	if !tr.Squash(1, concat) {
+		t.Fatal("Squash of the newest states failed")
+	}
+	if tr.Len() != 3 || tr.Current != 2 || tr.Nodes[2].Value != "bcd" {
+		t.Fatalf("after Squash: len %d, current %d, value %q", tr.Len(), tr.Current, tr.Nodes[2].Value)
+	}
+	if v, ok := tr.Undo(); !ok || v != "bcd" || tr.Current != 1 {
+		t.Fatalf("Undo = %q, %v at %d, want bcd at 1", v, ok, tr.Current)
+	}
+	if tr.Squash(0, concat) {
+		t.Error("Squash with undone states succeeded")
+	}
+
+	// A branch in between keeps the states apart.
+	var br Tree[string]
+	br.Push("a", time.Now())
+	br.Push("b", time.Now())
+	br.Undo()
+	br.Push("c", time.Now())
+	if br.Squash(0, concat) {
+		t.Error("Squash across a branch succeeded")
+	}
+	if br.Squash(br.Current, concat) {
+		t.Error("Squash of no changes succeeded")
+	}
+}
+
+func TestValid(t *testing.T) {
+	build := func() Tree[string] {
+		var tr Tree[string]
+		tr.Push("a", time.Now())
+		tr.Push("b", time.Now())
+		tr.Undo()
+		tr.Push("c", time.Now())
+		return tr
+	}
+	if tr := build(); !tr.Valid() {
+		t.Fatal("a history built by Push and Undo should be valid")
+	}
+	if tr := (Tree[string]{}); !tr.Valid() {
+		t.Error("the empty history should be valid")
+	}
+
+	damage := map[string]func(*Tree[string]){
+		"current out of range": func(tr *Tree[string]) { tr.Current = 9 },
+		"parent out of range":  func(tr *Tree[string]) { tr.Nodes[2].Parent = 7 },
+		"parent after child":   func(tr *Tree[string]) { tr.Nodes[1].Parent = 2 },
+		"child out of range":   func(tr *Tree[string]) { tr.Nodes[1].Children = []int{8} },
+		"child of another":     func(tr *Tree[string]) { tr.Nodes[0].Children = []int{1, 2} },
+		"redo out of range":    func(tr *Tree[string]) { tr.Nodes[1].Redo = 5 },
+		"truncated":            func(tr *Tree[string]) { tr.Nodes = tr.Nodes[:2] },
+	}
+	for name, f := range damage {
+		tr := build()
+		f(&tr)
+		if tr.Valid() {
+			t.Errorf("%s: damaged history reported valid", name)
+		}
+	}
+}
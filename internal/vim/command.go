@@ -0,0 +1,234 @@
+// Package vim parses the commands of the vim keymap: counts, operators,
+// motions, text objects and the other normal and visual mode commands. The
+// editor carries them out.
+package vim
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// State tells whether the keys typed so far form a command.
+type State int
+
+const (
+	Incomplete State = iota // a prefix of a command, more keys are needed
+	Complete
+	Invalid
+)
+
+// Line is the motion of a doubled operator such as dd or >>, which works on
+// whole lines.
+const Line = "line"
+
+// Command is a parsed command. Exactly one of Motion, Object and Action is
+// set, except for an operator in visual mode, which works on the selection.
+type Command struct {
+	Count    int    // 0 if none was typed; counts before and after an operator multiply
+	Operator string // d, c, y, > or <
+	Motion   string // e.g. w, $, gg or f; Line for a doubled operator
+	Object   string // text object, e.g. iw or a(
+	Action   string // any other command, e.g. x, p, i or ctrl+r
+	Char     string // the character after f, t, F, T and r
+	Register string // the register after ", e.g. a in "ayy; empty for the unnamed one
+}
+
+// Times returns the count, or 1 if none was typed.
+func (c Command) Times() int {
+	return max(c.Count, 1)
+}
+
+// motions are the motions of a single key.
+var motions = map[string]bool{
+	"h": true, "j": true, "k": true, "l": true,
+	"w": true, "b": true, "e": true, "W": true, "B": true, "E": true,
+	"0": true, "^": true, "$": true, "G": true, "%": true, ";": true, ",": true,
+	"+": true, "-": true,
+}
+
+// motionAliases maps other keys to the motion they stand for.
+var motionAliases = map[string]string{
+	"left": "h", "right": "l", "up": "k", "down": "j",
+	"backspace": "h", " ": "l", "enter": "+",
+	"home": "0", "end": "$",
+}
+
+// charMotions take the character to find as the next key.
+var charMotions = map[string]bool{"f": true, "t": true, "F": true, "T": true}
+
+var operators = map[string]bool{"d": true, "c": true, "y": true, ">": true, "<": true}
+
+// actions are the other commands of a single key in normal mode.
+var actions = map[string]bool{
+	"x": true, "X": true, "D": true, "C": true, "s": true, "S": true, "Y": true,
+	"p": true, "P": true, "u": true, "ctrl+r": true, "J": true, "~": true,
+	"i": true, "a": true, "I": true, "A": true, "o": true, "O": true,
+	"v": true, "V": true, ".": true, "n": true, "N": true, "/": true,
+	"delete": true,
+}
+
+// visualActions are the other commands of a single key in visual mode.
+var visualActions = map[string]bool{
+	"x": true, "X": true, "D": true, "s": true, "S": true, "Y": true, "p": true, "P": true,
+	"J": true, "~": true, "o": true, "v": true, "V": true, "delete": true,
+}
+
+// objects maps the key after i or a to the text object it selects.
+var objects = map[string]string{
+	"w": "w", "W": "W",
+	"(": "(", ")": "(", "b": "(",
+	"{": "{", "}": "{", "B": "{",
+	"[": "[", "]": "[",
+	"<": "<", ">": "<",
+	`"`: `"`, "'": "'", "`": "`",
+}
+
+// IsRegister reports whether k names a register: a to z, A to Z to append
+// to the lowercase one, 0 to 9, " for the unnamed register and + or * for
+// the system clipboard.
+func IsRegister(k string) bool {
+	if len(k) != 1 {
+		return false
+	}
+	r := k[0]
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		r == '"' || r == '+' || r == '*'
+}
+
+// Parse parses the keys typed so far, as reported by the terminal, e.g.
+// "2", "d", "w". A register can be named before or after the count, e.g.
+// `"`, "a", "y", "y". In visual mode operators work on the selection and i
+// and a select text objects.
+func Parse(keys []string, visual bool) (Command, State) {
+	var c Command
+	i := 0
+	c.Count, i = count(keys, i)
+	if i < len(keys) && keys[i] == `"` {
+		if i+1 == len(keys) {
+			return c, Incomplete
+		}
+		if !IsRegister(keys[i+1]) {
+			return c, Invalid
+		}
+		c.Register = keys[i+1]
+		n, j := count(keys, i+2)
+		if n > 0 {
+			c.Count = max(c.Count, 1) * n
+		}
+		i = j
+	}
+	if i == len(keys) {
+		return c, Incomplete
+	}
+
+	k := keys[i]
+	switch {
+	case operators[k] && visual:
+		c.Operator = k
+		return c, done(keys, i)
+	case operators[k]:
+		c.Operator = k
+		n, j := count(keys, i+1)
+		if n > 0 {
+			c.Count = max(c.Count, 1) * n
+		}
+		if j == len(keys) {
+			return c, Incomplete
+		}
+		if keys[j] == k {
+			c.Motion = Line
+			return c, done(keys, j)
+		}
+		if keys[j] == "i" || keys[j] == "a" {
+			return object(c, keys, j)
+		}
+		return motion(c, keys, j)
+	case visual && (k == "i" || k == "a"):
+		return object(c, keys, i)
+	case k == "r" && !visual:
+		c.Action = k
+		return char(c, keys, i)
+	case visual && visualActions[k], !visual && actions[k]:
+		c.Action = k
+		return c, done(keys, i)
+	}
+	return motion(c, keys, i)
+}
+
+// count reads a count starting at keys[i]. A 0 is a count only after another
+// digit, on its own it is a motion.
+func count(keys []string, i int) (int, int) {
+	n := 0
+	for ; i < len(keys); i++ {
+		d, err := strconv.Atoi(keys[i])
+		if err != nil || len(keys[i]) != 1 || (d == 0 && n == 0) {
+			break
+		}
+		n = min(n*10+d, 99999)
+	}
+	return n, i
+}
+
+// motion parses the motion starting at keys[i].
+func motion(c Command, keys []string, i int) (Command, State) {
+	k := keys[i]
+	if alias, ok := motionAliases[k]; ok {
+		k = alias
+	}
+	switch {
+	case motions[k]:
+		c.Motion = k
+		return c, done(keys, i)
+	case charMotions[k]:
+		c.Motion = k
+		return char(c, keys, i)
+	case k == "g":
+		if i+1 == len(keys) {
+			return c, Incomplete
+		}
+		if keys[i+1] == "g" {
+			c.Motion = "gg"
+			return c, done(keys, i+1)
+		}
+	}
+	return c, Invalid
+}
+
+// object parses the text object starting with i or a at keys[i].
+func object(c Command, keys []string, i int) (Command, State) {
+	if i+1 == len(keys) {
+		return c, Incomplete
+	}
+	obj, ok := objects[keys[i+1]]
+	if !ok {
+		return c, Invalid
+	}
+	c.Object = keys[i] + obj
+	return c, done(keys, i+1)
+}
+
+// char reads the character argument of the command at keys[i].
+func char(c Command, keys []string, i int) (Command, State) {
+	if i+1 == len(keys) {
+		return c, Incomplete
+	}
+	k := keys[i+1]
+	if k == "tab" {
+		k = "\t"
+	}
+	if utf8.RuneCountInString(k) != 1 || strings.ContainsRune(k, utf8.RuneError) {
+		return c, Invalid
+	}
+	c.Char = k
+	return c, done(keys, i+1)
+}
+
+// done reports a command ending at keys[i] as complete, or as invalid if more
+// keys follow.
+func done(keys []string, i int) State {
+	if i == len(keys)-1 {
+		return Complete
+	}
+	return Invalid
+}
@@ -0,0 +1,97 @@
+package vim
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		keys   string
+		visual bool
+		want   Command
+		state  State
+	}{
+		{"w", false, Command{Motion: "w"}, Complete},
+		{"3 w", false, Command{Count: 3, Motion: "w"}, Complete},
+		{"0", false, Command{Motion: "0"}, Complete},
+		{"1 0 j", false, Command{Count: 10, Motion: "j"}, Complete},
+		{"d w", false, Command{Operator: "d", Motion: "w"}, Complete},
+		{"2 d 3 w", false, Command{Count: 6, Operator: "d", Motion: "w"}, Complete},
+		{"d 0", false, Command{Operator: "d", Motion: "0"}, Complete},
+		{"d d", false, Command{Operator: "d", Motion: Line}, Complete},
+		{"3 > >", false, Command{Count: 3, Operator: ">", Motion: Line}, Complete},
+		{"c i w", false, Command{Operator: "c", Object: "iw"}, Complete},
+		{"d a b", false, Command{Operator: "d", Object: "a("}, Complete},
+		{"y i \"", false, Command{Operator: "y", Object: `i"`}, Complete},
+		{"d f x", false, Command{Operator: "d", Motion: "f", Char: "x"}, Complete},
+		{"t space", false, Command{Motion: "t", Char: " "}, Complete},
+		{"g g", false, Command{Motion: "gg"}, Complete},
+		{"5 G", false, Command{Count: 5, Motion: "G"}, Complete},
+		{"r a", false, Command{Action: "r", Char: "a"}, Complete},
+		{"x", false, Command{Action: "x"}, Complete},
+		{"ctrl+r", false, Command{Action: "ctrl+r"}, Complete},
+		{"left", false, Command{Motion: "h"}, Complete},
+		{"d", true, Command{Operator: "d"}, Complete},
+		{"i w", true, Command{Object: "iw"}, Complete},
+		{"o", true, Command{Action: "o"}, Complete},
+		{"\" a y y", false, Command{Register: "a", Operator: "y", Motion: Line}, Complete},
+		{"2 \" A d w", false, Command{Count: 2, Register: "A", Operator: "d", Motion: "w"}, Complete},
+		{"\" + 3 p", false, Command{Count: 3, Register: "+", Action: "p"}, Complete},
+		{"\" b y", true, Command{Register: "b", Operator: "y"}, Complete},
+
+		{"2", false, Command{Count: 2}, Incomplete},
+		{"d", false, Command{Operator: "d"}, Incomplete},
+		{"d 2", false, Command{Operator: "d", Count: 2}, Incomplete},
+		{"d i", false, Command{Operator: "d"}, Incomplete},
+		{"f", false, Command{Motion: "f"}, Incomplete},
+		{"g", false, Command{}, Incomplete},
+		{"\"", false, Command{}, Incomplete},
+		{"\" a", false, Command{Register: "a"}, Incomplete},
+
+		{"q", false, Command{}, Invalid},
+		{"g x", false, Command{}, Invalid},
+		{"d i q", false, Command{Operator: "d"}, Invalid},
+		{"d x", false, Command{Operator: "d"}, Invalid},
+		{"\" -", false, Command{}, Invalid},
+		{"i", true, Command{}, Incomplete},
+	}
+	for _, tt := range tests {
+		keys := strings.Split(tt.keys, " ")
+		for i, k := range keys {
+			if k == "space" {
+				keys[i] = " "
+			}
+		}
+		got, state := Parse(keys, tt.visual)
+		if state != tt.state {
+			t.Errorf("Parse(%q, %v) state = %v, want %v", tt.keys, tt.visual, state, tt.state)
+			continue
+		}
+		if state != Invalid && got != tt.want {
+			t.Errorf("Parse(%q, %v) = %+v, want %+v", tt.keys, tt.visual, got, tt.want)
+		}
+	}
+}
+
+func TestTimes(t *testing.T) {
+	if got := (Command{}).Times(); got != 1 {
+		t.Errorf("Times without a count = %d, want 1", got)
+	}
+	if got := (Command{Count: 4}).Times(); got != 4 {
+		t.Errorf("Times = %d, want 4", got)
+	}
+}
+
+func TestIsRegister(t *testing.T) {
+	for _, k := range []string{"a", "z", "A", "0", "9", `"`, "+", "*"} {
+		if !IsRegister(k) {
+			t.Errorf("IsRegister(%q) = false, want true", k)
+		}
+	}
+	for _, k := range []string{"", "-", "ab", "é", "esc", " "} {
+		if IsRegister(k) {
+			t.Errorf("IsRegister(%q) = true, want false", k)
+		}
+	}
+}